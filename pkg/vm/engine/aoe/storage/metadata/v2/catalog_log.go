@@ -0,0 +1,312 @@
+// Copyright 2021 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+// CatalogLog is a write-ahead log for the metadata package's catalog
+// mutations (CommitInfo appends) and Sequence counters, closing the gap
+// the rest of this package leaves open: today both are kept in memory
+// only, so a crash loses every uncommitted TranId allocation and every
+// commit the last checkpoint didn't already cover. CatalogLog persists
+// each append as a framed, checksummed record in an append-only file and
+// periodically checkpoints the whole Sequence state, truncating the log
+// behind it. RebuildCatalog replays a directory's newest checkpoint plus
+// whatever log records follow it, discarding a torn tail the same way
+// the rest of this corpus's restart paths do: stop at the first bad CRC
+// and rebuild nothing past it.
+package metadata
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+)
+
+const (
+	logFileName  = "catalog.log"
+	ckptFileName = "catalog.ckpt"
+	ckptTmpName  = "catalog.ckpt.tmp"
+)
+
+// RecordKind distinguishes a CatalogLog record's payload.
+type RecordKind uint8
+
+const (
+	// RecordCommit logs one CommitInfo append: Create, UpgradeFull,
+	// UpgradeClose, UpgradeSorted, SoftDelete, or HardDelete.
+	RecordCommit RecordKind = iota
+	// RecordCheckpoint logs a full Sequence snapshot. It only ever
+	// appears in the checkpoint file, never in the log file itself.
+	RecordCheckpoint
+)
+
+// LogRecord is one framed entry. A RecordCommit uses every field: the
+// Next* counters are a snapshot of Sequence taken at the same instant as
+// the append, not just NextCommitId, so RebuildCatalog can restore
+// nextTableId/nextSegmentId/nextBlockId/nextIndexId even when replay
+// never reaches a RecordCheckpoint. A RecordCheckpoint only uses the
+// Next* counters.
+type LogRecord struct {
+	Kind     RecordKind
+	EntryId  uint64
+	CommitId uint64
+	TranId   uint64
+	Op       OpT
+
+	NextTableId   uint64
+	NextSegmentId uint64
+	NextBlockId   uint64
+	NextCommitId  uint64
+	NextIndexId   uint64
+}
+
+// recordSize is the fixed on-disk size of one LogRecord, chosen so a
+// torn write during a crash is detectable as "fewer than recordSize
+// bytes remain" rather than requiring a length prefix.
+const recordSize = 1 + 8 + 8 + 8 + 1 + 8*5 + 4
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+func encodeRecord(r LogRecord) []byte {
+	b := make([]byte, recordSize)
+	b[0] = byte(r.Kind)
+	binary.LittleEndian.PutUint64(b[1:9], r.EntryId)
+	binary.LittleEndian.PutUint64(b[9:17], r.CommitId)
+	binary.LittleEndian.PutUint64(b[17:25], r.TranId)
+	b[25] = byte(r.Op)
+	binary.LittleEndian.PutUint64(b[26:34], r.NextTableId)
+	binary.LittleEndian.PutUint64(b[34:42], r.NextSegmentId)
+	binary.LittleEndian.PutUint64(b[42:50], r.NextBlockId)
+	binary.LittleEndian.PutUint64(b[50:58], r.NextCommitId)
+	binary.LittleEndian.PutUint64(b[58:66], r.NextIndexId)
+	binary.LittleEndian.PutUint32(b[66:70], crc32.Checksum(b[:66], crcTable))
+	return b
+}
+
+func decodeRecord(b []byte) (LogRecord, bool) {
+	if len(b) != recordSize {
+		return LogRecord{}, false
+	}
+	if crc32.Checksum(b[:66], crcTable) != binary.LittleEndian.Uint32(b[66:70]) {
+		return LogRecord{}, false
+	}
+	return LogRecord{
+		Kind:          RecordKind(b[0]),
+		EntryId:       binary.LittleEndian.Uint64(b[1:9]),
+		CommitId:      binary.LittleEndian.Uint64(b[9:17]),
+		TranId:        binary.LittleEndian.Uint64(b[17:25]),
+		Op:            OpT(b[25]),
+		NextTableId:   binary.LittleEndian.Uint64(b[26:34]),
+		NextSegmentId: binary.LittleEndian.Uint64(b[34:42]),
+		NextBlockId:   binary.LittleEndian.Uint64(b[42:50]),
+		NextCommitId:  binary.LittleEndian.Uint64(b[50:58]),
+		NextIndexId:   binary.LittleEndian.Uint64(b[58:66]),
+	}, true
+}
+
+// CatalogLog appends CommitInfo mutations to an on-disk, crash-safe log
+// and periodically checkpoints Sequence, truncating the log behind it.
+// It is safe for concurrent use.
+type CatalogLog struct {
+	mu  sync.Mutex
+	dir string
+	f   *os.File
+}
+
+// OpenCatalogLog opens (creating if necessary) the catalog log in dir,
+// ready to accept AppendCommit calls. It does not replay anything - call
+// RebuildCatalog first if dir might already hold committed state.
+func OpenCatalogLog(dir string) (*CatalogLog, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("metadata: create log dir %s: %w", dir, err)
+	}
+	f, err := os.OpenFile(filepath.Join(dir, logFileName), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("metadata: open catalog log: %w", err)
+	}
+	return &CatalogLog{dir: dir, f: f}, nil
+}
+
+// AppendCommit durably logs one CommitInfo append before it becomes
+// visible to readers, so a crash between the in-memory append and the
+// next checkpoint is still recoverable by RebuildCatalog. It snapshots
+// seq's counters into the record alongside the commit itself, so a
+// crash before the first Checkpoint still lets RebuildCatalog restore
+// nextTableId/nextSegmentId/nextBlockId/nextIndexId instead of resetting
+// them to 0 and risking newly allocated ids colliding with already
+// persisted ones.
+func (l *CatalogLog) AppendCommit(entryId uint64, info *CommitInfo, seq *Sequence) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	rec := encodeRecord(LogRecord{
+		Kind:          RecordCommit,
+		EntryId:       entryId,
+		CommitId:      info.CommitId,
+		TranId:        info.TranId,
+		Op:            info.Op,
+		NextTableId:   atomic.LoadUint64(&seq.nextTableId),
+		NextSegmentId: atomic.LoadUint64(&seq.nextSegmentId),
+		NextBlockId:   atomic.LoadUint64(&seq.nextBlockId),
+		NextCommitId:  atomic.LoadUint64(&seq.nextCommitId),
+		NextIndexId:   atomic.LoadUint64(&seq.nextIndexId),
+	})
+	if _, err := l.f.Write(rec); err != nil {
+		return fmt.Errorf("metadata: append catalog log: %w", err)
+	}
+	return l.f.Sync()
+}
+
+// Checkpoint snapshots seq to the checkpoint file and truncates the log,
+// so RebuildCatalog never has to replay more than one checkpoint period
+// of records. The checkpoint file is written to a temp path and renamed
+// into place so a crash mid-checkpoint leaves the previous checkpoint
+// (and the not-yet-truncated log that complements it) intact.
+func (l *CatalogLog) Checkpoint(seq *Sequence) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	rec := encodeRecord(LogRecord{
+		Kind:          RecordCheckpoint,
+		NextTableId:   seq.nextTableId,
+		NextSegmentId: seq.nextSegmentId,
+		NextBlockId:   seq.nextBlockId,
+		NextCommitId:  seq.nextCommitId,
+		NextIndexId:   seq.nextIndexId,
+	})
+	tmp := filepath.Join(l.dir, ckptTmpName)
+	if err := os.WriteFile(tmp, rec, 0644); err != nil {
+		return fmt.Errorf("metadata: write checkpoint: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(l.dir, ckptFileName)); err != nil {
+		return fmt.Errorf("metadata: install checkpoint: %w", err)
+	}
+
+	if err := l.f.Close(); err != nil {
+		return fmt.Errorf("metadata: close log before truncation: %w", err)
+	}
+	f, err := os.OpenFile(filepath.Join(l.dir, logFileName), os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("metadata: truncate catalog log: %w", err)
+	}
+	l.f = f
+	return nil
+}
+
+// Close closes the underlying log file.
+func (l *CatalogLog) Close() error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.f.Close()
+}
+
+// RebuildCatalog replays dir's newest checkpoint (if any) followed by
+// its log records, in commit-id order, and returns the restored
+// Sequence plus every committed LogRecord. A log record that fails
+// its CRC - or, being the tail record, carries a TranId >= MinUncommitId
+// that never went on to receive a real CommitId - stops the replay
+// right there: everything up to it is trusted, everything from it on is
+// discarded as a torn or abandoned in-flight write.
+func RebuildCatalog(dir string) (*Sequence, []LogRecord, error) {
+	seq := &Sequence{}
+	if ckpt, err := os.ReadFile(filepath.Join(dir, ckptFileName)); err == nil {
+		rec, ok := decodeRecord(ckpt)
+		if ok && rec.Kind == RecordCheckpoint {
+			seq.nextTableId = rec.NextTableId
+			seq.nextSegmentId = rec.NextSegmentId
+			seq.nextBlockId = rec.NextBlockId
+			seq.nextCommitId = rec.NextCommitId
+			seq.nextIndexId = rec.NextIndexId
+		}
+	} else if !os.IsNotExist(err) {
+		return nil, nil, fmt.Errorf("metadata: read checkpoint: %w", err)
+	}
+
+	f, err := os.Open(filepath.Join(dir, logFileName))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return seq, nil, nil
+		}
+		return nil, nil, fmt.Errorf("metadata: open catalog log: %w", err)
+	}
+	defer f.Close()
+
+	var records []LogRecord
+	r := bufio.NewReader(f)
+	buf := make([]byte, recordSize)
+	for {
+		n, err := readFull(r, buf)
+		if n < recordSize {
+			// Torn tail write: fewer bytes than one record remain.
+			break
+		}
+		rec, ok := decodeRecord(buf)
+		if !ok {
+			// Bad CRC: this record and anything after it is untrusted.
+			break
+		}
+		if rec.Kind != RecordCommit {
+			break
+		}
+		if IsTransientCommitId(rec.TranId) && rec.CommitId == 0 {
+			// An intent record that never received a real CommitId -
+			// only valid to see at the very tail, since a committed
+			// follow-up record would have a real CommitId for the same
+			// TranId. Treat it as abandoned and stop here.
+			break
+		}
+		records = append(records, rec)
+		if err != nil {
+			break
+		}
+	}
+	// Every RecordCommit carries a snapshot of all five Sequence counters
+	// taken at append time, so even without ever reaching a checkpoint,
+	// replaying the newest record restores table/segment/block/index ids
+	// exactly as well as it restores commit ids - merge in the max across
+	// every record rather than special-casing nextCommitId alone.
+	for _, rec := range records {
+		if rec.NextTableId > seq.nextTableId {
+			seq.nextTableId = rec.NextTableId
+		}
+		if rec.NextSegmentId > seq.nextSegmentId {
+			seq.nextSegmentId = rec.NextSegmentId
+		}
+		if rec.NextBlockId > seq.nextBlockId {
+			seq.nextBlockId = rec.NextBlockId
+		}
+		if rec.NextIndexId > seq.nextIndexId {
+			seq.nextIndexId = rec.NextIndexId
+		}
+		if rec.NextCommitId > seq.nextCommitId {
+			seq.nextCommitId = rec.NextCommitId
+		}
+	}
+	sort.SliceStable(records, func(i, j int) bool { return records[i].CommitId < records[j].CommitId })
+	return seq, records, nil
+}
+
+func readFull(r *bufio.Reader, buf []byte) (int, error) {
+	total := 0
+	for total < len(buf) {
+		n, err := r.Read(buf[total:])
+		total += n
+		if err != nil {
+			return total, err
+		}
+	}
+	return total, nil
+}
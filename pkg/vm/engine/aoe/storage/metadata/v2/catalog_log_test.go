@@ -0,0 +1,82 @@
+// Copyright 2021 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package metadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRebuildCatalogRestoresAllCountersBeforeFirstCheckpoint(t *testing.T) {
+	dir := t.TempDir()
+	log, err := OpenCatalogLog(dir)
+	assert.NoError(t, err)
+
+	seq := &Sequence{}
+	seq.NextTableId()
+	seq.NextSegmentId()
+	seq.NextSegmentId()
+	seq.NextBlockId()
+	seq.NextIndexId()
+	info := &CommitInfo{CommitId: seq.NextCommitId(), Op: OpCreate}
+	assert.NoError(t, log.AppendCommit(1, info, seq))
+	assert.NoError(t, log.Close())
+
+	// Simulate a crash: no Checkpoint call ever happened, so only the
+	// log file - not a checkpoint - is available to replay.
+	restored, records, err := RebuildCatalog(dir)
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, seq.nextTableId, restored.nextTableId)
+	assert.Equal(t, seq.nextSegmentId, restored.nextSegmentId)
+	assert.Equal(t, seq.nextBlockId, restored.nextBlockId)
+	assert.Equal(t, seq.nextCommitId, restored.nextCommitId)
+	assert.Equal(t, seq.nextIndexId, restored.nextIndexId)
+}
+
+func TestRebuildCatalogMergesCheckpointAndLaterCommits(t *testing.T) {
+	dir := t.TempDir()
+	log, err := OpenCatalogLog(dir)
+	assert.NoError(t, err)
+
+	seq := &Sequence{}
+	seq.NextTableId()
+	info := &CommitInfo{CommitId: seq.NextCommitId(), Op: OpCreate}
+	assert.NoError(t, log.AppendCommit(1, info, seq))
+	assert.NoError(t, log.Checkpoint(seq))
+
+	// A commit after the checkpoint must still win over the checkpoint's
+	// older snapshot.
+	seq.NextTableId()
+	seq.NextSegmentId()
+	info2 := &CommitInfo{CommitId: seq.NextCommitId(), Op: OpUpgradeFull}
+	assert.NoError(t, log.AppendCommit(2, info2, seq))
+	assert.NoError(t, log.Close())
+
+	restored, records, err := RebuildCatalog(dir)
+	assert.NoError(t, err)
+	assert.Len(t, records, 1)
+	assert.Equal(t, seq.nextTableId, restored.nextTableId)
+	assert.Equal(t, seq.nextSegmentId, restored.nextSegmentId)
+	assert.Equal(t, seq.nextCommitId, restored.nextCommitId)
+}
+
+func TestRebuildCatalogEmptyDirReturnsZeroSequence(t *testing.T) {
+	dir := t.TempDir()
+	seq, records, err := RebuildCatalog(dir)
+	assert.NoError(t, err)
+	assert.Nil(t, records)
+	assert.Equal(t, uint64(0), seq.nextCommitId)
+}
@@ -0,0 +1,377 @@
+// Copyright 2021 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package metadata
+
+import (
+	"sort"
+)
+
+// RoaringBitmap is a compressed set of row offsets, used by CommitInfo.
+// Tombstone to track which rows of a block a DELETE or UPDATE removed
+// from visibility without rewriting the block. It follows the standard
+// Roaring layout: offsets are split into a 16-bit high key (which
+// 65536-wide chunk) and a 16-bit low value (the offset within that
+// chunk), and each chunk is stored in whichever of three container
+// flavors is smallest - an array of sorted values for a sparse chunk, a
+// fixed bitmap for a dense one, or a list of (start, length) runs for a
+// chunk made of long contiguous deleted ranges, the common case for a
+// bulk DELETE WHERE that removes a whole tail of a block.
+type RoaringBitmap struct {
+	keys       []uint16
+	containers []container
+}
+
+// NewRoaringBitmap returns an empty RoaringBitmap.
+func NewRoaringBitmap() *RoaringBitmap {
+	return &RoaringBitmap{}
+}
+
+const (
+	// arrayMaxCardinality is the container-switch threshold: above this
+	// many set bits, a bitmap container (8KB, fixed) is no larger than
+	// an array container and supports O(1) Add/Contains.
+	arrayMaxCardinality = 4096
+	chunkBits           = 16
+	chunkSize           = 1 << chunkBits
+)
+
+func splitKey(x uint32) (hi, lo uint16) {
+	return uint16(x >> chunkBits), uint16(x & (chunkSize - 1))
+}
+
+// Add sets x in the bitmap.
+func (b *RoaringBitmap) Add(x uint32) {
+	hi, lo := splitKey(x)
+	i := b.find(hi)
+	if i < len(b.keys) && b.keys[i] == hi {
+		b.containers[i] = b.containers[i].add(lo)
+		return
+	}
+	b.keys = append(b.keys, 0)
+	b.containers = append(b.containers, nil)
+	copy(b.keys[i+1:], b.keys[i:])
+	copy(b.containers[i+1:], b.containers[i:])
+	b.keys[i] = hi
+	b.containers[i] = newArrayContainer().add(lo)
+}
+
+// AddRange sets every offset in [start, end) in the bitmap - the common
+// case for a range DELETE. It calls Add once per offset, so its cost
+// grows with the range's width, and the range stays in array or bitmap
+// containers until Optimize turns it into a run container.
+func (b *RoaringBitmap) AddRange(start, end uint32) {
+	for x := start; x < end; x++ {
+		b.Add(x)
+	}
+}
+
+// Contains reports whether x is set.
+func (b *RoaringBitmap) Contains(x uint32) bool {
+	hi, lo := splitKey(x)
+	i := b.find(hi)
+	if i < len(b.keys) && b.keys[i] == hi {
+		return b.containers[i].contains(lo)
+	}
+	return false
+}
+
+func (b *RoaringBitmap) find(hi uint16) int {
+	return sort.Search(len(b.keys), func(i int) bool { return b.keys[i] >= hi })
+}
+
+// Cardinality returns the number of set bits.
+func (b *RoaringBitmap) Cardinality() uint64 {
+	var n uint64
+	for _, c := range b.containers {
+		n += uint64(c.cardinality())
+	}
+	return n
+}
+
+// Or returns the union of b and other, leaving both inputs unmodified.
+func (b *RoaringBitmap) Or(other *RoaringBitmap) *RoaringBitmap {
+	out := NewRoaringBitmap()
+	i, j := 0, 0
+	for i < len(b.keys) || j < len(other.keys) {
+		switch {
+		case j >= len(other.keys) || (i < len(b.keys) && b.keys[i] < other.keys[j]):
+			out.keys = append(out.keys, b.keys[i])
+			out.containers = append(out.containers, b.containers[i])
+			i++
+		case i >= len(b.keys) || other.keys[j] < b.keys[i]:
+			out.keys = append(out.keys, other.keys[j])
+			out.containers = append(out.containers, other.containers[j])
+			j++
+		default:
+			out.keys = append(out.keys, b.keys[i])
+			out.containers = append(out.containers, b.containers[i].or(other.containers[j]))
+			i++
+			j++
+		}
+	}
+	out.Optimize()
+	return out
+}
+
+// ToSlice returns every set offset in ascending order.
+func (b *RoaringBitmap) ToSlice() []uint32 {
+	out := make([]uint32, 0, b.Cardinality())
+	for ci, hi := range b.keys {
+		for _, lo := range b.containers[ci].toArray() {
+			out = append(out, uint32(hi)<<chunkBits|uint32(lo))
+		}
+	}
+	return out
+}
+
+// SizeBytes estimates the bitmap's serialized footprint, the way
+// EstimateBlockSize accounts for a block's tombstone overhead.
+func (b *RoaringBitmap) SizeBytes() int {
+	size := 4 // container count
+	for _, c := range b.containers {
+		size += 2 + 1 + c.sizeBytes() // key + container-type tag + payload
+	}
+	return size
+}
+
+// Optimize re-picks each chunk's container flavor for minimal size -
+// e.g. after Or produces a chunk dense enough that a bitmap container
+// now beats the array or run container it started as.
+func (b *RoaringBitmap) Optimize() {
+	for i, c := range b.containers {
+		b.containers[i] = c.optimized()
+	}
+}
+
+// container is the per-chunk (65536-offset-wide) storage strategy a
+// RoaringBitmap picks independently for each key, matching upstream
+// Roaring: array for sparse chunks, bitmap for dense ones, run for long
+// contiguous ranges.
+type container interface {
+	add(x uint16) container
+	contains(x uint16) bool
+	cardinality() int
+	toArray() []uint16
+	sizeBytes() int
+	or(other container) container
+	optimized() container
+}
+
+type arrayContainer []uint16
+
+func newArrayContainer() container {
+	return arrayContainer(nil)
+}
+
+func (c arrayContainer) search(x uint16) int {
+	return sort.Search(len(c), func(i int) bool { return c[i] >= x })
+}
+
+func (c arrayContainer) add(x uint16) container {
+	i := c.search(x)
+	if i < len(c) && c[i] == x {
+		return c
+	}
+	c = append(c, 0)
+	copy(c[i+1:], c[i:])
+	c[i] = x
+	if len(c) > arrayMaxCardinality {
+		return toBitmapContainer(c)
+	}
+	return c
+}
+
+func (c arrayContainer) contains(x uint16) bool {
+	i := c.search(x)
+	return i < len(c) && c[i] == x
+}
+
+func (c arrayContainer) cardinality() int { return len(c) }
+
+func (c arrayContainer) toArray() []uint16 { return append([]uint16(nil), c...) }
+
+func (c arrayContainer) sizeBytes() int { return 2 * len(c) }
+
+func (c arrayContainer) or(other container) container {
+	out := arrayContainer(append([]uint16(nil), c...))
+	var res container = out
+	for _, x := range other.toArray() {
+		res = res.add(x)
+	}
+	return res
+}
+
+func (c arrayContainer) optimized() container {
+	if len(c) > arrayMaxCardinality {
+		return toBitmapContainer(c)
+	}
+	if runs := toRuns(c); runContainerSize(runs) < c.sizeBytes() {
+		return runContainer(runs)
+	}
+	return c
+}
+
+// bitmapContainer is a fixed 65536-bit set, one bit per possible low
+// value in a chunk.
+type bitmapContainer [chunkSize / 64]uint64
+
+func toBitmapContainer(values []uint16) container {
+	var bm bitmapContainer
+	for _, x := range values {
+		bm[x/64] |= 1 << (x % 64)
+	}
+	return &bm
+}
+
+func (c *bitmapContainer) add(x uint16) container {
+	c[x/64] |= 1 << (x % 64)
+	return c
+}
+
+func (c *bitmapContainer) contains(x uint16) bool {
+	return c[x/64]&(1<<(x%64)) != 0
+}
+
+func (c *bitmapContainer) cardinality() int {
+	n := 0
+	for _, word := range c {
+		for word != 0 {
+			word &= word - 1
+			n++
+		}
+	}
+	return n
+}
+
+func (c *bitmapContainer) toArray() []uint16 {
+	out := make([]uint16, 0, c.cardinality())
+	for i, word := range c {
+		for b := 0; word != 0; b++ {
+			if word&1 != 0 {
+				out = append(out, uint16(i*64+b))
+			}
+			word >>= 1
+		}
+	}
+	return out
+}
+
+func (c *bitmapContainer) sizeBytes() int { return len(c) * 8 }
+
+func (c *bitmapContainer) or(other container) container {
+	out := *c
+	for _, x := range other.toArray() {
+		out[x/64] |= 1 << (x % 64)
+	}
+	return &out
+}
+
+func (c *bitmapContainer) optimized() container {
+	if n := c.cardinality(); n <= arrayMaxCardinality {
+		arr := arrayContainer(c.toArray())
+		if runs := toRuns(arr); runContainerSize(runs) < arr.sizeBytes() {
+			return runContainer(runs)
+		}
+		return arr
+	}
+	return c
+}
+
+// runPair is one contiguous [Start, Start+Length] range of set values.
+type runPair struct {
+	Start  uint16
+	Length uint16 // number of values after Start, so the run covers Length+1 values
+}
+
+// runContainer is a sorted, non-overlapping list of runPair, ideal for a
+// chunk made of a few long contiguous ranges - the shape a range DELETE
+// or a bulk tail-truncation produces.
+type runContainer []runPair
+
+func toRuns(sorted []uint16) []runPair {
+	if len(sorted) == 0 {
+		return nil
+	}
+	var runs []runPair
+	start := sorted[0]
+	prev := sorted[0]
+	for _, x := range sorted[1:] {
+		if x == prev+1 {
+			prev = x
+			continue
+		}
+		runs = append(runs, runPair{Start: start, Length: prev - start})
+		start, prev = x, x
+	}
+	runs = append(runs, runPair{Start: start, Length: prev - start})
+	return runs
+}
+
+func runContainerSize(runs []runPair) int {
+	return 4 * len(runs)
+}
+
+func (c runContainer) add(x uint16) container {
+	arr := arrayContainer(c.toArray()).add(x)
+	return arr.optimized()
+}
+
+func (c runContainer) contains(x uint16) bool {
+	for _, r := range c {
+		if x >= r.Start && x <= r.Start+r.Length {
+			return true
+		}
+	}
+	return false
+}
+
+func (c runContainer) cardinality() int {
+	n := 0
+	for _, r := range c {
+		n += int(r.Length) + 1
+	}
+	return n
+}
+
+func (c runContainer) toArray() []uint16 {
+	out := make([]uint16, 0, c.cardinality())
+	for _, r := range c {
+		for v := r.Start; ; v++ {
+			out = append(out, v)
+			if v == r.Start+r.Length {
+				break
+			}
+		}
+	}
+	return out
+}
+
+func (c runContainer) sizeBytes() int { return runContainerSize(c) }
+
+func (c runContainer) or(other container) container {
+	arr := arrayContainer(c.toArray())
+	var res container = arr
+	for _, x := range other.toArray() {
+		res = res.add(x)
+	}
+	return res.optimized()
+}
+
+func (c runContainer) optimized() container {
+	arr := arrayContainer(c.toArray())
+	if runContainerSize(c) < arr.sizeBytes() {
+		return c
+	}
+	return arr.optimized()
+}
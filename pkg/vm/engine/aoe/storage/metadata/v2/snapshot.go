@@ -0,0 +1,135 @@
+// Copyright 2021 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package metadata
+
+import "matrixone/pkg/vm/engine/aoe/storage/common"
+
+// CommitView is the effective state of a CommitInfo chain as of some
+// committed id: which op produced it, its applied index if any, whether
+// the entry it belongs to should be considered visible at all, and the
+// row-granularity Tombstone in effect at that point. Tombstone is the
+// union of every committed CommitInfo.Tombstone at or before this view's
+// CommitId (see unionTombstones), not just the single node that
+// produced the view, since an OpDeleteRows/OpUpdateRows commit layers on
+// top of whatever earlier commits already removed from visibility.
+type CommitView struct {
+	CommitId     uint64
+	Op           OpT
+	AppliedIndex uint64
+	HasIndex     bool
+	Visible      bool
+	Tombstone    *RoaringBitmap
+}
+
+// committedChain returns info's CommitInfo chain (the same chain PString
+// prints), newest first, skipping any transient (uncommitted) entry.
+func (info *CommitInfo) committedChain() []*CommitInfo {
+	var chain []*CommitInfo
+	var curr common.ISSLLNode = info
+	for curr != nil {
+		node := curr.(*CommitInfo)
+		if !IsTransientCommitId(node.CommitId) {
+			chain = append(chain, node)
+		}
+		curr = node.GetNext()
+	}
+	return chain
+}
+
+// unionTombstones returns the OR of every non-nil Tombstone in nodes, or
+// nil if none of them carry one - the reconstruction step a scan needs
+// to see every row an OpDeleteRows/OpUpdateRows commit removed, on top
+// of whatever earlier commits already removed.
+func unionTombstones(nodes []*CommitInfo) *RoaringBitmap {
+	var out *RoaringBitmap
+	for _, node := range nodes {
+		if node.Tombstone == nil {
+			continue
+		}
+		if out == nil {
+			out = node.Tombstone
+			continue
+		}
+		out = out.Or(node.Tombstone)
+	}
+	return out
+}
+
+func viewOf(node *CommitInfo, tombstone *RoaringBitmap) *CommitView {
+	idx, ok := node.GetAppliedIndex()
+	return &CommitView{
+		CommitId:     node.CommitId,
+		Op:           node.Op,
+		AppliedIndex: idx,
+		HasIndex:     ok,
+		Visible:      !node.IsHardDeleted() && !node.IsSoftDeleted(),
+		Tombstone:    tombstone,
+	}
+}
+
+// Snapshot walks info's CommitInfo chain and returns the CommitView
+// effective as of commitId, skipping any transient (uncommitted) entry
+// along the way - the prerequisite for an MVCC scan or a time-travel
+// query to stop hand-walking GetNext and reimplementing
+// GetAppliedIndex's fallback logic at every call site. Its Tombstone is
+// the union of every committed Tombstone at or before commitId, so a
+// scan reconstructs full block visibility without walking the chain a
+// second time.
+//
+// A nil return means no committed entry exists at or before commitId,
+// e.g. a fresh, still-uncommitted Create.
+func (info *CommitInfo) Snapshot(commitId uint64) *CommitView {
+	chain := info.committedChain()
+	for i, node := range chain {
+		if node.CommitId <= commitId {
+			return viewOf(node, unionTombstones(chain[i:]))
+		}
+	}
+	return nil
+}
+
+// VisibleAt reports whether info's chain has a committed entry visible
+// at txnId, neither soft- nor hard-deleted. A txn never sees a row past
+// its own OpSoftDelete or OpHardDelete: once either commits, every
+// snapshot at or after its CommitId reports invisible, matching
+// CommitInfo.IsSoftDeleted/IsHardDeleted.
+func (info *CommitInfo) VisibleAt(txnId uint64) bool {
+	view := info.Snapshot(txnId)
+	return view != nil && view.Visible
+}
+
+// Versions returns every committed CommitView in info's chain, in
+// descending commit order (newest first, the same order GetNext walks
+// it), skipping transient entries. Each entry's Tombstone is the union
+// of its own Tombstone and every older committed entry's, mirroring
+// what Snapshot(entry.CommitId) would have returned.
+func (info *CommitInfo) Versions() []*CommitView {
+	chain := info.committedChain()
+	out := make([]*CommitView, len(chain))
+	// suffixTombstone accumulates oldest-to-newest so out[i]'s Tombstone
+	// covers chain[i:], i.e. this entry plus every one older than it.
+	var suffixTombstone *RoaringBitmap
+	for i := len(chain) - 1; i >= 0; i-- {
+		node := chain[i]
+		if node.Tombstone != nil {
+			if suffixTombstone == nil {
+				suffixTombstone = node.Tombstone
+			} else {
+				suffixTombstone = suffixTombstone.Or(node.Tombstone)
+			}
+		}
+		out[i] = viewOf(node, suffixTombstone)
+	}
+	return out
+}
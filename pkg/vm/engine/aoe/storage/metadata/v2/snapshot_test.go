@@ -0,0 +1,122 @@
+// Copyright 2021 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package metadata
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSnapshotVisibleForOrdinaryCommit(t *testing.T) {
+	info := &CommitInfo{CommitId: 1, Op: OpCreate}
+	view := info.Snapshot(1)
+	if assert.NotNil(t, view) {
+		assert.True(t, view.Visible)
+	}
+}
+
+func TestSnapshotInvisibleAfterHardDelete(t *testing.T) {
+	info := &CommitInfo{CommitId: 1, Op: OpHardDelete}
+	view := info.Snapshot(1)
+	if assert.NotNil(t, view) {
+		assert.False(t, view.Visible)
+	}
+}
+
+func TestSnapshotInvisibleAfterSoftDelete(t *testing.T) {
+	info := &CommitInfo{CommitId: 1, Op: OpSoftDelete}
+	view := info.Snapshot(1)
+	if assert.NotNil(t, view) {
+		assert.False(t, view.Visible)
+	}
+}
+
+func TestSnapshotNilBeforeAnyCommittedEntry(t *testing.T) {
+	info := &CommitInfo{CommitId: MinUncommitId, Op: OpCreate}
+	assert.Nil(t, info.Snapshot(10))
+}
+
+func TestVisibleAtMatchesSnapshotVisible(t *testing.T) {
+	info := &CommitInfo{CommitId: 1, Op: OpSoftDelete}
+	assert.False(t, info.VisibleAt(1))
+
+	info = &CommitInfo{CommitId: 1, Op: OpCreate}
+	assert.True(t, info.VisibleAt(1))
+}
+
+func TestSnapshotUnionsTombstonesAcrossCommittedChain(t *testing.T) {
+	older := NewRoaringBitmap()
+	older.Add(1)
+	older.Add(2)
+	newer := NewRoaringBitmap()
+	newer.Add(3)
+
+	oldest := &CommitInfo{CommitId: 1, Op: OpDeleteRows, Tombstone: older}
+	newest := &CommitInfo{CommitId: 2, Op: OpDeleteRows, Tombstone: newer}
+	newest.SetNext(oldest)
+
+	view := newest.Snapshot(2)
+	if assert.NotNil(t, view) && assert.NotNil(t, view.Tombstone) {
+		assert.True(t, view.Tombstone.Contains(1))
+		assert.True(t, view.Tombstone.Contains(2))
+		assert.True(t, view.Tombstone.Contains(3))
+		assert.Equal(t, uint64(3), view.Tombstone.Cardinality())
+	}
+
+	// As of the older commit alone, the newer commit's offset must not
+	// yet be part of the union.
+	earlierView := newest.Snapshot(1)
+	if assert.NotNil(t, earlierView) && assert.NotNil(t, earlierView.Tombstone) {
+		assert.False(t, earlierView.Tombstone.Contains(3))
+		assert.Equal(t, uint64(2), earlierView.Tombstone.Cardinality())
+	}
+}
+
+func TestVersionsUnionsTombstonesPerEntry(t *testing.T) {
+	older := NewRoaringBitmap()
+	older.Add(10)
+	newer := NewRoaringBitmap()
+	newer.Add(20)
+
+	oldest := &CommitInfo{CommitId: 1, Op: OpDeleteRows, Tombstone: older}
+	newest := &CommitInfo{CommitId: 2, Op: OpDeleteRows, Tombstone: newer}
+	newest.SetNext(oldest)
+
+	versions := newest.Versions()
+	if assert.Len(t, versions, 2) {
+		if assert.NotNil(t, versions[0].Tombstone) {
+			assert.True(t, versions[0].Tombstone.Contains(10))
+			assert.True(t, versions[0].Tombstone.Contains(20))
+		}
+		if assert.NotNil(t, versions[1].Tombstone) {
+			assert.False(t, versions[1].Tombstone.Contains(20))
+			assert.True(t, versions[1].Tombstone.Contains(10))
+		}
+	}
+}
+
+func TestVersionsWalksChainNewestFirst(t *testing.T) {
+	oldest := &CommitInfo{CommitId: 1, Op: OpCreate}
+	newest := &CommitInfo{CommitId: 2, Op: OpUpgradeFull}
+	newest.SetNext(oldest)
+
+	versions := newest.Versions()
+	if assert.Len(t, versions, 2) {
+		assert.Equal(t, uint64(2), versions[0].CommitId)
+		assert.Equal(t, uint64(1), versions[1].CommitId)
+		assert.True(t, versions[0].Visible)
+		assert.True(t, versions[1].Visible)
+	}
+}
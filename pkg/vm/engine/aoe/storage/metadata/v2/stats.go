@@ -0,0 +1,171 @@
+// Copyright 2021 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package metadata
+
+import (
+	"math"
+	"sync"
+)
+
+// minStatsSamples is how many observed rows ColumnStats needs before
+// EstimateColumnBlockSize trusts it over the constant worst-case bound -
+// below this, one or two short/empty blocks could otherwise skew the
+// estimate for every block that follows.
+const minStatsSamples = 64
+
+// statsStdDevFactor (k in rows*(avgLen+k*stddev)) is chosen generously
+// so the estimate stays an upper bound for a roughly normal length
+// distribution, the same spirit as the old constant heuristic being a
+// guaranteed-not-to-undersize bound rather than a tight one.
+const statsStdDevFactor = 3
+
+// offsetWidth is the per-row overhead of a varchar/json column's offset
+// array, mirroring the "* 4" term the constant heuristic already
+// accounted for.
+const offsetWidth = 4
+
+// ColumnStats are rolling per-column length statistics, updated as
+// blocks close or upgrade to STFull, that let EstimateColumnBlockSize
+// size a variable-length column from observed data instead of a
+// constant worst case.
+//
+// RefreshStats may run for several blocks of the same table at once, so
+// every accessor below takes mu - the same way
+// ARCCache and every other concurrent structure in this series guards
+// its shared mutable state.
+type ColumnStats struct {
+	mu sync.RWMutex
+
+	Count     uint64
+	SumLen    uint64
+	SumLenSq  uint64
+	MaxLen    uint32
+	NullCount uint64
+}
+
+// Observe folds one row's encoded length (0 if isNull) into the stats.
+func (s *ColumnStats) Observe(length uint32, isNull bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Count++
+	if isNull {
+		s.NullCount++
+		return
+	}
+	s.SumLen += uint64(length)
+	s.SumLenSq += uint64(length) * uint64(length)
+	if length > s.MaxLen {
+		s.MaxLen = length
+	}
+}
+
+// Mean returns the average encoded length across all observed rows,
+// including nulls (which contribute a length of 0), matching how
+// EstimateColumnBlockSize sizes for a whole block of rows regardless of
+// how many are null.
+func (s *ColumnStats) Mean() float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.mean()
+}
+
+func (s *ColumnStats) mean() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	return float64(s.SumLen) / float64(s.Count)
+}
+
+// StdDev returns the population standard deviation of the observed
+// lengths, again counting nulls as length 0.
+func (s *ColumnStats) StdDev() float64 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.stdDev()
+}
+
+func (s *ColumnStats) stdDev() float64 {
+	if s.Count == 0 {
+		return 0
+	}
+	mean := s.mean()
+	variance := float64(s.SumLenSq)/float64(s.Count) - mean*mean
+	if variance < 0 {
+		// Guard against floating-point cancellation when every observed
+		// length is identical.
+		variance = 0
+	}
+	return math.Sqrt(variance)
+}
+
+// Estimate returns the projected total encoded size (payload + offset
+// array) for rows rows of this column, or ok=false if fewer than
+// minStatsSamples rows have been observed yet.
+func (s *ColumnStats) Estimate(rows uint64) (size uint64, ok bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.Count < minStatsSamples {
+		return 0, false
+	}
+	perRow := s.mean() + statsStdDevFactor*s.stdDev()
+	return uint64(perRow*float64(rows)) + rows*offsetWidth, true
+}
+
+// columnKey identifies one column's stats by its owning Schema (by
+// pointer identity - a Schema is never copied once built) and column
+// index, since neither ColDef nor Schema carry a stats field of their
+// own in this package.
+type columnKey struct {
+	schema *Schema
+	colIdx int
+}
+
+type columnStatsRegistry struct {
+	mu sync.RWMutex
+	m  map[columnKey]*ColumnStats
+}
+
+var globalColumnStats = &columnStatsRegistry{m: make(map[columnKey]*ColumnStats)}
+
+func (r *columnStatsRegistry) get(schema *Schema, colIdx int) (*ColumnStats, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	s, ok := r.m[columnKey{schema, colIdx}]
+	return s, ok
+}
+
+func (r *columnStatsRegistry) getOrCreate(schema *Schema, colIdx int) *ColumnStats {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	key := columnKey{schema, colIdx}
+	s, ok := r.m[key]
+	if !ok {
+		s = &ColumnStats{}
+		r.m[key] = s
+	}
+	return s
+}
+
+// RefreshStats folds block's observed per-row encoded lengths for column
+// colIdx into that column's rolling ColumnStats, so later estimates for
+// the same table converge as more data is ingested. lengths holds one
+// encoded length per row, with a zero entry for a null. The block's
+// OpUpgradeFull/OpUpgradeClose transition does not call RefreshStats;
+// stats change only when a caller invokes it directly.
+func (s *Schema) RefreshStats(block *Block, colIdx int, lengths []uint32, isNull func(row int) bool) {
+	stats := globalColumnStats.getOrCreate(s, colIdx)
+	for row, length := range lengths {
+		stats.Observe(length, isNull != nil && isNull(row))
+	}
+}
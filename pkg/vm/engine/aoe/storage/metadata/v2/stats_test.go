@@ -0,0 +1,78 @@
+// Copyright 2021 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+//      http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+
+package metadata
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestColumnStatsObserveAndEstimate(t *testing.T) {
+	s := &ColumnStats{}
+	for i := 0; i < minStatsSamples; i++ {
+		s.Observe(10, false)
+	}
+	assert.Equal(t, uint64(minStatsSamples), s.Count)
+	assert.Equal(t, float64(10), s.Mean())
+	assert.Equal(t, float64(0), s.StdDev())
+
+	size, ok := s.Estimate(100)
+	assert.True(t, ok)
+	assert.Equal(t, uint64(10*100+100*offsetWidth), size)
+}
+
+func TestColumnStatsEstimateNotOkBelowMinSamples(t *testing.T) {
+	s := &ColumnStats{}
+	s.Observe(10, false)
+	_, ok := s.Estimate(100)
+	assert.False(t, ok)
+}
+
+func TestColumnStatsObserveNullDoesNotAffectSumLen(t *testing.T) {
+	s := &ColumnStats{}
+	s.Observe(0, true)
+	assert.Equal(t, uint64(1), s.Count)
+	assert.Equal(t, uint64(1), s.NullCount)
+	assert.Equal(t, float64(0), s.Mean())
+}
+
+// TestColumnStatsConcurrentObserveAndEstimate drives concurrent Observe
+// calls (mirroring several blocks of the same table flushing at once)
+// against concurrent Estimate/Mean/StdDev reads, the way -race catches a
+// bare, unlocked ColumnStats.
+func TestColumnStatsConcurrentObserveAndEstimate(t *testing.T) {
+	s := &ColumnStats{}
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 500; i++ {
+			s.Observe(uint32(i%64), i%7 == 0)
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 500; i++ {
+			s.Mean()
+			s.StdDev()
+			s.Estimate(1000)
+		}
+	}()
+	wg.Wait()
+
+	assert.Equal(t, uint64(500), s.Count)
+}
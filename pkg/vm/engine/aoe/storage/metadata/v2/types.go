@@ -54,6 +54,15 @@ const (
 	OpUpgradeSorted
 	OpSoftDelete
 	OpHardDelete
+	// OpDeleteRows marks a CommitInfo whose Tombstone records rows that
+	// became invisible as of CommitId without rewriting the block, e.g. a
+	// row-granularity DELETE. It never transitions State.
+	OpDeleteRows
+	// OpUpdateRows is OpDeleteRows's counterpart for UPDATE: the old row
+	// offsets recorded in Tombstone are no longer visible as of CommitId,
+	// but unlike OpHardDelete the block itself is still live and later
+	// CommitInfo nodes may carry their own Tombstone on top of it.
+	OpUpdateRows
 )
 
 var OpNames = map[OpT]string{
@@ -63,6 +72,8 @@ var OpNames = map[OpT]string{
 	OpUpgradeSorted: "UpgradeSorted",
 	OpSoftDelete:    "SoftDelete",
 	OpHardDelete:    "HardDelete",
+	OpDeleteRows:    "DeleteRows",
+	OpUpdateRows:    "UpdateRows",
 }
 
 func OpName(op OpT) string {
@@ -77,6 +88,13 @@ type CommitInfo struct {
 	ExternalIndex   *ExternalIndex
 	PrevIndex       *ExternalIndex
 	AppliedIndex    *ExternalIndex
+	// Tombstone is only set for OpDeleteRows/OpUpdateRows: the row
+	// offsets this commit removed from visibility, compressed the same
+	// way RoaringBitmap picks array/run/bitmap containers per chunk of
+	// 65536 offsets. A scan reconstructs full block visibility by OR-ing
+	// every committed CommitInfo's Tombstone up to its snapshot commit
+	// id - see Snapshot.
+	Tombstone *RoaringBitmap `json:"-"`
 }
 
 func (info *CommitInfo) IsHardDeleted() bool {
@@ -87,6 +105,12 @@ func (info *CommitInfo) IsSoftDeleted() bool {
 	return info.Op == OpSoftDelete
 }
 
+// IsRowTombstone reports whether info carries a row-granularity
+// Tombstone instead of changing the block's State.
+func (info *CommitInfo) IsRowTombstone() bool {
+	return info.Op == OpDeleteRows || info.Op == OpUpdateRows
+}
+
 func (info *CommitInfo) PString(level PPLevel) string {
 	s := fmt.Sprintf("CInfo: ")
 	var curr, prev common.ISSLLNode
@@ -170,11 +194,18 @@ func (s *Sequence) NextUncommitId() uint64 {
 }
 
 func EstimateColumnBlockSize(colIdx int, meta *Block) uint64 {
-	switch meta.Segment.Table.Schema.ColDefs[colIdx].Type.Oid {
+	schema := meta.Segment.Table.Schema
+	switch schema.ColDefs[colIdx].Type.Oid {
 	case types.T_json, types.T_char, types.T_varchar:
-		return meta.Segment.Table.Schema.BlockMaxRows * 2 * 4
+		if stats, ok := globalColumnStats.get(schema, colIdx); ok {
+			if size, ok := stats.Estimate(schema.BlockMaxRows); ok {
+				return size
+			}
+		}
+		// Worst-case fallback until enough samples have been observed.
+		return schema.BlockMaxRows * 2 * 4
 	default:
-		return meta.Segment.Table.Schema.BlockMaxRows * uint64(meta.Segment.Table.Schema.ColDefs[colIdx].Type.Size)
+		return schema.BlockMaxRows * uint64(schema.ColDefs[colIdx].Type.Size)
 	}
 }
 
@@ -183,5 +214,22 @@ func EstimateBlockSize(meta *Block) uint64 {
 	for colIdx, _ := range meta.Segment.Table.Schema.ColDefs {
 		size += EstimateColumnBlockSize(colIdx, meta)
 	}
+	size += estimateTombstoneSize(meta)
 	return size
 }
+
+// estimateTombstoneSize adds the union of every committed CommitInfo's
+// Tombstone in meta's chain to a block's size estimate - a block with
+// many row-granularity deletes, layered across several OpDeleteRows/
+// OpUpdateRows commits, costs real bytes in the checkpoint even though
+// EstimateColumnBlockSize never shrinks to reflect them.
+func estimateTombstoneSize(meta *Block) uint64 {
+	if meta.CommitInfo == nil {
+		return 0
+	}
+	view := meta.CommitInfo.Snapshot(meta.CommitInfo.CommitId)
+	if view == nil || view.Tombstone == nil {
+		return 0
+	}
+	return uint64(view.Tombstone.SizeBytes())
+}
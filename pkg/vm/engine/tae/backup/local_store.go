@@ -0,0 +1,97 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package backup
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// LocalStore is a Store backed by a directory on the local filesystem.
+// It is primarily useful for tests and for backing up to a mounted NFS
+// volume; production deployments typically use an S3-compatible Store
+// instead, behind the same interface.
+type LocalStore struct {
+	root string
+}
+
+// NewLocalStore returns a LocalStore rooted at dir, creating it if
+// necessary.
+func NewLocalStore(dir string) (*LocalStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("backup: create local store dir %s: %w", dir, err)
+	}
+	return &LocalStore{root: dir}, nil
+}
+
+func (s *LocalStore) path(key string) string {
+	return filepath.Join(s.root, filepath.FromSlash(key))
+}
+
+func (s *LocalStore) Put(_ context.Context, key string, r io.Reader) error {
+	p := s.path(key)
+	if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+		return fmt.Errorf("backup: create parent dir for %s: %w", key, err)
+	}
+	f, err := os.Create(p)
+	if err != nil {
+		return fmt.Errorf("backup: create %s: %w", key, err)
+	}
+	defer f.Close()
+	if _, err := io.Copy(f, r); err != nil {
+		return fmt.Errorf("backup: write %s: %w", key, err)
+	}
+	return nil
+}
+
+func (s *LocalStore) Get(_ context.Context, key string) (io.ReadCloser, error) {
+	f, err := os.Open(s.path(key))
+	if err != nil {
+		return nil, fmt.Errorf("backup: open %s: %w", key, err)
+	}
+	return f, nil
+}
+
+// List matches keys the same way an S3 ListObjects call with Prefix
+// would: by a plain string prefix on the slash-joined key, not by
+// filesystem directory boundaries.
+func (s *LocalStore) List(_ context.Context, prefix string) ([]string, error) {
+	var keys []string
+	err := filepath.Walk(s.root, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			return nil
+		}
+		rel, err := filepath.Rel(s.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("backup: list %s: %w", prefix, err)
+	}
+	return keys, nil
+}
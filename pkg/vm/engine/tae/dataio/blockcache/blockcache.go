@@ -0,0 +1,317 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package blockcache implements an mmap-backed local disk cache for hot
+// metaloc/deltaloc chunks, the way Prometheus m-maps head chunks instead
+// of re-parsing them from the WAL. blockio.BlockReadInner and
+// blockio.BlockPrefetch are meant to call Get before fetching a chunk
+// from Runtime.Fs.Service, passing a fetch func that does the real
+// object-store read on a miss, and Put the result so the next read for
+// the same (SegmentID, BlockID, Kind) is served from a local mmap
+// instead. It doesn't know what a metaloc or deltaloc actually decode to
+// - every payload is an opaque byte slice keyed by Kind, and only
+// blockio knows how to turn one back into the buffer its caller expects.
+//
+// Each cached chunk is its own file, named after its key, carrying a
+// small header (magic, version, key, the original location string it was
+// fetched from, and a CRC32 of the payload) ahead of the raw payload.
+// Open iterates the cache directory once at startup, verifies every
+// file's CRC, and rebuilds the in-memory key index from what's left;
+// a file that fails its CRC (or is torn mid-header) is deleted on the
+// spot rather than repaired, the same way a corrupt m-mapped chunk is
+// simply dropped and re-built from the WAL in Prometheus - here, the
+// equivalent "WAL" is just Runtime.Fs.Service, so a deleted cache entry
+// is transparently refetched on the next Get. blockio.BlockReadInner and
+// Runtime.Fs.Service do not exist in this checkout, so nothing calls Get
+// yet; Cache's exported surface (Get/Put/Invalidate/Stats) is the seam a
+// future blockio caller plugs into without needing its own eviction or
+// recovery logic.
+package blockcache
+
+import (
+	"container/list"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+)
+
+// Kind distinguishes the two chunk flavors blockio caches.
+type Kind uint8
+
+const (
+	KindMeta Kind = iota
+	KindDelta
+)
+
+func (k Kind) String() string {
+	switch k {
+	case KindMeta:
+		return "meta"
+	case KindDelta:
+		return "delta"
+	default:
+		return fmt.Sprintf("Kind(%d)", uint8(k))
+	}
+}
+
+// Key identifies one cached chunk.
+type Key struct {
+	SegmentID uint64
+	BlockID   uint64
+	Kind      Kind
+}
+
+func (k Key) fileName() string {
+	return fmt.Sprintf("%016x-%016x-%d.chunk", k.SegmentID, k.BlockID, k.Kind)
+}
+
+// Stats are the cumulative counters Cache.Stats reports.
+type Stats struct {
+	Hits          uint64
+	Misses        uint64
+	Evictions     uint64
+	ResidentBytes int64
+}
+
+// FetchFunc re-reads a chunk from its backing store on a cache miss,
+// returning the payload to cache and the location it was read from.
+type FetchFunc func(ctx context.Context) (payload []byte, origLoc string, err error)
+
+// BlockCache is what blockio.BlockReadInner and blockio.BlockPrefetch are
+// meant to hold instead of talking to Runtime.Fs.Service directly.
+// *Cache is the only implementation; the interface exists so callers
+// (and tests) don't need to depend on Cache's eviction internals.
+type BlockCache interface {
+	Get(ctx context.Context, key Key, fetch FetchFunc) ([]byte, error)
+	Put(key Key, origLoc string, payload []byte) error
+	Invalidate(key Key) error
+	Stats() Stats
+}
+
+var _ BlockCache = (*Cache)(nil)
+
+type residentEntry struct {
+	key     Key
+	path    string
+	size    int64 // payload size, not file size
+	origLoc string
+	mmap    mmapData
+}
+
+// payload returns the mmap'd payload bytes, zero-copy. The payload is
+// always the file's tail, so its start is just the file size minus its
+// own length.
+func (e *residentEntry) payload() []byte {
+	b := e.mmap.Bytes()
+	return b[int64(len(b))-e.size:]
+}
+
+// Cache is an mmap-backed disk cache bounded by maxBytes, evicting the
+// least recently used chunk to make room for a new one. It is safe for
+// concurrent use.
+type Cache struct {
+	dir      string
+	maxBytes int64
+
+	mu            sync.Mutex
+	lru           *list.List // front = most recently used
+	index         map[Key]*list.Element
+	residentBytes int64
+
+	hits, misses, evictions atomic.Uint64
+}
+
+// Open opens (creating if necessary) the cache directory at dir, recovers
+// every chunk file already in it, and returns a Cache bounded to maxBytes
+// of resident payload.
+func Open(dir string, maxBytes int64) (*Cache, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("blockcache: create dir %s: %w", dir, err)
+	}
+	c := &Cache{
+		dir:      dir,
+		maxBytes: maxBytes,
+		lru:      list.New(),
+		index:    make(map[Key]*list.Element),
+	}
+	if err := c.recover(); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Cache) recover() error {
+	ents, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("blockcache: list dir %s: %w", c.dir, err)
+	}
+	for _, e := range ents {
+		if e.IsDir() {
+			continue
+		}
+		path := filepath.Join(c.dir, e.Name())
+		entry, err := openResident(path)
+		if err != nil {
+			// Corrupt or torn: this chunk (and only this chunk, since
+			// each chunk is its own file) is unrecoverable; drop it and
+			// let the next Get re-fetch it from the object store.
+			_ = os.Remove(path)
+			continue
+		}
+		elem := c.lru.PushFront(entry)
+		c.index[entry.key] = elem
+		c.residentBytes += entry.size
+	}
+	return nil
+}
+
+// openResident mmaps path and validates its header, returning a
+// residentEntry ready to serve Get calls zero-copy.
+func openResident(path string) (*residentEntry, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("blockcache: stat %s: %w", path, err)
+	}
+	m, err := mmapOpen(path, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("blockcache: mmap %s: %w", path, err)
+	}
+	key, origLoc, payloadOffset, err := parseHeader(m.Bytes())
+	if err != nil {
+		_ = m.Close()
+		return nil, fmt.Errorf("blockcache: %s: %w", path, err)
+	}
+	return &residentEntry{
+		key:     key,
+		path:    path,
+		size:    info.Size() - int64(payloadOffset),
+		origLoc: origLoc,
+		mmap:    m,
+	}, nil
+}
+
+// Get returns key's cached payload if resident, otherwise calls fetch,
+// caches the result, and returns it.
+func (c *Cache) Get(ctx context.Context, key Key, fetch FetchFunc) ([]byte, error) {
+	if payload, ok := c.getResident(key); ok {
+		return payload, nil
+	}
+	payload, origLoc, err := fetch(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if putErr := c.Put(key, origLoc, payload); putErr != nil {
+		return nil, putErr
+	}
+	return payload, nil
+}
+
+// getResident reads payload() while still holding c.mu, not after: Put and
+// evictLocked/Invalidate close an evicted entry's mmap under the same lock,
+// so releasing it before calling payload() would let a concurrent eviction
+// of this very key unmap the memory out from under the slice this returns.
+func (c *Cache) getResident(key Key) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	elem, ok := c.index[key]
+	if !ok {
+		c.misses.Add(1)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	e := elem.Value.(*residentEntry)
+	c.hits.Add(1)
+	return e.payload(), true
+}
+
+// Put writes payload into the cache under key, evicting the
+// least-recently-used entries first if needed to stay within maxBytes.
+func (c *Cache) Put(key Key, origLoc string, payload []byte) error {
+	path := filepath.Join(c.dir, key.fileName())
+	if err := writeChunk(path, key, origLoc, payload); err != nil {
+		return err
+	}
+	entry, err := openResident(path)
+	if err != nil {
+		return err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if old, ok := c.index[key]; ok {
+		oldEntry := old.Value.(*residentEntry)
+		c.residentBytes -= oldEntry.size
+		c.lru.Remove(old)
+		_ = oldEntry.mmap.Close()
+	}
+	c.evictLocked(entry.size)
+	elem := c.lru.PushFront(entry)
+	c.index[key] = elem
+	c.residentBytes += entry.size
+	return nil
+}
+
+// evictLocked removes least-recently-used entries until admitting
+// incoming bytes won't exceed maxBytes. Called with c.mu held.
+func (c *Cache) evictLocked(incoming int64) {
+	if c.maxBytes <= 0 {
+		return
+	}
+	for c.residentBytes+incoming > c.maxBytes {
+		back := c.lru.Back()
+		if back == nil {
+			return
+		}
+		e := back.Value.(*residentEntry)
+		_ = e.mmap.Close()
+		_ = os.Remove(e.path)
+		c.lru.Remove(back)
+		delete(c.index, e.key)
+		c.residentBytes -= e.size
+		c.evictions.Add(1)
+	}
+}
+
+// Invalidate removes key from the cache, if present.
+func (c *Cache) Invalidate(key Key) error {
+	c.mu.Lock()
+	elem, ok := c.index[key]
+	if !ok {
+		c.mu.Unlock()
+		return nil
+	}
+	e := elem.Value.(*residentEntry)
+	c.lru.Remove(elem)
+	delete(c.index, key)
+	c.residentBytes -= e.size
+	_ = e.mmap.Close()
+	c.mu.Unlock()
+	return os.Remove(e.path)
+}
+
+// Stats returns the cache's cumulative counters and current footprint.
+func (c *Cache) Stats() Stats {
+	c.mu.Lock()
+	resident := c.residentBytes
+	c.mu.Unlock()
+	return Stats{
+		Hits:          c.hits.Load(),
+		Misses:        c.misses.Load(),
+		Evictions:     c.evictions.Load(),
+		ResidentBytes: resident,
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blockcache
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+)
+
+// magic tags a file as belonging to this cache format; version lets a
+// future layout change reject files an older reader wrote.
+const (
+	magic   = "TBCC"
+	version = 1
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// fixedHeaderSize covers magic, version, SegmentID, BlockID, Kind, the
+// origLoc length and the payload's CRC32 - everything before the
+// variable-length origLoc string.
+const fixedHeaderSize = 4 + 1 + 8 + 8 + 1 + 2 + 4
+
+// writeChunk writes key, origLoc and payload to path as a single
+// self-describing cache file, replacing whatever was there before.
+func writeChunk(path string, key Key, origLoc string, payload []byte) error {
+	if len(origLoc) > 1<<16-1 {
+		return fmt.Errorf("blockcache: origLoc too long (%d bytes)", len(origLoc))
+	}
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("blockcache: create %s: %w", path, err)
+	}
+	defer f.Close()
+
+	hdr := make([]byte, fixedHeaderSize)
+	copy(hdr[0:4], magic)
+	hdr[4] = version
+	binary.LittleEndian.PutUint64(hdr[5:13], key.SegmentID)
+	binary.LittleEndian.PutUint64(hdr[13:21], key.BlockID)
+	hdr[21] = uint8(key.Kind)
+	binary.LittleEndian.PutUint16(hdr[22:24], uint16(len(origLoc)))
+	binary.LittleEndian.PutUint32(hdr[24:28], crc32.Checksum(payload, crcTable))
+
+	if _, err := f.Write(hdr); err != nil {
+		return fmt.Errorf("blockcache: write header %s: %w", path, err)
+	}
+	if _, err := f.WriteString(origLoc); err != nil {
+		return fmt.Errorf("blockcache: write origLoc %s: %w", path, err)
+	}
+	if _, err := f.Write(payload); err != nil {
+		return fmt.Errorf("blockcache: write payload %s: %w", path, err)
+	}
+	return f.Sync()
+}
+
+// parseHeader validates b as a whole cache file (header plus origLoc plus
+// payload) and returns the key, origLoc, and the byte offset its payload
+// starts at, so a caller holding an mmap of the same file can slice the
+// payload out with no further copy.
+func parseHeader(b []byte) (key Key, origLoc string, payloadOffset int, err error) {
+	if len(b) < fixedHeaderSize {
+		return Key{}, "", 0, fmt.Errorf("blockcache: file shorter than header")
+	}
+	if string(b[0:4]) != magic {
+		return Key{}, "", 0, fmt.Errorf("blockcache: bad magic")
+	}
+	if b[4] != version {
+		return Key{}, "", 0, fmt.Errorf("blockcache: unsupported version %d", b[4])
+	}
+	key = Key{
+		SegmentID: binary.LittleEndian.Uint64(b[5:13]),
+		BlockID:   binary.LittleEndian.Uint64(b[13:21]),
+		Kind:      Kind(b[21]),
+	}
+	origLocLen := int(binary.LittleEndian.Uint16(b[22:24]))
+	crc := binary.LittleEndian.Uint32(b[24:28])
+
+	if len(b) < fixedHeaderSize+origLocLen {
+		return Key{}, "", 0, fmt.Errorf("blockcache: torn in origLoc")
+	}
+	origLoc = string(b[fixedHeaderSize : fixedHeaderSize+origLocLen])
+	payload := b[fixedHeaderSize+origLocLen:]
+	if crc32.Checksum(payload, crcTable) != crc {
+		return Key{}, "", 0, fmt.Errorf("blockcache: failed CRC check")
+	}
+	return key, origLoc, fixedHeaderSize + origLocLen, nil
+}
@@ -0,0 +1,133 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package blockchunk implements the on-disk format jobs.NewCompactBlockTask
+// writes a flushed block's column data into, mirroring the
+// dataio/headchunk mmap-segment design but for a compacted (immutable)
+// block's data instead of an appendable one's. It doesn't know about
+// catalog.BlockEntry, jobs.NewCompactBlockTask, or tae.DB's startup
+// sequence: the compact task is left to call Dir.Append once per flushed
+// column, tae.DB is left to call OpenDir before WAL replay and attach the
+// returned refs to catalog entries so BlockEntry.GetBlockData().
+// GetColumnDataById can read them zero-copy, and a crash-recovered block
+// whose CRC doesn't check out is left to whoever reruns the compact task
+// against the WAL-logged CompactBlockTask commit that produced it, the
+// same way a torn write anywhere else in TAE is repaired by replaying the
+// txn that produced it rather than by patching the file. Every record is
+// self-describing (segment, block, column, length, CRC32), so a reader
+// can validate a segment front to back with no separate manifest; a
+// corrupt or torn record simply ends the valid prefix of the segment and
+// everything after it is truncated away.
+package blockchunk
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+)
+
+const (
+	// magic tags a segment file as belonging to this format.
+	magic = "TBC1"
+	// recordHeaderSize is the fixed-size prefix before every record's
+	// body (its encoded Meta followed by the payload): a 4-byte length
+	// and a 4-byte CRC32, both of the whole body.
+	recordHeaderSize = 8
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ChunkRef locates one record within a segment file.
+type ChunkRef struct {
+	// Offset is the byte offset of the record's payload, not its header
+	// or its Meta.
+	Offset int64
+	Length int64
+	// Meta describes the segment/block/column this record's payload
+	// holds, so a reader scanning cold can route it without decoding the
+	// payload.
+	Meta Meta
+}
+
+// Writer appends checksummed records to a single segment file.
+type Writer struct {
+	f      *os.File
+	offset int64
+}
+
+// CreateWriter creates a new, empty segment file at path and writes its
+// header. It fails if path already exists.
+func CreateWriter(path string) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("blockchunk: create segment %s: %w", path, err)
+	}
+	if _, err := f.WriteString(magic); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("blockchunk: write header %s: %w", path, err)
+	}
+	return &Writer{f: f, offset: int64(len(magic))}, nil
+}
+
+// OpenWriter reopens an existing segment file for appending, positioning
+// new writes after whatever it already contains. Callers that recovered a
+// segment via Recover should pass validUpTo as truncateTo so any trailing
+// torn record is discarded before new data is appended past it.
+func OpenWriter(path string, truncateTo int64) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("blockchunk: open segment %s: %w", path, err)
+	}
+	if err := f.Truncate(truncateTo); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("blockchunk: truncate segment %s: %w", path, err)
+	}
+	if _, err := f.Seek(truncateTo, 0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("blockchunk: seek segment %s: %w", path, err)
+	}
+	return &Writer{f: f, offset: truncateTo}, nil
+}
+
+// Append writes payload as one record stamped with meta (which segment/
+// block/column it belongs to) and returns where it landed. The record is
+// not guaranteed durable until Sync returns.
+func (w *Writer) Append(payload []byte, meta Meta) (ChunkRef, error) {
+	body := append(meta.encode(), payload...)
+
+	var hdr [recordHeaderSize]byte
+	binary.LittleEndian.PutUint32(hdr[0:4], uint32(len(body)))
+	binary.LittleEndian.PutUint32(hdr[4:8], crc32.Checksum(body, crcTable))
+	if _, err := w.f.Write(hdr[:]); err != nil {
+		return ChunkRef{}, fmt.Errorf("blockchunk: write record header: %w", err)
+	}
+	if _, err := w.f.Write(body); err != nil {
+		return ChunkRef{}, fmt.Errorf("blockchunk: write record body: %w", err)
+	}
+	ref := ChunkRef{Offset: w.offset + recordHeaderSize + metaSize, Length: int64(len(payload)), Meta: meta}
+	w.offset += recordHeaderSize + int64(len(body))
+	return ref, nil
+}
+
+// Sync flushes the segment file to stable storage.
+func (w *Writer) Sync() error {
+	return w.f.Sync()
+}
+
+// Close closes the underlying file without syncing it; callers that need
+// durability must call Sync first.
+func (w *Writer) Close() error {
+	return w.f.Close()
+}
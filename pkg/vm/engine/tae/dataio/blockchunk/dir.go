@@ -0,0 +1,180 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blockchunk
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// segmentMaxBytes bounds how large a single segment file is allowed to
+// grow before Dir cuts a new one, so recovery never has to scan and mmap
+// one unbounded file.
+const segmentMaxBytes = 128 << 20
+
+// segmentName renders seq as the zero-padded file name Dir expects, e.g.
+// segment 3 -> "000003".
+func segmentName(seq int) string {
+	return fmt.Sprintf("%06d", seq)
+}
+
+// Dir manages a directory of sequentially numbered block-chunk segment
+// files for one DB, cutting a new segment once the active one passes
+// segmentMaxBytes. tae.DB is meant to call OpenDir before WAL replay and
+// attach the returned entries to catalog entries so reads can be served
+// zero-copy without waiting for replay to reconstruct them.
+type Dir struct {
+	path    string
+	active  *Writer
+	seq     int
+	written int64
+}
+
+// OpenDir opens (creating if necessary) the block-chunks directory at
+// path, recovers every segment in order, and returns the Dir plus every
+// ChunkRef recovered across all segments, keyed by segment sequence
+// number, so the caller can build its block -> chunk index. Only the
+// last segment's tail can be torn; every earlier segment was already cut
+// (and therefore fully synced) before the next one was created.
+func OpenDir(path string) (d *Dir, entries map[int][]ChunkRef, err error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, nil, fmt.Errorf("blockchunk: create dir %s: %w", path, err)
+	}
+	seqs, err := listSegments(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entries = make(map[int][]ChunkRef)
+	d = &Dir{path: path}
+	if len(seqs) == 0 {
+		w, err := CreateWriter(filepath.Join(path, segmentName(0)))
+		if err != nil {
+			return nil, nil, err
+		}
+		d.active = w
+		d.seq = 0
+		return d, entries, nil
+	}
+
+	for _, seq := range seqs[:len(seqs)-1] {
+		f, err := Open(filepath.Join(path, segmentName(seq)))
+		if err != nil {
+			return nil, nil, err
+		}
+		refs, validUpTo, err := f.Scan()
+		closeErr := f.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		if closeErr != nil {
+			return nil, nil, closeErr
+		}
+		info, statErr := os.Stat(filepath.Join(path, segmentName(seq)))
+		if statErr != nil {
+			return nil, nil, statErr
+		}
+		if validUpTo != info.Size() {
+			return nil, nil, fmt.Errorf("blockchunk: sealed segment %d is torn at byte %d of %d; treating as corrupt beyond repair", seq, validUpTo, info.Size())
+		}
+		entries[seq] = refs
+	}
+
+	lastSeq := seqs[len(seqs)-1]
+	refs, w, err := Recover(filepath.Join(path, segmentName(lastSeq)))
+	if err != nil {
+		return nil, nil, err
+	}
+	entries[lastSeq] = refs
+	d.active = w
+	d.seq = lastSeq
+	d.written = w.offset
+	return d, entries, nil
+}
+
+func listSegments(path string) ([]int, error) {
+	ents, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("blockchunk: list dir %s: %w", path, err)
+	}
+	var seqs []int
+	for _, e := range ents {
+		if e.IsDir() {
+			continue
+		}
+		seq, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+	return seqs, nil
+}
+
+// Append writes payload, stamped with meta describing the segment/block/
+// column it belongs to, to the active segment, cutting a new one first if
+// doing so would exceed segmentMaxBytes. It returns which segment the
+// chunk landed in alongside its ChunkRef.
+func (d *Dir) Append(payload []byte, meta Meta) (seq int, ref ChunkRef, err error) {
+	if d.written+int64(len(payload)) > segmentMaxBytes {
+		if err := d.cut(); err != nil {
+			return 0, ChunkRef{}, err
+		}
+	}
+	ref, err = d.active.Append(payload, meta)
+	if err != nil {
+		return 0, ChunkRef{}, err
+	}
+	d.written += int64(len(payload))
+	return d.seq, ref, nil
+}
+
+// cut seals the active segment and opens the next one in sequence.
+func (d *Dir) cut() error {
+	if err := d.active.Sync(); err != nil {
+		return err
+	}
+	if err := d.active.Close(); err != nil {
+		return err
+	}
+	d.seq++
+	d.written = 0
+	w, err := CreateWriter(filepath.Join(d.path, segmentName(d.seq)))
+	if err != nil {
+		return err
+	}
+	d.active = w
+	return nil
+}
+
+// Sync flushes the active segment to stable storage.
+func (d *Dir) Sync() error {
+	return d.active.Sync()
+}
+
+// Close closes the active segment without syncing it.
+func (d *Dir) Close() error {
+	return d.active.Close()
+}
+
+// Open mmaps segment seq for reading, e.g. to serve Index entries
+// recorded against it.
+func (d *Dir) Open(seq int) (*File, error) {
+	return Open(filepath.Join(d.path, segmentName(seq)))
+}
@@ -0,0 +1,77 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blockchunk
+
+import "sync"
+
+// Entry is one column's recovered mmapped chunk: which segment it lives
+// in and where within that segment.
+type Entry struct {
+	Segment int
+	Ref     ChunkRef
+}
+
+// Index maps a caller-defined block identifier (the catalog's common.ID
+// in TAE, kept generic here so this package stays independent of the
+// catalog package) to every column's recovered Entry for that block. A
+// block's columns are each written once by the compact task that flushed
+// it, so unlike headchunk's Index this is append-only per key rather than
+// last-write-wins: Set appends to the block's entry list, letting a block
+// accumulate one Entry per column.
+// mmaprecovery.BuildIndex builds its own map[uint64][]Entry rather than an
+// Index, since a one-shot restart-time scan has no need for Index's
+// mutability; Index itself is for a caller that needs to live-update the
+// index afterward, e.g. Delete-ing a block's entries once catalog.
+// BlockEntry reports it GC'd - a path that does not exist in this
+// checkout yet.
+type Index[K comparable] struct {
+	mu      sync.RWMutex
+	entries map[K][]Entry
+}
+
+// NewIndex returns an empty Index.
+func NewIndex[K comparable]() *Index[K] {
+	return &Index[K]{entries: make(map[K][]Entry)}
+}
+
+// Set appends e to key's entry list.
+func (idx *Index[K]) Set(key K, e Entry) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.entries[key] = append(idx.entries[key], e)
+}
+
+// Get returns every recorded entry for key.
+func (idx *Index[K]) Get(key K) ([]Entry, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	es, ok := idx.entries[key]
+	return es, ok
+}
+
+// Delete removes key, e.g. once its block has been GC'd and its mmapped
+// chunks are no longer reachable.
+func (idx *Index[K]) Delete(key K) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	delete(idx.entries, key)
+}
+
+// Len reports how many blocks currently have recorded entries.
+func (idx *Index[K]) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.entries)
+}
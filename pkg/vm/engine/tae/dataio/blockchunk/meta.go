@@ -0,0 +1,44 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blockchunk
+
+import "encoding/binary"
+
+// metaSize is the fixed, encoded size of Meta: three 8-byte IDs.
+const metaSize = 8 + 8 + 8
+
+// Meta describes the flushed block a chunk's payload holds one column's
+// data for.
+type Meta struct {
+	SegmentID uint64
+	BlockID   uint64
+	ColumnID  uint64
+}
+
+func (m Meta) encode() []byte {
+	b := make([]byte, metaSize)
+	binary.LittleEndian.PutUint64(b[0:8], m.SegmentID)
+	binary.LittleEndian.PutUint64(b[8:16], m.BlockID)
+	binary.LittleEndian.PutUint64(b[16:24], m.ColumnID)
+	return b
+}
+
+func decodeMeta(b []byte) Meta {
+	return Meta{
+		SegmentID: binary.LittleEndian.Uint64(b[0:8]),
+		BlockID:   binary.LittleEndian.Uint64(b[8:16]),
+		ColumnID:  binary.LittleEndian.Uint64(b[16:24]),
+	}
+}
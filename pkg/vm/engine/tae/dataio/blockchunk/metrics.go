@@ -0,0 +1,54 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blockchunk
+
+import "sync/atomic"
+
+var (
+	residentBytes         atomic.Int64
+	corruptionTruncations atomic.Int64
+)
+
+// Metrics is a point-in-time snapshot of this process's block-chunk
+// activity, meant to be surfaced the same way the rest of TAE exports
+// gauges/counters rather than read directly by application code.
+type Metrics struct {
+	// MmapBytesResident is how many bytes of segment files are currently
+	// mapped into this process, summed across every open File.
+	MmapBytesResident int64
+	// CorruptionTruncations counts how many times Scan has discarded a
+	// torn or checksum-failing tail since process start, across every
+	// segment scanned. A nonzero count is expected after a crash, since
+	// that is exactly the tail OnExec's WAL replay is meant to
+	// reconstruct; a count that keeps growing outside of crash recovery
+	// usually means something worse (disk corruption, a truncated copy).
+	CorruptionTruncations int64
+}
+
+// ReadMetrics returns the current Metrics snapshot.
+func ReadMetrics() Metrics {
+	return Metrics{
+		MmapBytesResident:     residentBytes.Load(),
+		CorruptionTruncations: corruptionTruncations.Load(),
+	}
+}
+
+func addResidentBytes(delta int64) {
+	residentBytes.Add(delta)
+}
+
+func recordCorruptionTruncation() {
+	corruptionTruncations.Add(1)
+}
@@ -0,0 +1,200 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package blockreader implements the Build/Next/Close/CollectTombstones
+// split MatrixOne's sharding service already uses, as a replacement for
+// pulling a whole block into one batch up front the way
+// blockio.BlockReadInner does today. BlockReader chunks its output by a
+// caller-supplied row count instead of materializing every row, and
+// applies CollectTombstones' deletes lazily per chunk rather than up
+// front, so a caller only pays for the rows it actually asked for.
+//
+// It doesn't know what a column actually decodes to, or how deletes are
+// represented on disk: Source and TombstoneSource are the seams blockio
+// is meant to implement against *objectio.BlockReader and
+// catalog.BlockEntry.GetBlockData().CollectChangesInRange, and
+// blockio.BlockReadInner is meant to become a thin wrapper that drains
+// Next until io.EOF for callers that still want one batch back.
+//
+// A BlockReader's position is just a row offset, so Handle lets it be
+// serialized into a small blob and handed to ResumeBlockReader on another
+// goroutine or CN shard to pick up exactly where Close left off, without
+// re-reading rows already delivered.
+package blockreader
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+
+	"github.com/RoaringBitmap/roaring"
+)
+
+// Source decodes the rows of one block, a range at a time. Row is left
+// generic because this package doesn't know whether a caller wants
+// containers.Batch, a CN-side batch.Batch, or something else entirely.
+type Source[Row any] interface {
+	// Len is the total row count in the block, independent of deletes.
+	Len() int
+	// ReadRange decodes rows [start, end) in row order.
+	ReadRange(ctx context.Context, start, end int) ([]Row, error)
+}
+
+// TombstoneSource answers which row offsets in the block are logically
+// deleted as of whatever snapshot the caller built the reader against.
+type TombstoneSource interface {
+	CollectTombstones(ctx context.Context) (*roaring.Bitmap, error)
+}
+
+// Handle is a BlockReader's position, small and serializable enough to
+// cross a goroutine or RPC boundary so a different shard can resume
+// reading where another one left off.
+type Handle struct {
+	RowOffset int
+}
+
+// BlockReader streams a block's rows in maxRowsPerBatch-sized chunks,
+// filtering out deleted rows as it goes. It is not safe for concurrent
+// use by multiple goroutines at once, but its Handle can be handed to
+// ResumeBlockReader on a different goroutine once this one is done with
+// it.
+type BlockReader[Row any] struct {
+	src             Source[Row]
+	tomb            TombstoneSource
+	maxRowsPerBatch int
+
+	rowOffset  int
+	tombstones *roaring.Bitmap
+	closed     bool
+}
+
+// BuildBlockReader starts a new BlockReader at row 0.
+func BuildBlockReader[Row any](ctx context.Context, src Source[Row], tomb TombstoneSource, maxRowsPerBatch int) (*BlockReader[Row], error) {
+	return ResumeBlockReader(ctx, src, tomb, maxRowsPerBatch, Handle{})
+}
+
+// ResumeBlockReader starts a BlockReader at the row offset a prior
+// reader's Handle recorded, e.g. after that reader was Close'd and its
+// Handle shipped to another shard.
+func ResumeBlockReader[Row any](ctx context.Context, src Source[Row], tomb TombstoneSource, maxRowsPerBatch int, h Handle) (*BlockReader[Row], error) {
+	if maxRowsPerBatch <= 0 {
+		return nil, fmt.Errorf("blockreader: maxRowsPerBatch must be positive, got %d", maxRowsPerBatch)
+	}
+	if h.RowOffset < 0 || h.RowOffset > src.Len() {
+		return nil, fmt.Errorf("blockreader: row offset %d out of range [0, %d]", h.RowOffset, src.Len())
+	}
+	return &BlockReader[Row]{src: src, tomb: tomb, maxRowsPerBatch: maxRowsPerBatch, rowOffset: h.RowOffset}, nil
+}
+
+// Next decodes and returns the next chunk of live (non-deleted) rows, up
+// to maxRowsPerBatch of them, or io.EOF once every row has been
+// delivered.
+func (r *BlockReader[Row]) Next(ctx context.Context) ([]Row, error) {
+	if r.closed {
+		return nil, fmt.Errorf("blockreader: Next called after Close")
+	}
+	if r.rowOffset >= r.src.Len() {
+		return nil, io.EOF
+	}
+
+	end := r.rowOffset + r.maxRowsPerBatch
+	if total := r.src.Len(); end > total {
+		end = total
+	}
+	rows, err := r.src.ReadRange(ctx, r.rowOffset, end)
+	if err != nil {
+		return nil, err
+	}
+	tombstones, err := r.CollectTombstones(ctx)
+	if err != nil {
+		return nil, err
+	}
+	live := filterDeleted(rows, r.rowOffset, tombstones)
+	r.rowOffset = end
+	return live, nil
+}
+
+// filterDeleted drops the rows of a [startOffset, startOffset+len(rows))
+// chunk whose absolute row offset tombstones marks deleted.
+func filterDeleted[Row any](rows []Row, startOffset int, tombstones *roaring.Bitmap) []Row {
+	if tombstones == nil || tombstones.IsEmpty() {
+		return rows
+	}
+	live := make([]Row, 0, len(rows))
+	for i, row := range rows {
+		offset := startOffset + i
+		// roaring.Bitmap only addresses a uint32 domain. A block's row
+		// offset never reaches that range in practice, but an offset
+		// that did would silently alias onto an unrelated bit if cast
+		// without this check, so treat it as never-deleted instead.
+		if offset < 0 || offset > math.MaxUint32 || !tombstones.Contains(uint32(offset)) {
+			live = append(live, row)
+		}
+	}
+	return live
+}
+
+// CollectTombstones returns the block's delete bitmap, fetching it from
+// the TombstoneSource once and reusing it for every subsequent Next call
+// and CollectTombstones call on this reader.
+func (r *BlockReader[Row]) CollectTombstones(ctx context.Context) (*roaring.Bitmap, error) {
+	if r.tombstones == nil {
+		bm, err := r.tomb.CollectTombstones(ctx)
+		if err != nil {
+			return nil, err
+		}
+		if bm == nil {
+			bm = roaring.New()
+		}
+		r.tombstones = bm
+	}
+	return r.tombstones, nil
+}
+
+// Close marks the reader done. It does not release src or tomb, which
+// the caller owns.
+func (r *BlockReader[Row]) Close() error {
+	r.closed = true
+	return nil
+}
+
+// Handle captures the reader's current position for ResumeBlockReader.
+func (r *BlockReader[Row]) Handle() Handle {
+	return Handle{RowOffset: r.rowOffset}
+}
+
+// BlockReadInner drains a BlockReader until EOF and returns every live
+// row it produced as one slice, for callers not yet converted to the
+// streaming API - the same backward-compatible role
+// blockio.BlockReadInner is meant to keep after this package lands.
+func BlockReadInner[Row any](ctx context.Context, src Source[Row], tomb TombstoneSource, maxRowsPerBatch int) ([]Row, error) {
+	r, err := BuildBlockReader(ctx, src, tomb, maxRowsPerBatch)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var all []Row
+	for {
+		rows, err := r.Next(ctx)
+		if err == io.EOF {
+			return all, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		all = append(all, rows...)
+	}
+}
@@ -0,0 +1,163 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package blockreader
+
+import (
+	"context"
+	"io"
+	"testing"
+
+	"github.com/RoaringBitmap/roaring"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSource holds every row of a block in memory, simulating a decoded
+// object store read: rows are just their own row offset.
+type fakeSource struct {
+	rows []int
+}
+
+func (s *fakeSource) Len() int { return len(s.rows) }
+
+func (s *fakeSource) ReadRange(ctx context.Context, start, end int) ([]int, error) {
+	out := make([]int, end-start)
+	copy(out, s.rows[start:end])
+	return out, nil
+}
+
+type fakeTombstones struct {
+	bm *roaring.Bitmap
+}
+
+func (t *fakeTombstones) CollectTombstones(ctx context.Context) (*roaring.Bitmap, error) {
+	return t.bm, nil
+}
+
+func newBlock(rowCount int, deleted ...int) (*fakeSource, *fakeTombstones) {
+	rows := make([]int, rowCount)
+	for i := range rows {
+		rows[i] = i
+	}
+	bm := roaring.New()
+	for _, d := range deleted {
+		bm.Add(uint32(d))
+	}
+	return &fakeSource{rows: rows}, &fakeTombstones{bm: bm}
+}
+
+func TestNextChunksOutputByMaxRowsPerBatch(t *testing.T) {
+	src, tomb := newBlock(20)
+	ctx := context.Background()
+
+	r, err := BuildBlockReader[int](ctx, src, tomb, 8)
+	require.NoError(t, err)
+	defer r.Close()
+
+	var batches [][]int
+	for {
+		rows, err := r.Next(ctx)
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		batches = append(batches, rows)
+	}
+
+	require.Len(t, batches, 3)
+	assert.Len(t, batches[0], 8)
+	assert.Len(t, batches[1], 8)
+	assert.Len(t, batches[2], 4)
+	assert.Equal(t, []int{0, 1, 2, 3, 4, 5, 6, 7}, batches[0])
+	assert.Equal(t, []int{16, 17, 18, 19}, batches[2])
+}
+
+func TestNextAppliesDeletesLazilyPerChunk(t *testing.T) {
+	// Mirrors TestCollectDelete's three-delete sequence: rows 0, 1-3, and
+	// 4-5 are deleted, spread across the reader's first two chunks.
+	src, tomb := newBlock(20, 0, 1, 2, 3, 4, 5)
+	ctx := context.Background()
+
+	r, err := BuildBlockReader[int](ctx, src, tomb, 8)
+	require.NoError(t, err)
+	defer r.Close()
+
+	first, err := r.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int{6, 7}, first)
+
+	second, err := r.Next(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []int{8, 9, 10, 11, 12, 13, 14, 15}, second)
+
+	bm, err := r.CollectTombstones(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, uint64(6), bm.GetCardinality())
+}
+
+func TestBlockReadInnerDrainsAllLiveRows(t *testing.T) {
+	src, tomb := newBlock(12, 0, 3)
+	ctx := context.Background()
+
+	rows, err := BlockReadInner[int](ctx, src, tomb, 5)
+	require.NoError(t, err)
+	assert.Len(t, rows, 10)
+	assert.NotContains(t, rows, 0)
+	assert.NotContains(t, rows, 3)
+}
+
+func TestResumeBlockReaderContinuesAfterClose(t *testing.T) {
+	src, tomb := newBlock(20)
+	ctx := context.Background()
+
+	r, err := BuildBlockReader[int](ctx, src, tomb, 8)
+	require.NoError(t, err)
+	first, err := r.Next(ctx)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	resumed, err := ResumeBlockReader[int](ctx, src, tomb, 8, r.Handle())
+	require.NoError(t, err)
+	defer resumed.Close()
+
+	second, err := resumed.Next(ctx)
+	require.NoError(t, err)
+	assert.NotEqual(t, first, second)
+	assert.Equal(t, []int{8, 9, 10, 11, 12, 13, 14, 15}, second)
+}
+
+func TestNextAfterCloseErrors(t *testing.T) {
+	src, tomb := newBlock(4)
+	ctx := context.Background()
+
+	r, err := BuildBlockReader[int](ctx, src, tomb, 8)
+	require.NoError(t, err)
+	require.NoError(t, r.Close())
+
+	_, err = r.Next(ctx)
+	assert.Error(t, err)
+}
+
+func TestBuildBlockReaderRejectsNonPositiveBatchSize(t *testing.T) {
+	src, tomb := newBlock(4)
+	_, err := BuildBlockReader[int](context.Background(), src, tomb, 0)
+	assert.Error(t, err)
+}
+
+func TestResumeBlockReaderRejectsOutOfRangeOffset(t *testing.T) {
+	src, tomb := newBlock(4)
+	_, err := ResumeBlockReader[int](context.Background(), src, tomb, 2, Handle{RowOffset: 5})
+	assert.Error(t, err)
+}
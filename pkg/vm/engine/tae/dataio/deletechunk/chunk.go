@@ -0,0 +1,135 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package deletechunk implements an on-disk, mmap-friendly directory of
+// persisted-delete "chunks", one per (segmentID, blockID, seqno): a small
+// header (Meta) describing the owning block, its commit-ts range and row
+// count, followed by the row bitmap BlockData.CollectDeleteInRange would
+// otherwise decode from a delta-location object on every replay. It is
+// modeled on dataio/headchunk's segment format but, like dataio/
+// blockchunk, write-once per record rather than last-write-wins: a block
+// accumulates one chunk per flush, the same way it accumulates one delta-
+// location object per TryDeleteByDeltaloc/UpdateDeltaLoc call today.
+//
+// This package doesn't know about catalog.BlockEntry, index.DeltaLocIndex
+// (see pkg/vm/engine/tae/index/delta_loc_index.go), or tae.DB's restart
+// sequence: tae.Restart is left to call OpenDir before replaying the WAL/
+// logtail, build a map[blockID][]*Entry from the returned records, and
+// attach any replayed commit to the pre-loaded chunks so
+// BlockData.CollectDeleteInRange can scan the mmapped bitmap directly
+// instead of re-decoding the delta-location object that produced it. A
+// corrupted chunk and everything after it in its segment is truncated the
+// same way blockchunk.Dir recovers a torn tail, so the WAL rebuilds
+// exactly the suffix that didn't make it to disk - a torn mmap file never
+// loses data, it just costs one extra replay.
+package deletechunk
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+)
+
+const (
+	// magic tags a segment file as belonging to this format.
+	magic = "TDC1"
+	// recordHeaderSize is the fixed-size prefix before every record's
+	// body (its encoded Meta followed by the payload): a 4-byte length
+	// and a 4-byte CRC32, both of the whole body.
+	recordHeaderSize = 8
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ChunkRef locates one record's row-bitmap payload within a segment file.
+type ChunkRef struct {
+	// Offset is the byte offset of the payload, not its header or Meta.
+	Offset int64
+	Length int64
+	Meta   Meta
+}
+
+// Writer appends checksummed delete-chunk records to a single segment
+// file.
+type Writer struct {
+	f      *os.File
+	offset int64
+}
+
+// CreateWriter creates a new, empty segment file at path and writes its
+// header. It fails if path already exists.
+func CreateWriter(path string) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("deletechunk: create segment %s: %w", path, err)
+	}
+	if _, err := f.WriteString(magic); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("deletechunk: write header %s: %w", path, err)
+	}
+	return &Writer{f: f, offset: int64(len(magic))}, nil
+}
+
+// OpenWriter reopens an existing segment file for appending, positioning
+// new writes after whatever it already contains. Callers that recovered a
+// segment via Recover should pass validUpTo as truncateTo so any trailing
+// torn record is discarded before new data is appended past it.
+func OpenWriter(path string, truncateTo int64) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("deletechunk: open segment %s: %w", path, err)
+	}
+	if err := f.Truncate(truncateTo); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("deletechunk: truncate segment %s: %w", path, err)
+	}
+	if _, err := f.Seek(truncateTo, 0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("deletechunk: seek segment %s: %w", path, err)
+	}
+	return &Writer{f: f, offset: truncateTo}, nil
+}
+
+// Append writes a row bitmap payload as one record stamped with meta
+// (which block, seqno, and commit-ts range it belongs to) and returns
+// where it landed. The record is not guaranteed durable until Sync
+// returns.
+func (w *Writer) Append(bitmap []byte, meta Meta) (ChunkRef, error) {
+	body := append(meta.encode(), bitmap...)
+
+	var hdr [recordHeaderSize]byte
+	binary.LittleEndian.PutUint32(hdr[0:4], uint32(len(body)))
+	binary.LittleEndian.PutUint32(hdr[4:8], crc32.Checksum(body, crcTable))
+	if _, err := w.f.Write(hdr[:]); err != nil {
+		return ChunkRef{}, fmt.Errorf("deletechunk: write record header: %w", err)
+	}
+	if _, err := w.f.Write(body); err != nil {
+		return ChunkRef{}, fmt.Errorf("deletechunk: write record body: %w", err)
+	}
+	ref := ChunkRef{Offset: w.offset + recordHeaderSize + metaSize, Length: int64(len(bitmap)), Meta: meta}
+	w.offset += recordHeaderSize + int64(len(body))
+	return ref, nil
+}
+
+// Sync flushes the segment file to stable storage.
+func (w *Writer) Sync() error {
+	return w.f.Sync()
+}
+
+// Close closes the underlying file without syncing it; callers that need
+// durability must call Sync first.
+func (w *Writer) Close() error {
+	return w.f.Close()
+}
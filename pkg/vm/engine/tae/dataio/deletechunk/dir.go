@@ -0,0 +1,205 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deletechunk
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// segmentMaxBytes bounds how large a single segment file is allowed to
+// grow before Dir cuts a new one, so restart never has to scan and mmap
+// one unbounded file before WAL replay can start.
+const segmentMaxBytes = 64 << 20
+
+// segmentName renders seq as the zero-padded file name Dir expects, e.g.
+// segment 3 -> "000003".
+func segmentName(seq int) string {
+	return fmt.Sprintf("%06d", seq)
+}
+
+// Dir manages a directory of sequentially numbered delete-chunk segment
+// files for one DB, cutting a new segment once the active one passes
+// segmentMaxBytes. tae.Restart is meant to call OpenDir before replaying
+// the WAL/logtail and build a map[blockID][]*Entry from the returned
+// records, so any commit the replay attaches lands on top of the
+// pre-loaded chunks instead of requiring them to be re-decoded from their
+// delta-location objects.
+type Dir struct {
+	path    string
+	active  *Writer
+	seq     int
+	written int64
+}
+
+// Entry is one block's recovered delete chunk: which segment it lives in
+// and where within that segment, keyed by the same (SegmentID, BlockID)
+// pair OpenDir groups records by.
+type Entry struct {
+	Segment int
+	Ref     ChunkRef
+}
+
+// BlockKey identifies the block a recovered Entry belongs to.
+type BlockKey struct {
+	SegmentID uint64
+	BlockID   uint64
+}
+
+// OpenDir opens (creating if necessary) the delete-chunks directory at
+// path, recovers every segment in order, and returns the Dir plus every
+// recovered Entry grouped by the block it tombstones rows for. Only the
+// last segment's tail can be torn; every earlier segment was already cut
+// (and therefore fully synced) before the next one was created, so a torn
+// sealed segment is treated the same way blockchunk.OpenDir treats one:
+// fatal, since nothing downstream of it can repair disk corruption that
+// happened after the segment was sealed.
+func OpenDir(path string) (d *Dir, entries map[BlockKey][]Entry, err error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, nil, fmt.Errorf("deletechunk: create dir %s: %w", path, err)
+	}
+	seqs, err := listSegments(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entries = make(map[BlockKey][]Entry)
+	d = &Dir{path: path}
+	if len(seqs) == 0 {
+		w, err := CreateWriter(filepath.Join(path, segmentName(0)))
+		if err != nil {
+			return nil, nil, err
+		}
+		d.active = w
+		d.seq = 0
+		return d, entries, nil
+	}
+
+	for _, seq := range seqs[:len(seqs)-1] {
+		f, err := Open(filepath.Join(path, segmentName(seq)))
+		if err != nil {
+			return nil, nil, err
+		}
+		refs, validUpTo, err := f.Scan()
+		closeErr := f.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		if closeErr != nil {
+			return nil, nil, closeErr
+		}
+		info, statErr := os.Stat(filepath.Join(path, segmentName(seq)))
+		if statErr != nil {
+			return nil, nil, statErr
+		}
+		if validUpTo != info.Size() {
+			return nil, nil, fmt.Errorf("deletechunk: sealed segment %d is torn at byte %d of %d; treating as corrupt beyond repair", seq, validUpTo, info.Size())
+		}
+		addEntries(entries, seq, refs)
+	}
+
+	lastSeq := seqs[len(seqs)-1]
+	refs, w, err := Recover(filepath.Join(path, segmentName(lastSeq)))
+	if err != nil {
+		return nil, nil, err
+	}
+	addEntries(entries, lastSeq, refs)
+	d.active = w
+	d.seq = lastSeq
+	d.written = w.offset
+	return d, entries, nil
+}
+
+func addEntries(entries map[BlockKey][]Entry, seq int, refs []ChunkRef) {
+	for _, ref := range refs {
+		key := BlockKey{SegmentID: ref.Meta.SegmentID, BlockID: ref.Meta.BlockID}
+		entries[key] = append(entries[key], Entry{Segment: seq, Ref: ref})
+	}
+}
+
+func listSegments(path string) ([]int, error) {
+	ents, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("deletechunk: list dir %s: %w", path, err)
+	}
+	var seqs []int
+	for _, e := range ents {
+		if e.IsDir() {
+			continue
+		}
+		seq, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+	return seqs, nil
+}
+
+// Append writes a row bitmap, stamped with meta describing the block and
+// commit-ts range it belongs to, to the active segment, cutting a new one
+// first if doing so would exceed segmentMaxBytes. It returns which
+// segment the chunk landed in alongside its ChunkRef.
+func (d *Dir) Append(bitmap []byte, meta Meta) (seq int, ref ChunkRef, err error) {
+	if d.written+int64(len(bitmap)) > segmentMaxBytes {
+		if err := d.cut(); err != nil {
+			return 0, ChunkRef{}, err
+		}
+	}
+	ref, err = d.active.Append(bitmap, meta)
+	if err != nil {
+		return 0, ChunkRef{}, err
+	}
+	d.written += int64(len(bitmap))
+	return d.seq, ref, nil
+}
+
+// cut seals the active segment and opens the next one in sequence.
+func (d *Dir) cut() error {
+	if err := d.active.Sync(); err != nil {
+		return err
+	}
+	if err := d.active.Close(); err != nil {
+		return err
+	}
+	d.seq++
+	d.written = 0
+	w, err := CreateWriter(filepath.Join(d.path, segmentName(d.seq)))
+	if err != nil {
+		return err
+	}
+	d.active = w
+	return nil
+}
+
+// Sync flushes the active segment to stable storage.
+func (d *Dir) Sync() error {
+	return d.active.Sync()
+}
+
+// Close closes the active segment without syncing it.
+func (d *Dir) Close() error {
+	return d.active.Close()
+}
+
+// Open mmaps segment seq for reading, e.g. to serve an Entry recorded
+// against it.
+func (d *Dir) Open(seq int) (*File, error) {
+	return Open(filepath.Join(d.path, segmentName(seq)))
+}
@@ -0,0 +1,68 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deletechunk
+
+import "encoding/binary"
+
+// metaSize is the fixed, encoded size of Meta: two 8-byte IDs, an
+// 8-byte seqno, two 12-byte timestamps (8-byte physical + 4-byte logical
+// each, matching types.TS's own field widths, as headchunk.Meta does),
+// a 4-byte row count, and 8 trailing bytes that encode leaves zero.
+const metaSize = 8 + 8 + 8 + 8 + 12 + 12 + 4
+
+// Meta describes the block a persisted-delete chunk's row bitmap payload
+// belongs to: which (segment, block) it tombstones rows for, the seqno
+// distinguishing it from any other delete chunk flushed for the same
+// block, the [MinTS, MaxTS] range of commit timestamps the payload's
+// deletes cover, and how many rows are set so a reader can size a
+// decoded bitmap without touching the mmapped payload first.
+type Meta struct {
+	SegmentID uint64
+	BlockID   uint64
+	SeqNo     uint64
+
+	MinTSPhysical int64
+	MinTSLogical  uint32
+	MaxTSPhysical int64
+	MaxTSLogical  uint32
+
+	RowCount uint32
+}
+
+func (m Meta) encode() []byte {
+	b := make([]byte, metaSize)
+	binary.LittleEndian.PutUint64(b[0:8], m.SegmentID)
+	binary.LittleEndian.PutUint64(b[8:16], m.BlockID)
+	binary.LittleEndian.PutUint64(b[16:24], m.SeqNo)
+	binary.LittleEndian.PutUint64(b[24:32], uint64(m.MinTSPhysical))
+	binary.LittleEndian.PutUint32(b[32:36], m.MinTSLogical)
+	binary.LittleEndian.PutUint64(b[36:44], uint64(m.MaxTSPhysical))
+	binary.LittleEndian.PutUint32(b[44:48], m.MaxTSLogical)
+	binary.LittleEndian.PutUint32(b[48:52], m.RowCount)
+	return b
+}
+
+func decodeMeta(b []byte) Meta {
+	return Meta{
+		SegmentID:     binary.LittleEndian.Uint64(b[0:8]),
+		BlockID:       binary.LittleEndian.Uint64(b[8:16]),
+		SeqNo:         binary.LittleEndian.Uint64(b[16:24]),
+		MinTSPhysical: int64(binary.LittleEndian.Uint64(b[24:32])),
+		MinTSLogical:  binary.LittleEndian.Uint32(b[32:36]),
+		MaxTSPhysical: int64(binary.LittleEndian.Uint64(b[36:44])),
+		MaxTSLogical:  binary.LittleEndian.Uint32(b[44:48]),
+		RowCount:      binary.LittleEndian.Uint32(b[48:52]),
+	}
+}
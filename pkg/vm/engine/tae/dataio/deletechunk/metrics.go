@@ -0,0 +1,53 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deletechunk
+
+import "sync/atomic"
+
+var (
+	residentBytes         atomic.Int64
+	corruptionTruncations atomic.Int64
+)
+
+// Metrics is a point-in-time snapshot of this process's delete-chunk
+// activity.
+type Metrics struct {
+	// MmapBytesResident is how many bytes of segment files are currently
+	// mapped into this process, summed across every open File.
+	MmapBytesResident int64
+	// CorruptionTruncations counts how many times Scan has discarded a
+	// torn or checksum-failing tail since process start, across every
+	// segment scanned. A nonzero count right after a crash is expected -
+	// that's exactly the suffix WAL replay reconstructs - but a count
+	// that keeps growing outside of crash recovery points at disk
+	// corruption instead.
+	CorruptionTruncations int64
+}
+
+// ReadMetrics returns the current Metrics snapshot.
+func ReadMetrics() Metrics {
+	return Metrics{
+		MmapBytesResident:     residentBytes.Load(),
+		CorruptionTruncations: corruptionTruncations.Load(),
+	}
+}
+
+func addResidentBytes(delta int64) {
+	residentBytes.Add(delta)
+}
+
+func recordCorruptionTruncation() {
+	corruptionTruncations.Add(1)
+}
@@ -0,0 +1,45 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build windows
+
+package deletechunk
+
+import "os"
+
+// mmapData is the fallback backend for platforms without unix mmap
+// semantics: it reads the whole segment into a heap buffer once. Segment
+// files are bounded by segmentMaxBytes, so this stays small.
+type mmapData struct {
+	b []byte
+}
+
+func mmapOpen(path string, size int64) (mmapData, error) {
+	if size == 0 {
+		return mmapData{}, nil
+	}
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return mmapData{}, err
+	}
+	return mmapData{b: b}, nil
+}
+
+func (m mmapData) Bytes() []byte {
+	return m.b
+}
+
+func (m mmapData) Close() error {
+	return nil
+}
@@ -0,0 +1,142 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package deletechunk
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+)
+
+// File is a read-only, mmapped view of one segment file.
+type File struct {
+	path string
+	data mmapData
+}
+
+// Open mmaps the segment file at path for reading. The caller is
+// responsible for validating it with Scan before trusting any offset
+// beyond the header.
+func Open(path string) (*File, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("deletechunk: stat segment %s: %w", path, err)
+	}
+	data, err := mmapOpen(path, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("deletechunk: mmap segment %s: %w", path, err)
+	}
+	addResidentBytes(int64(len(data.Bytes())))
+	return &File{path: path, data: data}, nil
+}
+
+// Close unmaps the segment file.
+func (f *File) Close() error {
+	addResidentBytes(-int64(len(f.data.Bytes())))
+	return f.data.Close()
+}
+
+// Size returns the mmapped file's length in bytes.
+func (f *File) Size() int64 {
+	return int64(len(f.data.Bytes()))
+}
+
+// Bitmap returns the row-bitmap payload located at ref, scanning the
+// mmapped region directly rather than copying it - the whole point of
+// keeping a decoded delete chunk around is that CollectDeleteInRange can
+// read straight out of the page cache. It does not re-verify the
+// checksum; callers should only pass refs that Scan already validated.
+func (f *File) Bitmap(ref ChunkRef) ([]byte, error) {
+	b := f.data.Bytes()
+	end := ref.Offset + ref.Length
+	if ref.Offset < 0 || end > int64(len(b)) {
+		return nil, fmt.Errorf("deletechunk: ref %+v out of range for %d-byte segment", ref, len(b))
+	}
+	return b[ref.Offset:end], nil
+}
+
+// Scan walks the segment from its header, validating each record's length
+// and checksum, and returns a ChunkRef for every fully intact record in
+// order. validUpTo is the byte offset of the first byte not covered by a
+// valid record - either because the file ends there, or because the next
+// record's header/payload is short or fails its checksum. Everything from
+// validUpTo onward is presumed to be a torn write from a crash and must be
+// discarded; the WAL replay that follows OpenDir is responsible for
+// reconstructing whatever deletes it covered.
+func (f *File) Scan() (refs []ChunkRef, validUpTo int64, err error) {
+	b := f.data.Bytes()
+	if len(b) < len(magic) || string(b[:len(magic)]) != magic {
+		return nil, 0, fmt.Errorf("deletechunk: %s is not a delete-chunk segment", f.path)
+	}
+	off := int64(len(magic))
+	for {
+		if off+recordHeaderSize > int64(len(b)) {
+			if off != int64(len(b)) {
+				recordCorruptionTruncation()
+			}
+			return refs, off, nil
+		}
+		bodyLen := int64(binary.LittleEndian.Uint32(b[off : off+4]))
+		wantCRC := binary.LittleEndian.Uint32(b[off+4 : off+8])
+		bodyStart := off + recordHeaderSize
+		bodyEnd := bodyStart + bodyLen
+		if bodyLen < metaSize || bodyEnd > int64(len(b)) {
+			recordCorruptionTruncation()
+			return refs, off, nil
+		}
+		body := b[bodyStart:bodyEnd]
+		if crc32.Checksum(body, crcTable) != wantCRC {
+			recordCorruptionTruncation()
+			return refs, off, nil
+		}
+		meta := decodeMeta(body[:metaSize])
+		payloadStart := bodyStart + metaSize
+		refs = append(refs, ChunkRef{Offset: payloadStart, Length: bodyLen - metaSize, Meta: meta})
+		off = bodyEnd
+	}
+}
+
+// Recover opens path (creating an empty, header-only segment if it does
+// not exist yet) and scans it, returning the intact records plus the
+// writer that should continue appending after them. Any bytes from
+// validUpTo onward are truncated away, so the returned writer never
+// appends after a torn record.
+func Recover(path string) (refs []ChunkRef, writer *Writer, err error) {
+	if _, statErr := os.Stat(path); os.IsNotExist(statErr) {
+		w, err := CreateWriter(path)
+		if err != nil {
+			return nil, nil, err
+		}
+		return nil, w, nil
+	}
+
+	f, err := Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	refs, validUpTo, err := f.Scan()
+	if cerr := f.Close(); cerr != nil && err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	w, err := OpenWriter(path, validUpTo)
+	if err != nil {
+		return nil, nil, err
+	}
+	return refs, w, nil
+}
@@ -0,0 +1,145 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package headchunk implements the on-disk format used to persist an
+// appendable block's column vectors and delete chain once it fills or ages
+// past a threshold, so the heap-resident representation can be replaced by
+// an mmapped one instead of being kept around until the next checkpoint.
+// The layout is the Prometheus mmap-head design: a directory of
+// sequentially numbered segment files, each holding a stream of
+// checksummed, length-prefixed records. Every record is self-describing,
+// so a reader can validate a segment by scanning it front to back without
+// any separate manifest; a corrupt or torn record (the tail of a write
+// that never reached fsync before a crash) is detected by its checksum and
+// simply ends the valid prefix of the segment, leaving WAL replay to
+// reconstruct whatever came after it. The directory this package manages
+// is meant to be surfaced as options.CacheCfg.HeadChunkDir, alongside the
+// WAL directory, so it can be placed on its own device independently of
+// the object store cache. options.CacheCfg and the appendable-block write
+// path that would call Dir.Append do not exist in this checkout; Dir and
+// Writer are the on-disk format those pieces compose on top of once they
+// do.
+package headchunk
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+)
+
+const (
+	// magic tags a segment file as belonging to this format so a reader
+	// never mistakes a foreign file for one of ours.
+	magic = "THC1"
+	// recordHeaderSize is the fixed-size prefix before every record's
+	// body (its encoded Meta followed by the payload): a 4-byte length and
+	// a 4-byte CRC32, both of the whole body, and an 8-byte LSN identifying the last WAL record the chunk already
+	// reflects, so recovery can tell which WAL tail still needs replaying
+	// on top of a recovered chunk.
+	recordHeaderSize = 16
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// ChunkRef locates one record within a segment file.
+type ChunkRef struct {
+	// Offset is the byte offset of the record's payload, not its header or
+	// its Meta.
+	Offset int64
+	Length int64
+	// LSN is the WAL log index this chunk was flushed at: replay should
+	// skip any WAL record for the same block at or below LSN and only
+	// apply records after it.
+	LSN uint64
+	// Meta describes the block/column this record's payload holds, so a
+	// caller scanning a segment cold can route it without decoding the
+	// payload.
+	Meta Meta
+}
+
+// Writer appends checksummed records to a single segment file, tracking
+// the offset new records land at so callers can hand the returned
+// ChunkRef straight to an Index.
+type Writer struct {
+	f      *os.File
+	offset int64
+}
+
+// CreateWriter creates a new, empty segment file at path and writes its
+// header. It fails if path already exists.
+func CreateWriter(path string) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("headchunk: create segment %s: %w", path, err)
+	}
+	if _, err := f.WriteString(magic); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("headchunk: write header %s: %w", path, err)
+	}
+	return &Writer{f: f, offset: int64(len(magic))}, nil
+}
+
+// OpenWriter reopens an existing segment file for appending, positioning
+// new writes after whatever it already contains. Callers that recovered a
+// segment via Recover should pass validUpTo as truncateTo so any trailing
+// torn record is discarded before new data is appended past it.
+func OpenWriter(path string, truncateTo int64) (*Writer, error) {
+	f, err := os.OpenFile(path, os.O_RDWR, 0644)
+	if err != nil {
+		return nil, fmt.Errorf("headchunk: open segment %s: %w", path, err)
+	}
+	if err := f.Truncate(truncateTo); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("headchunk: truncate segment %s: %w", path, err)
+	}
+	if _, err := f.Seek(truncateTo, 0); err != nil {
+		f.Close()
+		return nil, fmt.Errorf("headchunk: seek segment %s: %w", path, err)
+	}
+	return &Writer{f: f, offset: truncateTo}, nil
+}
+
+// Append writes payload as one record stamped with lsn (the WAL log index
+// this chunk already reflects) and meta (which block/column it belongs
+// to), and returns where it landed. The record is not guaranteed durable
+// until Sync returns.
+func (w *Writer) Append(payload []byte, lsn uint64, meta Meta) (ChunkRef, error) {
+	body := append(meta.encode(), payload...)
+
+	var hdr [recordHeaderSize]byte
+	binary.LittleEndian.PutUint32(hdr[0:4], uint32(len(body)))
+	binary.LittleEndian.PutUint32(hdr[4:8], crc32.Checksum(body, crcTable))
+	binary.LittleEndian.PutUint64(hdr[8:16], lsn)
+	if _, err := w.f.Write(hdr[:]); err != nil {
+		return ChunkRef{}, fmt.Errorf("headchunk: write record header: %w", err)
+	}
+	if _, err := w.f.Write(body); err != nil {
+		return ChunkRef{}, fmt.Errorf("headchunk: write record body: %w", err)
+	}
+	ref := ChunkRef{Offset: w.offset + recordHeaderSize + metaSize, Length: int64(len(payload)), LSN: lsn, Meta: meta}
+	w.offset += recordHeaderSize + int64(len(body))
+	return ref, nil
+}
+
+// Sync flushes the segment file to stable storage.
+func (w *Writer) Sync() error {
+	return w.f.Sync()
+}
+
+// Close closes the underlying file without syncing it; callers that need
+// durability must call Sync first.
+func (w *Writer) Close() error {
+	return w.f.Close()
+}
@@ -0,0 +1,196 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package headchunk
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+)
+
+// segmentMaxBytes bounds how large a single segment file is allowed to
+// grow before Dir cuts a new one, so recovery never has to scan and mmap
+// one unbounded file.
+const segmentMaxBytes = 128 << 20
+
+// segmentName renders seq as the zero-padded file name Dir expects, e.g.
+// segment 3 -> "000003".
+func segmentName(seq int) string {
+	return fmt.Sprintf("%06d", seq)
+}
+
+// Dir manages a directory of sequentially numbered head-chunk segment
+// files for one DB, cutting a new segment once the active one passes
+// segmentMaxBytes. This is the on-disk layout the recovery step in the
+// package doc walks: segments are replayed in ascending sequence order,
+// and only the active (highest-numbered) segment can have a torn tail.
+type Dir struct {
+	path    string
+	active  *Writer
+	seq     int
+	written int64
+}
+
+// OpenDir opens (creating if necessary) the head-chunks directory at path,
+// recovers every segment in order, and returns the Dir plus every ChunkRef
+// recovered across all segments, keyed by segment sequence number, so the caller can build its block ->
+// chunk index. Only the last segment's tail can be torn; every earlier
+// segment was already cut (and therefore fully synced) before the next one
+// was created, so Recover's truncation only ever applies to it.
+func OpenDir(path string) (d *Dir, entries map[int][]ChunkRef, err error) {
+	if err := os.MkdirAll(path, 0755); err != nil {
+		return nil, nil, fmt.Errorf("headchunk: create dir %s: %w", path, err)
+	}
+	seqs, err := listSegments(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	entries = make(map[int][]ChunkRef)
+	d = &Dir{path: path}
+	if len(seqs) == 0 {
+		w, err := CreateWriter(filepath.Join(path, segmentName(0)))
+		if err != nil {
+			return nil, nil, err
+		}
+		d.active = w
+		d.seq = 0
+		return d, entries, nil
+	}
+
+	for _, seq := range seqs[:len(seqs)-1] {
+		f, err := Open(filepath.Join(path, segmentName(seq)))
+		if err != nil {
+			return nil, nil, err
+		}
+		refs, validUpTo, err := f.Scan()
+		closeErr := f.Close()
+		if err != nil {
+			return nil, nil, err
+		}
+		if closeErr != nil {
+			return nil, nil, closeErr
+		}
+		info, statErr := os.Stat(filepath.Join(path, segmentName(seq)))
+		if statErr != nil {
+			return nil, nil, statErr
+		}
+		// A sealed segment is supposed to have been fully synced before the
+		// next one was cut, so it should never be torn. If it is anyway
+		// (disk corruption, a bad copy), do not fail OpenDir over it: Scan
+		// already recorded the corruption and stopped at the last intact
+		// record, so entries[seq] only ever holds blocks whose chunk is
+		// provably whole. Whatever chunk would have landed past validUpTo
+		// simply never gets a recorded Entry, so the caller's Index never
+		// Sets it and ReplayFrom falls back to replaying that block's WAL
+		// tail from scratch instead of trusting a corrupt chunk.
+		if validUpTo != info.Size() {
+			recordSealedSegmentCorruption()
+		}
+		entries[seq] = refs
+	}
+
+	lastSeq := seqs[len(seqs)-1]
+	refs, w, err := Recover(filepath.Join(path, segmentName(lastSeq)))
+	if err != nil {
+		return nil, nil, err
+	}
+	entries[lastSeq] = refs
+	d.active = w
+	d.seq = lastSeq
+	d.written = w.offset
+
+	total := 0
+	for _, refs := range entries {
+		total += len(refs)
+	}
+	recordChunksLoaded(total)
+	return d, entries, nil
+}
+
+func listSegments(path string) ([]int, error) {
+	ents, err := os.ReadDir(path)
+	if err != nil {
+		return nil, fmt.Errorf("headchunk: list dir %s: %w", path, err)
+	}
+	var seqs []int
+	for _, e := range ents {
+		if e.IsDir() {
+			continue
+		}
+		seq, err := strconv.Atoi(e.Name())
+		if err != nil {
+			continue
+		}
+		seqs = append(seqs, seq)
+	}
+	sort.Ints(seqs)
+	return seqs, nil
+}
+
+// Append writes payload, stamped with the WAL log index lsn it already
+// reflects and meta describing the block/column it belongs to, to the
+// active segment, cutting a new one first if doing so would exceed
+// segmentMaxBytes. It returns which segment the chunk landed in alongside
+// its ChunkRef.
+func (d *Dir) Append(payload []byte, lsn uint64, meta Meta) (seq int, ref ChunkRef, err error) {
+	if d.written+int64(len(payload)) > segmentMaxBytes {
+		if err := d.cut(); err != nil {
+			return 0, ChunkRef{}, err
+		}
+	}
+	ref, err = d.active.Append(payload, lsn, meta)
+	if err != nil {
+		return 0, ChunkRef{}, err
+	}
+	d.written += int64(len(payload))
+	return d.seq, ref, nil
+}
+
+// cut seals the active segment and opens the next one in sequence.
+func (d *Dir) cut() error {
+	if err := d.active.Sync(); err != nil {
+		return err
+	}
+	if err := d.active.Close(); err != nil {
+		return err
+	}
+	d.seq++
+	d.written = 0
+	w, err := CreateWriter(filepath.Join(d.path, segmentName(d.seq)))
+	if err != nil {
+		return err
+	}
+	d.active = w
+	return nil
+}
+
+// Sync flushes the active segment to stable storage.
+func (d *Dir) Sync() error {
+	return d.active.Sync()
+}
+
+// Close closes the active segment without syncing it.
+func (d *Dir) Close() error {
+	return d.active.Close()
+}
+
+// Open mmaps segment seq for reading, e.g. to serve Index entries recorded
+// against it.
+func (d *Dir) Open(seq int) (*File, error) {
+	return Open(filepath.Join(d.path, segmentName(seq)))
+}
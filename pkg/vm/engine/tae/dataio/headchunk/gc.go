@@ -0,0 +1,48 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package headchunk
+
+// Reclaim drops every entry in idx for which isCovered reports true, and
+// returns how many were dropped. It is meant to be called from a GC pass
+// like gc.DiskCleaner once an incremental checkpoint durably covers a
+// block's data some other way (e.g. the block has since been compacted
+// into an object), so the mmapped chunk is no longer needed to skip WAL
+// replay for it. This package does not itself know what "covered by a
+// checkpoint" means; isCovered is the caller's decision, keyed by
+// whatever K the caller's Index already uses.
+//
+// Reclaim only removes idx's bookkeeping, not the underlying segment
+// bytes: a reclaimed entry's record may still physically live in a
+// segment file alongside still-needed ones until that segment is later
+// cut and the whole file is removed.
+//
+// gc.DiskCleaner does not exist in this checkout, so nothing calls
+// Reclaim yet; isCovered's signature is the hook a future checkpoint GC
+// pass plugs its "covered by an incremental checkpoint" decision into
+// without Reclaim itself needing to know what a checkpoint is.
+func Reclaim[K comparable](idx *Index[K], isCovered func(K) bool) (reclaimed int) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	for key := range idx.entries {
+		if isCovered(key) {
+			delete(idx.entries, key)
+			reclaimed++
+		}
+	}
+	if reclaimed > 0 {
+		recordChunksReclaimed(reclaimed)
+	}
+	return reclaimed
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package headchunk
+
+import (
+	"fmt"
+	"sync"
+)
+
+// BlockKey identifies one column's head chunk by segment, block, and
+// column id, the composite key TAE itself uses to address a block's
+// column. Callers that already have a richer identifier (e.g. catalog's
+// common.ID plus its column index) can use that as K directly instead;
+// BlockKey exists for callers that only have the three integers on hand,
+// e.g. when parsing a chunk directory path that encodes
+// "segmentID-blockID-colID" in its name. ColID is part of the key, not
+// just Meta, because a block's columns are each Appended as their own
+// record: keying only on segment+block would collide every column of the
+// same flush onto one last-write-wins Entry, silently losing every
+// column but whichever Append landed last.
+type BlockKey struct {
+	SegmentID uint64
+	BlockID   uint64
+	ColID     uint64
+}
+
+func (k BlockKey) String() string {
+	return fmt.Sprintf("%d-%d-%d", k.SegmentID, k.BlockID, k.ColID)
+}
+
+// BuildIndex turns OpenDir's recovered entries (keyed by segment) into an
+// Index keyed by BlockKey, the shape a restart's block -> chunk lookup
+// needs. It is the BlockKey counterpart to the segment-keyed map OpenDir
+// itself returns, the same composition mmaprecovery.BuildIndex already
+// does for dataio/blockchunk.
+func BuildIndex(entries map[int][]ChunkRef) *Index[BlockKey] {
+	idx := NewIndex[BlockKey]()
+	for seq, refs := range entries {
+		for _, ref := range refs {
+			key := BlockKey{SegmentID: ref.Meta.SegID, BlockID: ref.Meta.BlockID, ColID: ref.Meta.ColID}
+			idx.Set(key, Entry{Segment: seq, Ref: ref})
+		}
+	}
+	return idx
+}
+
+// Entry is one block's recovered mmapped chunk: which segment it lives in
+// and where within that segment.
+type Entry struct {
+	Segment int
+	Ref     ChunkRef
+}
+
+// Index maps a caller-defined block identifier (the catalog's common.ID in
+// TAE, kept generic here so this package stays independent of the catalog
+// package) to the most recent Entry recovered or written for it. A block
+// is re-flushed over its lifetime, so Set is last-write-wins rather than
+// append-only.
+type Index[K comparable] struct {
+	mu      sync.RWMutex
+	entries map[K]Entry
+}
+
+// NewIndex returns an empty Index.
+func NewIndex[K comparable]() *Index[K] {
+	return &Index[K]{entries: make(map[K]Entry)}
+}
+
+// Set records (or replaces) the chunk entry for key.
+func (idx *Index[K]) Set(key K, e Entry) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	idx.entries[key] = e
+}
+
+// Get returns the chunk entry for key, if one has been recorded.
+func (idx *Index[K]) Get(key K) (Entry, bool) {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	e, ok := idx.entries[key]
+	return e, ok
+}
+
+// Delete removes key, e.g. once its block has been compacted into an
+// object and no longer needs an mmapped head chunk.
+func (idx *Index[K]) Delete(key K) {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	delete(idx.entries, key)
+}
+
+// Len reports how many blocks currently have a recorded chunk entry.
+func (idx *Index[K]) Len() int {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return len(idx.entries)
+}
+
+// ReplayFrom returns the WAL log index replay should resume from for key:
+// the chunk's own LSN when one is recorded, or fromLSN unchanged otherwise
+// (a block with no mmapped chunk must replay its WAL from the start). WAL
+// replay should apply only records with a log index greater than the
+// returned value, since the mmapped chunk already reflects everything up
+// to and including it.
+func (idx *Index[K]) ReplayFrom(key K, fromLSN uint64) uint64 {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	if e, ok := idx.entries[key]; ok {
+		return e.Ref.LSN
+	}
+	return fromLSN
+}
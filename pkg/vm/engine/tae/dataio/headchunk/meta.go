@@ -0,0 +1,74 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package headchunk
+
+import "encoding/binary"
+
+// metaSize is the fixed, encoded size of Meta: four 8-byte IDs, a 1-byte
+// encoding tag, and two 12-byte timestamps (8-byte physical + 4-byte
+// logical each, matching types.TS's own field widths).
+const metaSize = 8 + 8 + 8 + 8 + 1 + 12 + 12
+
+// Meta describes the flushed block a chunk's payload holds the column
+// vector for, so a reader opening a segment cold (e.g. after a crash,
+// before the catalog is replayed) can tell which table/block/column a
+// record belongs to and whether it is still in that block's committed TS
+// range without decoding the payload itself. TableID is carried alongside
+// SegID/BlockID rather than assumed from the directory the segment lives
+// in, so a GC pass can decide whether a block is covered by a table's
+// checkpoint without first resolving segment/block back to a table. The
+// checkpoint watermark that decision needs is catalog.Checkpoint's, which
+// does not exist in this checkout; Reclaim's isCovered callback is the
+// seam a future GC pass plugs Meta.TableID into.
+type Meta struct {
+	TableID  uint64
+	BlockID  uint64
+	SegID    uint64
+	ColID    uint64
+	Encoding uint8
+
+	MinTSPhysical int64
+	MinTSLogical  uint32
+	MaxTSPhysical int64
+	MaxTSLogical  uint32
+}
+
+func (m Meta) encode() []byte {
+	b := make([]byte, metaSize)
+	binary.LittleEndian.PutUint64(b[0:8], m.TableID)
+	binary.LittleEndian.PutUint64(b[8:16], m.BlockID)
+	binary.LittleEndian.PutUint64(b[16:24], m.SegID)
+	binary.LittleEndian.PutUint64(b[24:32], m.ColID)
+	b[32] = m.Encoding
+	binary.LittleEndian.PutUint64(b[33:41], uint64(m.MinTSPhysical))
+	binary.LittleEndian.PutUint32(b[41:45], m.MinTSLogical)
+	binary.LittleEndian.PutUint64(b[45:53], uint64(m.MaxTSPhysical))
+	binary.LittleEndian.PutUint32(b[53:57], m.MaxTSLogical)
+	return b
+}
+
+func decodeMeta(b []byte) Meta {
+	return Meta{
+		TableID:       binary.LittleEndian.Uint64(b[0:8]),
+		BlockID:       binary.LittleEndian.Uint64(b[8:16]),
+		SegID:         binary.LittleEndian.Uint64(b[16:24]),
+		ColID:         binary.LittleEndian.Uint64(b[24:32]),
+		Encoding:      b[32],
+		MinTSPhysical: int64(binary.LittleEndian.Uint64(b[33:41])),
+		MinTSLogical:  binary.LittleEndian.Uint32(b[41:45]),
+		MaxTSPhysical: int64(binary.LittleEndian.Uint64(b[45:53])),
+		MaxTSLogical:  binary.LittleEndian.Uint32(b[53:57]),
+	}
+}
@@ -0,0 +1,119 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package headchunk
+
+import "sync/atomic"
+
+var (
+	residentBytes            atomic.Int64
+	corruptionTruncations    atomic.Int64
+	chunksLoaded             atomic.Int64
+	walRecordsSkipped        atomic.Int64
+	chunksReclaimed          atomic.Int64
+	sealedSegmentCorruptions atomic.Int64
+	heapBytesReleased        atomic.Int64
+)
+
+// Metrics is a point-in-time snapshot of this process's head-chunk
+// activity, meant to be surfaced the same way the rest of TAE exports
+// gauges/counters (e.g. alongside the block cache's hit-rate metrics)
+// rather than read directly by application code.
+type Metrics struct {
+	// MmapBytesResident is how many bytes of segment files are currently
+	// mapped into this process, summed across every open File.
+	MmapBytesResident int64
+	// CorruptionTruncations counts how many times Scan has discarded a
+	// torn or checksum-failing tail since process start, across every
+	// segment scanned. A nonzero, growing count outside of the expected
+	// one-torn-tail-per-crash pattern usually means something worse than
+	// a crash (disk corruption, a truncated copy) is happening.
+	CorruptionTruncations int64
+	// ChunksLoaded counts how many chunk records OpenDir has recovered
+	// into a caller's block index since process start.
+	ChunksLoaded int64
+	// WALRecordsSkipped counts how many WAL records the caller's replay
+	// loop reported skipping because Index.ReplayFrom already resolved a
+	// recovered chunk covering them, via RecordSkipped.
+	WALRecordsSkipped int64
+	// ChunksReclaimed counts how many Index entries Reclaim has dropped
+	// because their block was reported covered by an incremental
+	// checkpoint.
+	ChunksReclaimed int64
+	// SealedSegmentCorruptions counts how many already-sealed segments
+	// OpenDir found torn at open time, a condition that should never
+	// happen (a sealed segment is only cut after a full sync) and that
+	// OpenDir tolerates by re-materializing the affected blocks from WAL
+	// instead of failing startup. A nonzero count is worth alerting on even
+	// though it isn't fatal.
+	SealedSegmentCorruptions int64
+	// HeapBytesReleased is the other half of "resident vs. mmapped bytes":
+	// MmapBytesResident tracks bytes newly mapped in by Open, while this
+	// tracks bytes a caller's on-heap containers.Vector gave up via
+	// RecordFrozen once the block backing it was frozen and replaced by an
+	// mmapped read, so a test like TestAppendAndGC can assert both moved in
+	// the direction freezing a block should move them.
+	HeapBytesReleased int64
+}
+
+// ReadMetrics returns the current Metrics snapshot.
+func ReadMetrics() Metrics {
+	return Metrics{
+		MmapBytesResident:        residentBytes.Load(),
+		CorruptionTruncations:    corruptionTruncations.Load(),
+		ChunksLoaded:             chunksLoaded.Load(),
+		WALRecordsSkipped:        walRecordsSkipped.Load(),
+		ChunksReclaimed:          chunksReclaimed.Load(),
+		SealedSegmentCorruptions: sealedSegmentCorruptions.Load(),
+		HeapBytesReleased:        heapBytesReleased.Load(),
+	}
+}
+
+func addResidentBytes(delta int64) {
+	residentBytes.Add(delta)
+}
+
+func recordCorruptionTruncation() {
+	corruptionTruncations.Add(1)
+}
+
+func recordChunksLoaded(n int) {
+	chunksLoaded.Add(int64(n))
+}
+
+// RecordSkipped reports that a WAL replay loop skipped n records for a
+// block because the chunk Index already resolved a recovered chunk
+// covering them. It is meant to be called once per block replay decides
+// its resume point from Index.ReplayFrom, not by this package itself,
+// which does not drive WAL replay.
+func RecordSkipped(n int) {
+	walRecordsSkipped.Add(int64(n))
+}
+
+func recordChunksReclaimed(n int) {
+	chunksReclaimed.Add(int64(n))
+}
+
+func recordSealedSegmentCorruption() {
+	sealedSegmentCorruptions.Add(1)
+}
+
+// RecordFrozen reports that heapBytes of on-heap containers.Vector storage
+// were released because the block they belonged to was just frozen and
+// replaced by an mmapped read via Open/Read. It is meant to be called once
+// per frozen block by whatever owns releasing the heap copy, not by this
+// package itself, which never holds the heap-resident representation.
+func RecordFrozen(heapBytes int64) {
+	heapBytesReleased.Add(heapBytes)
+}
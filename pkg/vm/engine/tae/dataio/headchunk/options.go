@@ -0,0 +1,36 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package headchunk
+
+// Options configures whether db.Runtime freezes a block's column vectors
+// into this package's mmapped tier at all, and where. It is meant to be
+// reached through options.CacheCfg.HeadChunkDir the same way the package
+// doc already describes that directory; this package only needs the two
+// fields below, not anything else CacheCfg carries.
+type Options struct {
+	// Enabled turns the tier on. It defaults to false: a Runtime that never
+	// sets it keeps every block's vectors heap-resident, exactly as before
+	// this package existed.
+	Enabled bool
+	// Dir is the head-chunks directory OpenDir manages, conventionally a
+	// sibling of the WAL directory so it can be placed on its own device.
+	Dir string
+}
+
+// DefaultOptions returns the tier disabled, the opt-in default every new
+// storage tier in this codebase ships with until a Runtime turns it on.
+func DefaultOptions() Options {
+	return Options{}
+}
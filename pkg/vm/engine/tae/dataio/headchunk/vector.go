@@ -0,0 +1,51 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package headchunk
+
+// MmappedVector is the minimal read surface a containers.Vector
+// implementation backed by a recovered head chunk needs: Bytes returns the
+// mmapped payload Scan already validated, for the real containers.Vector to
+// decode into typed values the same way it decodes a freshly-read object's
+// column bytes. This package stays ignorant of containers.Vector's
+// interface itself (element types, null bitmaps, the rest of its decode
+// path); it only hands back bytes and their length. containers.Vector
+// does not exist in this checkout, so nothing wraps MmappedVector in it
+// yet; Bytes/Length/Meta are the surface a future decode layer needs.
+type MmappedVector struct {
+	file *File
+	ref  ChunkRef
+}
+
+// NewMmappedVector wraps ref, a chunk already located within file (by
+// OpenDir's recovered entries or a freshly written Append), as a
+// MmappedVector.
+func NewMmappedVector(file *File, ref ChunkRef) *MmappedVector {
+	return &MmappedVector{file: file, ref: ref}
+}
+
+// Bytes returns the vector's raw column payload.
+func (v *MmappedVector) Bytes() ([]byte, error) {
+	return v.file.Read(v.ref)
+}
+
+// Length returns the payload's length in bytes, without reading it.
+func (v *MmappedVector) Length() int64 {
+	return v.ref.Length
+}
+
+// Meta returns which block/column this vector belongs to.
+func (v *MmappedVector) Meta() Meta {
+	return v.ref.Meta
+}
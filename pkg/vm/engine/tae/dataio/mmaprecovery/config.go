@@ -0,0 +1,50 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package mmaprecovery builds the map[BlockID][]Entry that lets tae.DB
+// skip rereading the object store for a block's already-flushed columns
+// on Restart: BuildIndex walks a blockchunk.Dir front to back (the same
+// way blockchunk.OpenDir does for a single segment, but across every
+// block recorded in it) before WAL replay starts, and tables.BlockData is
+// meant to attach the Entry slice for its BlockID as LoadFromMmap's
+// result instead of issuing object-store reads. It is independent of
+// catalog.BlockEntry and options.Options: the compact/append path is
+// left to decide, via Config.FlushThreshold, when a block has grown
+// large enough to be worth mmap-backing, and to call blockchunk.Dir.
+// Append once that threshold is crossed; options.Options is left to embed
+// Config as its MmapChunkCfg field and pass it down to wherever that
+// decision is made. tables.BlockData, catalog.BlockEntry, and
+// options.Options do not exist in this checkout, so nothing calls
+// BuildIndex or ShouldFlush yet; LazyReplay is the seam a future
+// tables.BlockData.LoadFromMmap plugs into once it does.
+package mmaprecovery
+
+// Config is what options.Options is meant to expose as MmapChunkCfg: where
+// the mmap chunk directory lives, and how large a block's pending column
+// data must grow before it's worth flushing into one rather than waiting
+// for the object-store path to pick it up.
+type Config struct {
+	// Dir is the mmap chunk directory, passed to blockchunk.OpenDir.
+	Dir string
+	// FlushThreshold is the number of bytes a block's unflushed column
+	// data must reach before it is flushed to an mmap chunk file. Zero
+	// disables the mmap fast path entirely.
+	FlushThreshold int64
+}
+
+// ShouldFlush reports whether pendingBytes of a block's unflushed column
+// data is enough to trigger a flush under cfg.
+func (cfg Config) ShouldFlush(pendingBytes int64) bool {
+	return cfg.FlushThreshold > 0 && pendingBytes >= cfg.FlushThreshold
+}
@@ -0,0 +1,48 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mmaprecovery
+
+import "github.com/matrixorigin/matrixone/pkg/vm/engine/tae/dataio/blockchunk"
+
+// Entry is one column's recovered mmapped chunk, resolved enough for
+// tables.BlockData.LoadFromMmap to read it back: which segment file it
+// lives in (pass to (*blockchunk.Dir).Open) and where within that segment
+// (pass the Ref to (*blockchunk.File).Read).
+type Entry struct {
+	Segment int
+	Ref     blockchunk.ChunkRef
+}
+
+// BuildIndex opens the mmap chunk directory at path (creating it if this
+// is a fresh DB) and returns every block's recovered Entry slice, keyed by
+// BlockID, plus the Dir itself so the caller can keep appending to it.
+// A torn or corrupt tail in the last segment is silently truncated away by
+// blockchunk's own recovery (see blockchunk.OpenDir): the corresponding
+// rows are presumed to still be reconstructable from WAL replay, so
+// BuildIndex surfaces no error for that case. A torn sealed (earlier)
+// segment is returned as an error, as is an I/O failure.
+func BuildIndex(path string) (dir *blockchunk.Dir, index map[uint64][]Entry, err error) {
+	dir, bySeg, err := blockchunk.OpenDir(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	index = make(map[uint64][]Entry)
+	for seq, refs := range bySeg {
+		for _, ref := range refs {
+			index[ref.Meta.BlockID] = append(index[ref.Meta.BlockID], Entry{Segment: seq, Ref: ref})
+		}
+	}
+	return dir, index, nil
+}
@@ -0,0 +1,91 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mmaprecovery
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/matrixorigin/matrixone/pkg/vm/engine/tae/dataio/blockchunk"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigShouldFlush(t *testing.T) {
+	cfg := Config{FlushThreshold: 1024}
+	assert.False(t, cfg.ShouldFlush(1023))
+	assert.True(t, cfg.ShouldFlush(1024))
+
+	disabled := Config{}
+	assert.False(t, disabled.ShouldFlush(1 << 30))
+}
+
+func TestBuildIndexGroupsByBlockID(t *testing.T) {
+	dir := t.TempDir()
+
+	d, _, err := blockchunk.OpenDir(dir)
+	require.NoError(t, err)
+	_, ref0, err := d.Append([]byte("col-0-data"), blockchunk.Meta{SegmentID: 1, BlockID: 9, ColumnID: 0})
+	require.NoError(t, err)
+	_, ref1, err := d.Append([]byte("col-1-data"), blockchunk.Meta{SegmentID: 1, BlockID: 9, ColumnID: 1})
+	require.NoError(t, err)
+	_, ref2, err := d.Append([]byte("other-block"), blockchunk.Meta{SegmentID: 1, BlockID: 10, ColumnID: 0})
+	require.NoError(t, err)
+	require.NoError(t, d.Sync())
+	require.NoError(t, d.Close())
+
+	reopened, index, err := BuildIndex(dir)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	require.Len(t, index[9], 2)
+	assert.Equal(t, ref0, index[9][0].Ref)
+	assert.Equal(t, ref1, index[9][1].Ref)
+	require.Len(t, index[10], 1)
+	assert.Equal(t, ref2, index[10][0].Ref)
+}
+
+func TestBuildIndexRecoversPastCorruptTail(t *testing.T) {
+	dir := t.TempDir()
+
+	d, _, err := blockchunk.OpenDir(dir)
+	require.NoError(t, err)
+	_, ref0, err := d.Append([]byte("intact record"), blockchunk.Meta{SegmentID: 1, BlockID: 1, ColumnID: 0})
+	require.NoError(t, err)
+	_, _, err = d.Append([]byte("torn record"), blockchunk.Meta{SegmentID: 1, BlockID: 1, ColumnID: 1})
+	require.NoError(t, err)
+	require.NoError(t, d.Sync())
+	require.NoError(t, d.Close())
+
+	segPath := filepath.Join(dir, "000000")
+	info, err := os.Stat(segPath)
+	require.NoError(t, err)
+	require.NoError(t, os.Truncate(segPath, info.Size()-3))
+
+	reopened, index, err := BuildIndex(dir)
+	require.NoError(t, err)
+	defer reopened.Close()
+
+	// Only the record that survives the CRC check is indexed; the torn
+	// column is left for WAL replay to reconstruct.
+	require.Len(t, index[1], 1)
+	assert.Equal(t, ref0, index[1][0].Ref)
+
+	// Recovery must leave the directory appendable past the torn tail.
+	_, ref1, err := reopened.Append([]byte("replacement"), blockchunk.Meta{SegmentID: 1, BlockID: 1, ColumnID: 1})
+	require.NoError(t, err)
+	require.NotZero(t, ref1.Offset)
+}
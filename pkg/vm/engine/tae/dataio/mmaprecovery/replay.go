@@ -0,0 +1,135 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package mmaprecovery
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/matrixorigin/matrixone/pkg/vm/engine/tae/dataio/blockchunk"
+)
+
+// Decoder turns one column's raw mmapped payload into whatever value
+// catalog bootstrap expects back (a containers.Vector in the real
+// engine; kept as `any` here so this package stays independent of
+// containers).
+type Decoder func(payload []byte) (any, error)
+
+// WALRebuilder reconstructs a block's column values by replaying the WAL
+// segment that covers it - the fallback LazyReplay uses when a chunk
+// BuildIndex recovered turns out corrupt at decode time. Wal.
+// RangeCheckpoint already proves the WAL alone can reconstruct state
+// after a checkpoint truncation, so this is not a new recovery path, only
+// a new trigger for the existing one.
+type WALRebuilder interface {
+	RebuildFromWAL(ctx context.Context, blockID uint64) ([]any, error)
+}
+
+// LazyReplay serves a block's column data on first access instead of up
+// front: BuildIndex only records where each column's chunk lives, and
+// Get mmap-reads and decodes it the first time something asks for that
+// block, so catalog bootstrap's RSS scales with the index rather than
+// with the full on-disk footprint. A chunk that fails to decode - torn
+// by corruption BuildIndex's own CRC check didn't catch because it lived
+// past the sealed segment's valid prefix - falls back to wal rather than
+// failing Get outright.
+type LazyReplay struct {
+	dir    *blockchunk.Dir
+	index  map[uint64][]Entry
+	decode Decoder
+	wal    WALRebuilder
+
+	mu    sync.Mutex
+	files map[int]*blockchunk.File
+}
+
+// NewLazyReplay wraps dir and index (as BuildIndex returned them) with
+// decode and wal, ready to serve Get calls. wal may be nil, in which case
+// a decode failure is returned as an error instead of being repaired.
+func NewLazyReplay(dir *blockchunk.Dir, index map[uint64][]Entry, decode Decoder, wal WALRebuilder) *LazyReplay {
+	return &LazyReplay{dir: dir, index: index, decode: decode, wal: wal, files: make(map[int]*blockchunk.File)}
+}
+
+// Get returns blockID's column values in index order, mmap-reading and
+// decoding each one's chunk on this first access and caching nothing
+// beyond the open segment file itself. Any I/O or decode failure for
+// blockID triggers a full WAL rebuild of the block rather than returning
+// a partially-decoded result.
+func (r *LazyReplay) Get(ctx context.Context, blockID uint64) ([]any, error) {
+	entries, ok := r.index[blockID]
+	if !ok {
+		return nil, fmt.Errorf("mmaprecovery: no recovered chunk for block %d", blockID)
+	}
+
+	values := make([]any, 0, len(entries))
+	for _, e := range entries {
+		v, err := r.readAndDecodeLocked(e.Segment, e.Ref)
+		if err != nil {
+			return r.rebuild(ctx, blockID)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
+
+func (r *LazyReplay) rebuild(ctx context.Context, blockID uint64) ([]any, error) {
+	if r.wal == nil {
+		return nil, fmt.Errorf("mmaprecovery: block %d failed to decode and no WAL fallback is configured", blockID)
+	}
+	return r.wal.RebuildFromWAL(ctx, blockID)
+}
+
+// readAndDecodeLocked opens (or reuses) segment seq's mmapped File, reads
+// ref from it, and decodes the result, all without ever releasing r.mu in
+// between: Release closes a segment's File under the same lock, e.g. from
+// a concurrent BGCheckpointRunner.GCByTS pass, so f.Read's returned slice
+// is a zero-copy view into the mmap (see blockchunk.File.Read) that stays
+// valid only as long as the segment remains mapped. Decoding outside the
+// lock - even right after Read, before the next loop iteration - would
+// leave a window where GCByTS's Release could unmap the segment while
+// r.decode is still reading from it.
+func (r *LazyReplay) readAndDecodeLocked(seq int, ref blockchunk.ChunkRef) (any, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, ok := r.files[seq]
+	if !ok {
+		var err error
+		f, err = r.dir.Open(seq)
+		if err != nil {
+			return nil, err
+		}
+		r.files[seq] = f
+	}
+	payload, err := f.Read(ref)
+	if err != nil {
+		return nil, err
+	}
+	return r.decode(payload)
+}
+
+// Release closes (unmaps) segment seq's mmapped file, for a caller to
+// invoke once BGCheckpointRunner.GCByTS confirms nothing in that segment
+// is reachable anymore. It is a no-op if seq was never opened by Get.
+func (r *LazyReplay) Release(seq int) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f, ok := r.files[seq]
+	if !ok {
+		return nil
+	}
+	delete(r.files, seq)
+	return f.Close()
+}
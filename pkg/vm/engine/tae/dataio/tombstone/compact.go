@@ -0,0 +1,58 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tombstone
+
+import "fmt"
+
+// Compact merges the tombstone files at paths (all covering the same
+// segment) into a single new file at outPath, deduplicating any
+// (BlockID, RowOffset) entry more than one input file recorded - the
+// overlapping-interval case a heavy-delete workload produces when the
+// same row is re-targeted by more than one bulk DELETE before a merge
+// runs. It is the on-disk counterpart of how jobs.CompactBlockTask folds
+// many small blocks into one: where that rewrites column data,
+// Compact only ever rewrites the tombstone index, so it is cheap enough
+// to run as a background pass whenever a segment accumulates more than a
+// few tombstone files.
+//
+// Compact does not delete the input files; the caller (the background
+// tombstone compactor, or jobs.MergeTombstones once it exists) is
+// responsible for doing so only after outPath has been fsynced, so a
+// crash mid-merge never loses a tombstoned row.
+func Compact(paths []string, outPath string) error {
+	seen := make(map[Entry]struct{})
+	w := NewWriter()
+	for _, p := range paths {
+		r, err := Open(p)
+		if err != nil {
+			return fmt.Errorf("tombstone: compact %s: %w", p, err)
+		}
+		entries, err := r.All()
+		if err != nil {
+			return fmt.Errorf("tombstone: compact %s: %w", p, err)
+		}
+		for _, e := range entries {
+			if _, dup := seen[e]; dup {
+				continue
+			}
+			seen[e] = struct{}{}
+			w.Add(e.BlockID, e.RowOffset)
+		}
+	}
+	if err := w.Write(outPath); err != nil {
+		return fmt.Errorf("tombstone: compact write %s: %w", outPath, err)
+	}
+	return nil
+}
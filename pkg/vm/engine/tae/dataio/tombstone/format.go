@@ -0,0 +1,105 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package tombstone implements a dedicated on-disk file format for
+// persisted deletes, modeled on Prometheus' tombstones file but laid out
+// for TAE's segment/block addressing instead of a single flat series
+// space. Entries are (BlockID, RowOffset) pairs sorted ascending and
+// packed into fixed-size, CRC32-checked pages; a small index at the tail
+// maps each page to the [firstBlockID, lastBlockID] range it covers, so
+// Reader.Lookup can binary-search straight to the pages a given block's
+// rows could be in without scanning the whole file.
+//
+// This replaces the one-object-per-TryDeleteByDeltaloc-call pattern
+// db_test.go's TestApplyDeltalocation2 exercises today: instead of every
+// bulk DELETE producing its own delta-location object that CompactBlocks
+// later has to fold in, a caller can write one Writer per batch and a
+// background pass running Compact (compact.go) merges many small tombstone files for
+// the same segment into one, the same way CompactBlockTask folds many
+// small blocks into one. It doesn't know about catalog.BlockEntry or
+// Relation.DeleteByDeltaloc: those are left to decide how a CN-pushed
+// bulk DELETE turns into entries for a Writer and how a merged File's
+// path becomes a new delta-location for the blocks it covers.
+package tombstone
+
+import "encoding/binary"
+
+const (
+	// magic tags a file as belonging to this format.
+	magic = "TTF1"
+	// footerMagic closes the file so a reader can tell a truncated write
+	// never reached the end of Writer.Write.
+	footerMagic = "TTF1END"
+
+	// entrySize is the encoded size of one (BlockID, RowOffset) entry.
+	entrySize = 8 + 4
+	// pageMaxEntries bounds how many entries one page holds before the
+	// page is sealed with its CRC32 and a new one is started, so a reader
+	// only needs to checksum the page it's actually scanning.
+	pageMaxEntries = 4096
+	// pageHeaderSize is the 4-byte entry count prefixing every page.
+	pageHeaderSize = 4
+	// pageCRCSize is the 4-byte CRC32 suffix closing every page.
+	pageCRCSize = 4
+
+	// indexEntrySize is the encoded size of one tail index record:
+	// pageOffset, pageLength, firstBlockID, lastBlockID.
+	indexEntrySize = 8 + 8 + 8 + 8
+	// footerSize is the fixed trailer: index offset, index entry count,
+	// and footerMagic.
+	footerSize = 8 + 4 + len(footerMagic)
+)
+
+// Entry is one tombstoned row: RowOffset within BlockID.
+type Entry struct {
+	BlockID   uint64
+	RowOffset uint32
+}
+
+func encodeEntry(b []byte, e Entry) {
+	binary.LittleEndian.PutUint64(b[0:8], e.BlockID)
+	binary.LittleEndian.PutUint32(b[8:12], e.RowOffset)
+}
+
+func decodeEntry(b []byte) Entry {
+	return Entry{
+		BlockID:   binary.LittleEndian.Uint64(b[0:8]),
+		RowOffset: binary.LittleEndian.Uint32(b[8:12]),
+	}
+}
+
+// pageIndex is one tail index record: where page lives and the inclusive
+// [FirstBlockID, LastBlockID] range of entries it holds.
+type pageIndex struct {
+	Offset       int64
+	Length       int64
+	FirstBlockID uint64
+	LastBlockID  uint64
+}
+
+func encodePageIndex(b []byte, p pageIndex) {
+	binary.LittleEndian.PutUint64(b[0:8], uint64(p.Offset))
+	binary.LittleEndian.PutUint64(b[8:16], uint64(p.Length))
+	binary.LittleEndian.PutUint64(b[16:24], p.FirstBlockID)
+	binary.LittleEndian.PutUint64(b[24:32], p.LastBlockID)
+}
+
+func decodePageIndex(b []byte) pageIndex {
+	return pageIndex{
+		Offset:       int64(binary.LittleEndian.Uint64(b[0:8])),
+		Length:       int64(binary.LittleEndian.Uint64(b[8:16])),
+		FirstBlockID: binary.LittleEndian.Uint64(b[16:24]),
+		LastBlockID:  binary.LittleEndian.Uint64(b[24:32]),
+	}
+}
@@ -0,0 +1,120 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tombstone
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"sort"
+)
+
+// Reader is a read-only, whole-file view of a tombstone file. Tombstone
+// files are small (one bulk DELETE's worth of rows, or Compact's
+// merge of many), so Reader reads the file into memory once rather than
+// mmapping it the way the larger, longer-lived dataio/blockchunk and
+// dataio/deletechunk segments do.
+type Reader struct {
+	path  string
+	data  []byte
+	pages []pageIndex
+}
+
+// Open reads path and validates its footer, returning a Reader ready for
+// Lookup.
+func Open(path string) (*Reader, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("tombstone: read %s: %w", path, err)
+	}
+	if len(data) < len(magic)+footerSize || string(data[:len(magic)]) != magic {
+		return nil, fmt.Errorf("tombstone: %s is not a tombstone file", path)
+	}
+	footer := data[len(data)-footerSize:]
+	if string(footer[12:]) != footerMagic {
+		return nil, fmt.Errorf("tombstone: %s footer is missing or truncated", path)
+	}
+	indexOffset := int64(binary.LittleEndian.Uint64(footer[0:8]))
+	indexCount := int(binary.LittleEndian.Uint32(footer[8:12]))
+
+	indexEnd := int64(len(data)) - int64(footerSize)
+	if indexOffset < 0 || indexOffset+int64(indexCount)*indexEntrySize != indexEnd {
+		return nil, fmt.Errorf("tombstone: %s index is truncated", path)
+	}
+	pages := make([]pageIndex, indexCount)
+	for i := 0; i < indexCount; i++ {
+		b := data[indexOffset+int64(i)*indexEntrySize : indexOffset+int64(i+1)*indexEntrySize]
+		pages[i] = decodePageIndex(b)
+	}
+
+	return &Reader{path: path, data: data, pages: pages}, nil
+}
+
+// Lookup returns every Entry for blockID, validating the CRC32 of each
+// page it has to open and returning an error if one does not match. Pages outside blockID's range, per
+// the tail index, are never even checksummed.
+func (r *Reader) Lookup(blockID uint64) ([]Entry, error) {
+	start := sort.Search(len(r.pages), func(i int) bool {
+		return r.pages[i].LastBlockID >= blockID
+	})
+
+	var out []Entry
+	for i := start; i < len(r.pages) && r.pages[i].FirstBlockID <= blockID; i++ {
+		entries, err := r.readPage(r.pages[i])
+		if err != nil {
+			return nil, err
+		}
+		for _, e := range entries {
+			if e.BlockID == blockID {
+				out = append(out, e)
+			}
+		}
+	}
+	return out, nil
+}
+
+// All returns every entry in the file, in (BlockID, RowOffset) order,
+// e.g. for Compact merging several files together.
+func (r *Reader) All() ([]Entry, error) {
+	var out []Entry
+	for _, p := range r.pages {
+		entries, err := r.readPage(p)
+		if err != nil {
+			return nil, err
+		}
+		out = append(out, entries...)
+	}
+	return out, nil
+}
+
+func (r *Reader) readPage(p pageIndex) ([]Entry, error) {
+	if p.Offset < 0 || p.Offset+p.Length > int64(len(r.data)) {
+		return nil, fmt.Errorf("tombstone: %s page at %d out of range", r.path, p.Offset)
+	}
+	raw := r.data[p.Offset : p.Offset+p.Length]
+	body := raw[:len(raw)-pageCRCSize]
+	wantCRC := binary.LittleEndian.Uint32(raw[len(raw)-pageCRCSize:])
+	if crc32.Checksum(body, crcTable) != wantCRC {
+		return nil, fmt.Errorf("tombstone: %s page at %d fails checksum", r.path, p.Offset)
+	}
+	count := binary.LittleEndian.Uint32(body[0:pageHeaderSize])
+	entries := make([]Entry, count)
+	for i := range entries {
+		off := pageHeaderSize + i*entrySize
+		entries[i] = decodeEntry(body[off : off+entrySize])
+	}
+	return entries, nil
+}
@@ -0,0 +1,120 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tombstone
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriterReaderRoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tombstone-0")
+
+	w := NewWriter()
+	w.Add(5, 2)
+	w.Add(3, 1)
+	w.Add(5, 0)
+	w.Add(9, 100)
+	require.Equal(t, 4, w.Len())
+	require.NoError(t, w.Write(path))
+
+	r, err := Open(path)
+	require.NoError(t, err)
+
+	got, err := r.Lookup(5)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []Entry{{BlockID: 5, RowOffset: 0}, {BlockID: 5, RowOffset: 2}}, got)
+
+	got, err = r.Lookup(3)
+	require.NoError(t, err)
+	assert.Equal(t, []Entry{{BlockID: 3, RowOffset: 1}}, got)
+
+	got, err = r.Lookup(7)
+	require.NoError(t, err)
+	assert.Empty(t, got)
+
+	all, err := r.All()
+	require.NoError(t, err)
+	assert.Len(t, all, 4)
+}
+
+func TestWriterSpansMultiplePages(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tombstone-0")
+
+	w := NewWriter()
+	for i := 0; i < pageMaxEntries*2+17; i++ {
+		w.Add(uint64(i/10), uint32(i))
+	}
+	require.NoError(t, w.Write(path))
+
+	r, err := Open(path)
+	require.NoError(t, err)
+	assert.Equal(t, 3, len(r.pages))
+
+	all, err := r.All()
+	require.NoError(t, err)
+	assert.Len(t, all, pageMaxEntries*2+17)
+
+	got, err := r.Lookup(0)
+	require.NoError(t, err)
+	assert.Len(t, got, 10)
+}
+
+func TestOpenRejectsTruncatedFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tombstone-0")
+	w := NewWriter()
+	w.Add(1, 1)
+	require.NoError(t, w.Write(path))
+
+	raw, err := os.ReadFile(path)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, raw[:len(raw)-2], 0644))
+
+	_, err = Open(path)
+	assert.Error(t, err)
+}
+
+func TestCompactMergesAndDedupes(t *testing.T) {
+	dir := t.TempDir()
+
+	w1 := NewWriter()
+	w1.Add(1, 0)
+	w1.Add(1, 1)
+	p1 := filepath.Join(dir, "a")
+	require.NoError(t, w1.Write(p1))
+
+	w2 := NewWriter()
+	w2.Add(1, 1) // overlaps w1
+	w2.Add(2, 5)
+	p2 := filepath.Join(dir, "b")
+	require.NoError(t, w2.Write(p2))
+
+	out := filepath.Join(dir, "merged")
+	require.NoError(t, Compact([]string{p1, p2}, out))
+
+	r, err := Open(out)
+	require.NoError(t, err)
+	all, err := r.All()
+	require.NoError(t, err)
+	assert.Len(t, all, 3)
+
+	got, err := r.Lookup(1)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []Entry{{BlockID: 1, RowOffset: 0}, {BlockID: 1, RowOffset: 1}}, got)
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package tombstone
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"sort"
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// Writer builds a tombstone file from a caller-supplied set of entries.
+// Unlike dataio/blockchunk and dataio/deletechunk, which append records
+// as the caller produces them, a tombstone file must be sorted by
+// (BlockID, RowOffset) before the tail index can be built, so Writer
+// buffers entries in memory and only touches disk on Write - tombstone
+// files are expected to be small (one bulk DELETE's worth of rows) and
+// short-lived until Compact folds them together.
+type Writer struct {
+	entries []Entry
+}
+
+// NewWriter returns an empty Writer.
+func NewWriter() *Writer {
+	return &Writer{}
+}
+
+// Add records that row RowOffset of block BlockID was deleted. Order of
+// calls does not matter; Write sorts before persisting.
+func (w *Writer) Add(blockID uint64, rowOffset uint32) {
+	w.entries = append(w.entries, Entry{BlockID: blockID, RowOffset: rowOffset})
+}
+
+// Len reports how many entries have been added.
+func (w *Writer) Len() int {
+	return len(w.entries)
+}
+
+// Write sorts the buffered entries and writes them as a complete
+// tombstone file at path, failing if path already exists. The file is
+// fsynced before Write returns, so a reader never observes a partially
+// written file through a successful Write.
+func (w *Writer) Write(path string) error {
+	sorted := append([]Entry(nil), w.entries...)
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].BlockID != sorted[j].BlockID {
+			return sorted[i].BlockID < sorted[j].BlockID
+		}
+		return sorted[i].RowOffset < sorted[j].RowOffset
+	})
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_RDWR, 0644)
+	if err != nil {
+		return fmt.Errorf("tombstone: create %s: %w", path, err)
+	}
+	defer f.Close()
+
+	if _, err := f.WriteString(magic); err != nil {
+		return fmt.Errorf("tombstone: write header %s: %w", path, err)
+	}
+	offset := int64(len(magic))
+
+	var pages []pageIndex
+	for start := 0; start < len(sorted); start += pageMaxEntries {
+		end := start + pageMaxEntries
+		if end > len(sorted) {
+			end = len(sorted)
+		}
+		page := sorted[start:end]
+		body := make([]byte, pageHeaderSize+len(page)*entrySize)
+		binary.LittleEndian.PutUint32(body[0:pageHeaderSize], uint32(len(page)))
+		for i, e := range page {
+			encodeEntry(body[pageHeaderSize+i*entrySize:], e)
+		}
+		crc := crc32.Checksum(body, crcTable)
+		if _, err := f.Write(body); err != nil {
+			return fmt.Errorf("tombstone: write page %s: %w", path, err)
+		}
+		var crcBuf [pageCRCSize]byte
+		binary.LittleEndian.PutUint32(crcBuf[:], crc)
+		if _, err := f.Write(crcBuf[:]); err != nil {
+			return fmt.Errorf("tombstone: write page crc %s: %w", path, err)
+		}
+		pageLen := int64(len(body) + pageCRCSize)
+		pages = append(pages, pageIndex{
+			Offset:       offset,
+			Length:       pageLen,
+			FirstBlockID: page[0].BlockID,
+			LastBlockID:  page[len(page)-1].BlockID,
+		})
+		offset += pageLen
+	}
+
+	indexOffset := offset
+	for _, p := range pages {
+		var buf [indexEntrySize]byte
+		encodePageIndex(buf[:], p)
+		if _, err := f.Write(buf[:]); err != nil {
+			return fmt.Errorf("tombstone: write index %s: %w", path, err)
+		}
+	}
+
+	var footer [footerSize]byte
+	binary.LittleEndian.PutUint64(footer[0:8], uint64(indexOffset))
+	binary.LittleEndian.PutUint32(footer[8:12], uint32(len(pages)))
+	copy(footer[12:], footerMagic)
+	if _, err := f.Write(footer[:]); err != nil {
+		return fmt.Errorf("tombstone: write footer %s: %w", path, err)
+	}
+
+	return f.Sync()
+}
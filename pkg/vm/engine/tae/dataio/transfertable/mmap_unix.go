@@ -0,0 +1,58 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+//go:build !windows
+
+package transfertable
+
+import (
+	"fmt"
+	"os"
+
+	"golang.org/x/sys/unix"
+)
+
+// mmapData is the unix backend: a spilled run's whole contents are
+// mapped directly from the page cache, so a lookup never copies the
+// run's records off disk.
+type mmapData struct {
+	b []byte
+}
+
+func mmapOpen(path string, size int64) (mmapData, error) {
+	if size == 0 {
+		return mmapData{}, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return mmapData{}, err
+	}
+	defer f.Close()
+	b, err := unix.Mmap(int(f.Fd()), 0, int(size), unix.PROT_READ, unix.MAP_SHARED)
+	if err != nil {
+		return mmapData{}, fmt.Errorf("mmap: %w", err)
+	}
+	return mmapData{b: b}, nil
+}
+
+func (m mmapData) Bytes() []byte {
+	return m.b
+}
+
+func (m mmapData) Close() error {
+	if m.b == nil {
+		return nil
+	}
+	return unix.Munmap(m.b)
+}
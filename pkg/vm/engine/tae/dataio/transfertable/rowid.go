@@ -0,0 +1,55 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfertable
+
+import "encoding/binary"
+
+// rowidSize is the fixed, encoded size of a Rowid.
+const rowidSize = 8 + 8 + 4
+
+// Rowid locates one row within a segment's block, the same triple
+// catalog.BlockEntry callers already identify a row by. It is comparable,
+// so it can be used directly as a map key.
+type Rowid struct {
+	SegmentID uint64
+	BlockID   uint64
+	Offset    uint32
+}
+
+func (r Rowid) encode(b []byte) {
+	binary.LittleEndian.PutUint64(b[0:8], r.SegmentID)
+	binary.LittleEndian.PutUint64(b[8:16], r.BlockID)
+	binary.LittleEndian.PutUint32(b[16:20], r.Offset)
+}
+
+func decodeRowid(b []byte) Rowid {
+	return Rowid{
+		SegmentID: binary.LittleEndian.Uint64(b[0:8]),
+		BlockID:   binary.LittleEndian.Uint64(b[8:16]),
+		Offset:    binary.LittleEndian.Uint32(b[16:20]),
+	}
+}
+
+// Less orders two Rowids, first by SegmentID, then BlockID, then Offset -
+// the order spilled runs are sorted and binary-searched in.
+func (r Rowid) Less(o Rowid) bool {
+	if r.SegmentID != o.SegmentID {
+		return r.SegmentID < o.SegmentID
+	}
+	if r.BlockID != o.BlockID {
+		return r.BlockID < o.BlockID
+	}
+	return r.Offset < o.Offset
+}
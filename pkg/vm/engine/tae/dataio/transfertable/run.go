@@ -0,0 +1,138 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfertable
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"sort"
+
+	"github.com/matrixorigin/matrixone/pkg/container/types"
+)
+
+const (
+	runMagic      = "TTR1"
+	runHeaderSize = 4 + 4 // magic + record count
+	recordSize    = rowidSize + rowidSize
+)
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// record is one (old -> new) mapping a spilled run holds.
+type record struct {
+	old Rowid
+	new Rowid
+}
+
+// writeRun writes records, which must already be sorted by old Rowid, to
+// path as a single mmap-able file: a magic/count header, the sorted
+// records, and a trailing CRC32 over everything before it.
+func writeRun(path string, records []record) error {
+	body := make([]byte, runHeaderSize+len(records)*recordSize)
+	copy(body[0:4], runMagic)
+	binary.LittleEndian.PutUint32(body[4:8], uint32(len(records)))
+	off := runHeaderSize
+	for _, r := range records {
+		r.old.encode(body[off : off+rowidSize])
+		r.new.encode(body[off+rowidSize : off+recordSize])
+		off += recordSize
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if err != nil {
+		return fmt.Errorf("transfertable: create run %s: %w", path, err)
+	}
+	defer f.Close()
+	if _, err := f.Write(body); err != nil {
+		return fmt.Errorf("transfertable: write run %s: %w", path, err)
+	}
+	var crc [4]byte
+	binary.LittleEndian.PutUint32(crc[:], crc32.Checksum(body, crcTable))
+	if _, err := f.Write(crc[:]); err != nil {
+		return fmt.Errorf("transfertable: write run crc %s: %w", path, err)
+	}
+	return f.Sync()
+}
+
+// run is a spilled, sorted batch of transfer-table entries, mmap'd once
+// and looked up with no further syscalls.
+type run struct {
+	path  string
+	mmap  mmapData
+	count int
+	minTS types.TS
+	maxTS types.TS
+}
+
+// openRun mmaps path and validates it, tagging the run with the
+// [minTS, maxTS] range of createdAt timestamps its caller spilled, so TTL
+// can decide whether every entry in it is safe to forget without
+// re-deriving that from the (timestamp-less) on-disk format.
+func openRun(path string, minTS, maxTS types.TS) (*run, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("transfertable: stat run %s: %w", path, err)
+	}
+	m, err := mmapOpen(path, info.Size())
+	if err != nil {
+		return nil, fmt.Errorf("transfertable: mmap run %s: %w", path, err)
+	}
+	b := m.Bytes()
+	if len(b) < runHeaderSize+4 {
+		_ = m.Close()
+		return nil, fmt.Errorf("transfertable: run %s shorter than header", path)
+	}
+	if string(b[0:4]) != runMagic {
+		_ = m.Close()
+		return nil, fmt.Errorf("transfertable: run %s has bad magic", path)
+	}
+	count := int(binary.LittleEndian.Uint32(b[4:8]))
+	body := b[:len(b)-4]
+	wantCRC := binary.LittleEndian.Uint32(b[len(b)-4:])
+	if crc32.Checksum(body, crcTable) != wantCRC {
+		_ = m.Close()
+		return nil, fmt.Errorf("transfertable: run %s failed CRC check", path)
+	}
+	if len(body) != runHeaderSize+count*recordSize {
+		_ = m.Close()
+		return nil, fmt.Errorf("transfertable: run %s has inconsistent record count", path)
+	}
+	return &run{path: path, mmap: m, count: count, minTS: minTS, maxTS: maxTS}, nil
+}
+
+// lookup binary-searches the run for old, returning its transferred
+// Rowid if present.
+func (r *run) lookup(old Rowid) (Rowid, bool) {
+	b := r.mmap.Bytes()
+	i := sort.Search(r.count, func(i int) bool {
+		off := runHeaderSize + i*recordSize
+		return !decodeRowid(b[off:off+rowidSize]).Less(old)
+	})
+	if i >= r.count {
+		return Rowid{}, false
+	}
+	off := runHeaderSize + i*recordSize
+	got := decodeRowid(b[off : off+rowidSize])
+	if got != old {
+		return Rowid{}, false
+	}
+	return decodeRowid(b[off+rowidSize : off+recordSize]), true
+}
+
+func (r *run) close() error {
+	return r.mmap.Close()
+}
@@ -0,0 +1,216 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package transfertable implements the pre-merge -> post-merge rowid map
+// jobs.NewMergeBlocksTask and jobs.NewCompactBlockTask publish to so a
+// txn started before a merge commits can still resolve a rowid it read
+// against the pre-merge block. Kept entirely in memory, that map is
+// unbounded for the lifetime of any concurrent txn touching the merged
+// segment; Table instead caps how many entries stay resident and spills
+// the rest as sorted, mmap-able runs under a caller-given directory (the
+// same fs.Service-backed block directory, in the real wiring), looked up
+// with a binary search instead of a full scan.
+//
+// It doesn't know what a merge or compact task actually is: Put is meant
+// to be called once per transferred row as either task commits, and TTL
+// is meant to be called with the oldest active reader txn's start-ts
+// (from the txn manager) whenever it advances, so entries older than
+// every txn that could still need them are dropped instead of kept
+// forever.
+package transfertable
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/matrixorigin/matrixone/pkg/container/types"
+)
+
+type residentEntry struct {
+	new       Rowid
+	createdAt types.TS
+}
+
+// Metrics are the current gauges Table.Metrics reports; each one drops
+// again as entries are spilled or dropped by TTL.
+type Metrics struct {
+	ResidentEntries int64
+	SpilledEntries  int64
+	SpilledRuns     int64
+}
+
+// Table is a Put/Lookup/TTL map from pre-merge to post-merge Rowid,
+// bounded to at most memCapEntries resident in memory at once. It is
+// safe for concurrent use.
+type Table struct {
+	dir           string
+	memCapEntries int
+	nextRunSeq    int
+
+	mu       sync.RWMutex
+	resident map[Rowid]residentEntry
+	runs     []*run // oldest spilled run first
+
+	residentGauge, spilledGauge, runsGauge atomic.Int64
+}
+
+// NewTable opens (creating if necessary) dir as this Table's spill
+// directory and returns an empty Table capped at memCapEntries resident
+// entries.
+func NewTable(dir string, memCapEntries int) (*Table, error) {
+	if memCapEntries <= 0 {
+		return nil, fmt.Errorf("transfertable: memCapEntries must be positive, got %d", memCapEntries)
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, fmt.Errorf("transfertable: create dir %s: %w", dir, err)
+	}
+	return &Table{
+		dir:           dir,
+		memCapEntries: memCapEntries,
+		resident:      make(map[Rowid]residentEntry),
+	}, nil
+}
+
+// Put records that old was transferred to new as of createdAt (the
+// merge or compact task's commit ts), first spilling every resident entry
+// to a new run on disk if the resident set already holds memCapEntries.
+func (t *Table) Put(old, new Rowid, createdAt types.TS) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if len(t.resident) >= t.memCapEntries {
+		if err := t.spillLocked(); err != nil {
+			return err
+		}
+	}
+	if _, exists := t.resident[old]; !exists {
+		t.residentGauge.Add(1)
+	}
+	t.resident[old] = residentEntry{new: new, createdAt: createdAt}
+	return nil
+}
+
+// spillLocked flushes every currently-resident entry to a new sorted run
+// on disk and clears the in-memory map. Called with t.mu held.
+func (t *Table) spillLocked() error {
+	if len(t.resident) == 0 {
+		return nil
+	}
+	records := make([]record, 0, len(t.resident))
+	minTS, maxTS := types.TS{}, types.TS{}
+	first := true
+	for old, e := range t.resident {
+		records = append(records, record{old: old, new: e.new})
+		if first || e.createdAt.Less(minTS) {
+			minTS = e.createdAt
+		}
+		if first || maxTS.Less(e.createdAt) {
+			maxTS = e.createdAt
+		}
+		first = false
+	}
+	sort.Slice(records, func(i, j int) bool { return records[i].old.Less(records[j].old) })
+
+	path := filepath.Join(t.dir, fmt.Sprintf("%08d.run", t.nextRunSeq))
+	t.nextRunSeq++
+	if err := writeRun(path, records); err != nil {
+		return err
+	}
+	r, err := openRun(path, minTS, maxTS)
+	if err != nil {
+		return err
+	}
+
+	t.runs = append(t.runs, r)
+	t.residentGauge.Add(-int64(len(t.resident)))
+	t.spilledGauge.Add(int64(len(records)))
+	t.runsGauge.Add(1)
+	t.resident = make(map[Rowid]residentEntry)
+	return nil
+}
+
+// Lookup returns old's transferred Rowid, checking the resident map
+// first and then spilled runs from newest to oldest.
+func (t *Table) Lookup(old Rowid) (Rowid, bool) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	if e, ok := t.resident[old]; ok {
+		return e.new, true
+	}
+	for i := len(t.runs) - 1; i >= 0; i-- {
+		if new, ok := t.runs[i].lookup(old); ok {
+			return new, true
+		}
+	}
+	return Rowid{}, false
+}
+
+// TTL drops every resident entry and spilled run whose createdAt (or, for
+// a run, whose latest createdAt) is strictly before ts, since no txn
+// reading at ts or later can have a view old enough to still need them.
+// A spilled run is only dropped as a whole once every entry in it
+// qualifies; this never partially rewrites a run.
+func (t *Table) TTL(ts types.TS) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for old, e := range t.resident {
+		if e.createdAt.Less(ts) {
+			delete(t.resident, old)
+			t.residentGauge.Add(-1)
+		}
+	}
+
+	kept := t.runs[:0]
+	for _, r := range t.runs {
+		if r.maxTS.Less(ts) {
+			t.spilledGauge.Add(-int64(r.count))
+			t.runsGauge.Add(-1)
+			if err := r.close(); err != nil {
+				return err
+			}
+			if err := os.Remove(r.path); err != nil {
+				return err
+			}
+			continue
+		}
+		kept = append(kept, r)
+	}
+	t.runs = kept
+	return nil
+}
+
+// Metrics returns the table's current resident/spilled footprint.
+func (t *Table) Metrics() Metrics {
+	return Metrics{
+		ResidentEntries: t.residentGauge.Load(),
+		SpilledEntries:  t.spilledGauge.Load(),
+		SpilledRuns:     t.runsGauge.Load(),
+	}
+}
+
+// Close releases every spilled run's mmap without removing it from disk.
+func (t *Table) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, r := range t.runs {
+		if err := r.close(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package transfertable
+
+import (
+	"testing"
+
+	"github.com/matrixorigin/matrixone/pkg/container/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ts(physical int64) types.TS {
+	return types.BuildTS(physical, 0)
+}
+
+func TestPutLookupRoundTripsResident(t *testing.T) {
+	tbl, err := NewTable(t.TempDir(), 64)
+	require.NoError(t, err)
+	defer tbl.Close()
+
+	old := Rowid{SegmentID: 1, BlockID: 2, Offset: 3}
+	new := Rowid{SegmentID: 1, BlockID: 9, Offset: 0}
+	require.NoError(t, tbl.Put(old, new, ts(10)))
+
+	got, ok := tbl.Lookup(old)
+	require.True(t, ok)
+	assert.Equal(t, new, got)
+
+	_, ok = tbl.Lookup(Rowid{SegmentID: 1, BlockID: 2, Offset: 4})
+	assert.False(t, ok)
+	assert.Equal(t, int64(1), tbl.Metrics().ResidentEntries)
+}
+
+func TestPutSpillsResidentSetWhenCapReached(t *testing.T) {
+	tbl, err := NewTable(t.TempDir(), 4)
+	require.NoError(t, err)
+	defer tbl.Close()
+
+	for i := uint64(0); i < 8; i++ {
+		old := Rowid{SegmentID: 1, BlockID: i, Offset: 0}
+		new := Rowid{SegmentID: 2, BlockID: i, Offset: 0}
+		require.NoError(t, tbl.Put(old, new, ts(int64(i))))
+	}
+
+	m := tbl.Metrics()
+	assert.Greater(t, m.SpilledRuns, int64(0))
+	assert.Greater(t, m.SpilledEntries, int64(0))
+
+	for i := uint64(0); i < 8; i++ {
+		got, ok := tbl.Lookup(Rowid{SegmentID: 1, BlockID: i, Offset: 0})
+		require.True(t, ok, "block %d should resolve from resident or spilled run", i)
+		assert.Equal(t, Rowid{SegmentID: 2, BlockID: i, Offset: 0}, got)
+	}
+}
+
+func TestLookupMissReturnsFalse(t *testing.T) {
+	tbl, err := NewTable(t.TempDir(), 4)
+	require.NoError(t, err)
+	defer tbl.Close()
+
+	for i := uint64(0); i < 8; i++ {
+		require.NoError(t, tbl.Put(
+			Rowid{SegmentID: 1, BlockID: i, Offset: 0},
+			Rowid{SegmentID: 2, BlockID: i, Offset: 0},
+			ts(int64(i)),
+		))
+	}
+
+	_, ok := tbl.Lookup(Rowid{SegmentID: 1, BlockID: 99, Offset: 0})
+	assert.False(t, ok)
+}
+
+func TestTTLDropsResidentAndWholeSpilledRuns(t *testing.T) {
+	tbl, err := NewTable(t.TempDir(), 4)
+	require.NoError(t, err)
+	defer tbl.Close()
+
+	for i := uint64(0); i < 8; i++ {
+		require.NoError(t, tbl.Put(
+			Rowid{SegmentID: 1, BlockID: i, Offset: 0},
+			Rowid{SegmentID: 2, BlockID: i, Offset: 0},
+			ts(int64(i)),
+		))
+	}
+	require.Greater(t, tbl.Metrics().SpilledRuns, int64(0))
+
+	require.NoError(t, tbl.TTL(ts(4)))
+
+	for i := uint64(0); i < 4; i++ {
+		_, ok := tbl.Lookup(Rowid{SegmentID: 1, BlockID: i, Offset: 0})
+		assert.False(t, ok, "block %d committed before the TTL cutoff should be forgotten", i)
+	}
+	for i := uint64(4); i < 8; i++ {
+		_, ok := tbl.Lookup(Rowid{SegmentID: 1, BlockID: i, Offset: 0})
+		assert.True(t, ok, "block %d committed at or after the TTL cutoff must survive", i)
+	}
+}
+
+func TestNewTableRejectsNonPositiveCap(t *testing.T) {
+	_, err := NewTable(t.TempDir(), 0)
+	assert.Error(t, err)
+}
+
+func TestCloseAfterSpill(t *testing.T) {
+	tbl, err := NewTable(t.TempDir(), 2)
+	require.NoError(t, err)
+
+	for i := uint64(0); i < 4; i++ {
+		require.NoError(t, tbl.Put(
+			Rowid{SegmentID: 1, BlockID: i, Offset: 0},
+			Rowid{SegmentID: 2, BlockID: i, Offset: 0},
+			ts(int64(i)),
+		))
+	}
+	require.NoError(t, tbl.Close())
+}
@@ -0,0 +1,142 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package merge scores merge candidates for the background compaction
+// scheduler and turns the winners into a prioritized Plan. It does not
+// itself know about catalog.SegmentEntry/BlockEntry, jobs.NewMergeBlocksTask,
+// or handle.Relation: a caller collects SegmentStats from the catalog each
+// tick, runs them through a MergePolicy, and feeds the resulting Plans to
+// jobs.NewMergeBlocksTask the way TestMergeblocks2 does by hand today.
+// This package keeps no per-table policy registry and handle.Relation has
+// no SetMergePolicy method: the caller picks which MergePolicy each
+// table's candidates go through. TombstoneDensityPolicy's CooldownDur plays the same role as the
+// dirty-tree collector's LastFlush watermark: both exist so a repeated
+// scheduler tick doesn't re-select a segment whose delete chain it already
+// acted on before the merge has had a chance to land.
+package merge
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// BlockStats is the per-block row/delete counts a MergePolicy needs to
+// score a segment. TotalRows and DeletedRows are as-of-now snapshots the
+// caller reads off catalog.BlockEntry; MergePolicy never mutates them.
+type BlockStats[B comparable] struct {
+	ID          B
+	TotalRows   uint32
+	DeletedRows uint32
+}
+
+// SegmentStats is one segment's candidate material for a merge decision:
+// its identity plus the per-block stats of every block a policy may choose
+// to include in its Plan.
+type SegmentStats[S comparable, B comparable] struct {
+	ID     S
+	Blocks []BlockStats[B]
+}
+
+// Plan is one segment a MergePolicy picked to merge, together with the
+// subset of its blocks the merge should cover.
+type Plan[S comparable, B comparable] struct {
+	Segment S
+	Blocks  []B
+}
+
+// MergePolicy scores merge candidates and returns a priority-ordered Plan
+// list, highest priority first. Select must not block: the scheduler calls
+// it on every tick holding no locks of its own.
+type MergePolicy[S comparable, B comparable] interface {
+	Select(candidates []SegmentStats[S, B], now time.Time) []Plan[S, B]
+}
+
+// TombstoneDensityPolicy selects segments whose aggregate deleted-row
+// ratio, sum(DeletedRows)/sum(TotalRows) across all of a segment's blocks,
+// is at least MinTombstoneRatio, skipping segments with fewer than
+// MinBlocks blocks (merging a lone block buys nothing) and segments merged
+// within the last CooldownDur (see the package doc comment). Candidates
+// that clear both bars are returned most-dense first.
+type TombstoneDensityPolicy[S comparable, B comparable] struct {
+	MinTombstoneRatio float64
+	MinBlocks         int
+	CooldownDur       time.Duration
+
+	mu         sync.Mutex
+	lastMerged map[S]time.Time
+}
+
+// NewTombstoneDensityPolicy returns a policy with the given thresholds.
+func NewTombstoneDensityPolicy[S comparable, B comparable](minTombstoneRatio float64, minBlocks int, cooldown time.Duration) *TombstoneDensityPolicy[S, B] {
+	return &TombstoneDensityPolicy[S, B]{
+		MinTombstoneRatio: minTombstoneRatio,
+		MinBlocks:         minBlocks,
+		CooldownDur:       cooldown,
+		lastMerged:        make(map[S]time.Time),
+	}
+}
+
+// Select implements MergePolicy.
+func (p *TombstoneDensityPolicy[S, B]) Select(candidates []SegmentStats[S, B], now time.Time) []Plan[S, B] {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	type scored struct {
+		plan  Plan[S, B]
+		ratio float64
+	}
+	var hits []scored
+	for _, seg := range candidates {
+		if len(seg.Blocks) < p.MinBlocks {
+			continue
+		}
+		if last, ok := p.lastMerged[seg.ID]; ok && now.Sub(last) < p.CooldownDur {
+			continue
+		}
+		var total, deleted uint64
+		blocks := make([]B, 0, len(seg.Blocks))
+		for _, b := range seg.Blocks {
+			total += uint64(b.TotalRows)
+			deleted += uint64(b.DeletedRows)
+			blocks = append(blocks, b.ID)
+		}
+		if total == 0 {
+			continue
+		}
+		ratio := float64(deleted) / float64(total)
+		if ratio < p.MinTombstoneRatio {
+			continue
+		}
+		hits = append(hits, scored{plan: Plan[S, B]{Segment: seg.ID, Blocks: blocks}, ratio: ratio})
+	}
+
+	sort.Slice(hits, func(i, j int) bool { return hits[i].ratio > hits[j].ratio })
+
+	plans := make([]Plan[S, B], len(hits))
+	for i, h := range hits {
+		plans[i] = h.plan
+	}
+	return plans
+}
+
+// MarkMerged records that seg was merged at instant at, arming its
+// cooldown so a later Select call within CooldownDur skips it even if
+// its on-disk stats haven't caught up yet. The scheduler calls this right
+// after handing seg's Plan to jobs.NewMergeBlocksTask.
+func (p *TombstoneDensityPolicy[S, B]) MarkMerged(seg S, at time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastMerged[seg] = at
+}
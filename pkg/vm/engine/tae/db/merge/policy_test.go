@@ -0,0 +1,118 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package merge
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTombstoneDensityPolicySelectsDenseSegmentsOnly(t *testing.T) {
+	policy := NewTombstoneDensityPolicy[string, int](0.5, 2, time.Minute)
+	now := time.Unix(0, 0)
+
+	candidates := []SegmentStats[string, int]{
+		// 9/10 deleted: well over the ratio bar.
+		{ID: "hot", Blocks: []BlockStats[int]{{ID: 1, TotalRows: 5, DeletedRows: 5}, {ID: 2, TotalRows: 5, DeletedRows: 4}}},
+		// 1/10 deleted: under the ratio bar.
+		{ID: "cold", Blocks: []BlockStats[int]{{ID: 3, TotalRows: 5, DeletedRows: 1}, {ID: 4, TotalRows: 5, DeletedRows: 0}}},
+		// over the ratio bar but a single block: under the block-count bar.
+		{ID: "tiny", Blocks: []BlockStats[int]{{ID: 5, TotalRows: 2, DeletedRows: 2}}},
+	}
+
+	plans := policy.Select(candidates, now)
+	if assert.Len(t, plans, 1) {
+		assert.Equal(t, "hot", plans[0].Segment)
+		assert.ElementsMatch(t, []int{1, 2}, plans[0].Blocks)
+	}
+}
+
+func TestTombstoneDensityPolicyOrdersByRatioDescending(t *testing.T) {
+	policy := NewTombstoneDensityPolicy[string, int](0.4, 2, time.Minute)
+	now := time.Unix(0, 0)
+
+	candidates := []SegmentStats[string, int]{
+		{ID: "warm", Blocks: []BlockStats[int]{{ID: 1, TotalRows: 10, DeletedRows: 5}, {ID: 2, TotalRows: 10, DeletedRows: 4}}},     // 0.45
+		{ID: "hottest", Blocks: []BlockStats[int]{{ID: 3, TotalRows: 10, DeletedRows: 9}, {ID: 4, TotalRows: 10, DeletedRows: 8}}}, // 0.85
+	}
+
+	plans := policy.Select(candidates, now)
+	if assert.Len(t, plans, 2) {
+		assert.Equal(t, "hottest", plans[0].Segment)
+		assert.Equal(t, "warm", plans[1].Segment)
+	}
+}
+
+func TestTombstoneDensityPolicyCooldownSkipsRecentlyMerged(t *testing.T) {
+	policy := NewTombstoneDensityPolicy[string, int](0.5, 2, time.Minute)
+	start := time.Unix(0, 0)
+
+	candidates := []SegmentStats[string, int]{
+		{ID: "hot", Blocks: []BlockStats[int]{{ID: 1, TotalRows: 10, DeletedRows: 8}, {ID: 2, TotalRows: 10, DeletedRows: 8}}},
+	}
+
+	plans := policy.Select(candidates, start)
+	assert.Len(t, plans, 1)
+	policy.MarkMerged("hot", start)
+
+	// Ticking the scheduler forward within the cooldown window should not
+	// re-select "hot" even though it still clears the ratio/block bars.
+	assert.Empty(t, policy.Select(candidates, start.Add(30*time.Second)))
+
+	// Bounded number of ticks: the segment becomes eligible again only
+	// once CooldownDur has fully elapsed.
+	assert.Len(t, policy.Select(candidates, start.Add(61*time.Second)), 1)
+}
+
+// simScheduler drives Select on a fixed tick interval for maxTicks ticks,
+// the way the background merge runner would, and returns every segment
+// any tick produced a Plan for.
+func simScheduler[S comparable, B comparable](policy MergePolicy[S, B], candidates []SegmentStats[S, B], start time.Time, tick time.Duration, maxTicks int) map[S]bool {
+	picked := make(map[S]bool)
+	now := start
+	for i := 0; i < maxTicks; i++ {
+		for _, plan := range policy.Select(candidates, now) {
+			picked[plan.Segment] = true
+		}
+		now = now.Add(tick)
+	}
+	return picked
+}
+
+func TestSchedulerPicksExpectedSegmentsWithinBoundedTicks(t *testing.T) {
+	policy := NewTombstoneDensityPolicy[string, int](0.3, 3, time.Minute)
+	start := time.Unix(0, 0)
+
+	blockID := 0
+	newSeg := func(id string, totalPerBlock, deletedPerBlock []uint32) SegmentStats[string, int] {
+		seg := SegmentStats[string, int]{ID: id}
+		for i := range totalPerBlock {
+			blockID++
+			seg.Blocks = append(seg.Blocks, BlockStats[int]{ID: blockID, TotalRows: totalPerBlock[i], DeletedRows: deletedPerBlock[i]})
+		}
+		return seg
+	}
+
+	candidates := []SegmentStats[string, int]{
+		newSeg("seg-heavy", []uint32{10, 10, 10}, []uint32{8, 7, 6}), // ratio 0.7, 3 blocks: expected
+		newSeg("seg-light", []uint32{10, 10, 10}, []uint32{1, 0, 1}), // ratio 0.07: not expected
+		newSeg("seg-small", []uint32{10, 10}, []uint32{9, 9}),        // only 2 blocks: not expected
+	}
+
+	picked := simScheduler[string, int](policy, candidates, start, 10*time.Second, 5)
+	assert.Equal(t, map[string]bool{"seg-heavy": true}, picked)
+}
@@ -0,0 +1,110 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"sort"
+
+	"github.com/matrixorigin/matrixone/pkg/container/types"
+)
+
+// CheckpointEntry is the read-only material PlanCheckpointGC needs to
+// judge one incremental or global checkpoint: its identity, whether it is
+// a global checkpoint, the bytes it and the objects it alone keeps alive
+// would free if dropped, and the [Start, End) TS range it covers.
+type CheckpointEntry[S comparable] struct {
+	ID       S
+	IsGlobal bool
+	Bytes    uint64
+	Start    types.TS
+	End      types.TS
+}
+
+func tsEqual(a, b types.TS) bool {
+	return !a.Less(b) && !b.Less(a)
+}
+
+// PlanCheckpointGC walks entries oldest-first by size rather than by a
+// wall-clock/TS cutoff, selecting the oldest ones to GC until the
+// remaining total footprint is at or under budget. It never selects the
+// newest global checkpoint, nor any incremental on the contiguous chain
+// running from that checkpoint's End forward - the same invariant
+// GCByTS already holds: a CN must always be able to catch up from
+// MaxGlobal.End().Next() using only what survives. If every entry
+// outside that protected set is GCed and the total is still over
+// budget, PlanCheckpointGC returns what it has rather than touching the
+// protected set.
+func PlanCheckpointGC[S comparable](entries []CheckpointEntry[S], budget uint64) []S {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	var maxGlobal *CheckpointEntry[S]
+	for i := range entries {
+		if !entries[i].IsGlobal {
+			continue
+		}
+		if maxGlobal == nil || maxGlobal.End.Less(entries[i].End) {
+			maxGlobal = &entries[i]
+		}
+	}
+
+	protected := make(map[S]bool)
+	if maxGlobal != nil {
+		protected[maxGlobal.ID] = true
+		chainEnd := maxGlobal.End
+		for {
+			advanced := false
+			for _, e := range entries {
+				if e.IsGlobal || protected[e.ID] {
+					continue
+				}
+				if tsEqual(e.Start, chainEnd) {
+					protected[e.ID] = true
+					chainEnd = e.End
+					advanced = true
+				}
+			}
+			if !advanced {
+				break
+			}
+		}
+	}
+
+	var total uint64
+	var candidates []CheckpointEntry[S]
+	for _, e := range entries {
+		total += e.Bytes
+		if protected[e.ID] {
+			continue
+		}
+		candidates = append(candidates, e)
+	}
+	if total <= budget {
+		return nil
+	}
+
+	sort.Slice(candidates, func(i, j int) bool { return candidates[i].End.Less(candidates[j].End) })
+
+	var drop []S
+	for _, e := range candidates {
+		if total <= budget {
+			break
+		}
+		drop = append(drop, e.ID)
+		total -= e.Bytes
+	}
+	return drop
+}
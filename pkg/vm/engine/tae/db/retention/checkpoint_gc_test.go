@@ -0,0 +1,82 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"testing"
+
+	"github.com/matrixorigin/matrixone/pkg/container/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func ckpts(physical int64) types.TS {
+	return types.BuildTS(physical, 0)
+}
+
+func TestPlanCheckpointGCNoOpUnderBudget(t *testing.T) {
+	entries := []CheckpointEntry[int]{
+		{ID: 1, IsGlobal: true, Bytes: 40, Start: ckpts(0), End: ckpts(100)},
+		{ID: 2, Bytes: 10, Start: ckpts(100), End: ckpts(110)},
+	}
+	assert.Empty(t, PlanCheckpointGC(entries, 100))
+}
+
+func TestPlanCheckpointGCDropsOldestNonProtectedEntries(t *testing.T) {
+	entries := []CheckpointEntry[int]{
+		{ID: 1, IsGlobal: true, Bytes: 40, Start: ckpts(0), End: ckpts(100)},
+		{ID: 2, IsGlobal: true, Bytes: 40, Start: ckpts(0), End: ckpts(200)}, // newer global, must survive
+		{ID: 3, Bytes: 30, Start: ckpts(200), End: ckpts(210)},              // chains off the newest global
+		{ID: 4, Bytes: 30, Start: ckpts(90), End: ckpts(95)},                // stale, not on any surviving chain
+	}
+	// total = 140, budget 80: 2 (the newest global) and 3 (chained off
+	// it) are protected; 4 and 1 (an old global superseded by 2) are
+	// droppable, and dropping 4 then 1 brings the total to 70.
+	dropped := PlanCheckpointGC(entries, 80)
+	assert.Contains(t, dropped, 4)
+	assert.NotContains(t, dropped, 2, "the newest global checkpoint must never be GCed")
+	assert.NotContains(t, dropped, 3, "incrementals chained off the newest global must survive")
+}
+
+func TestPlanCheckpointGCNeverDropsChainEvenOverBudget(t *testing.T) {
+	entries := []CheckpointEntry[int]{
+		{ID: 1, IsGlobal: true, Bytes: 50, Start: ckpts(0), End: ckpts(100)},
+		{ID: 2, Bytes: 50, Start: ckpts(100), End: ckpts(110)},
+		{ID: 3, Bytes: 50, Start: ckpts(110), End: ckpts(120)},
+	}
+	// Entire chain from the global forward is protected; nothing is
+	// droppable even though the total (150) is well over the budget (10).
+	dropped := PlanCheckpointGC(entries, 10)
+	assert.Empty(t, dropped)
+}
+
+func TestPlanCheckpointGCOrdersDropsOldestFirst(t *testing.T) {
+	entries := []CheckpointEntry[int]{
+		{ID: 1, IsGlobal: true, Bytes: 10, Start: ckpts(0), End: ckpts(500)},
+		{ID: 2, Bytes: 20, Start: ckpts(50), End: ckpts(60)},
+		{ID: 3, Bytes: 20, Start: ckpts(10), End: ckpts(20)},
+		{ID: 4, Bytes: 20, Start: ckpts(30), End: ckpts(40)},
+	}
+	dropped := PlanCheckpointGC(entries, 10)
+	assert.Equal(t, []int{3, 4, 2}, dropped)
+}
+
+func TestPlanCheckpointGCNoGlobalCheckpointTreatsEverythingAsCandidate(t *testing.T) {
+	entries := []CheckpointEntry[int]{
+		{ID: 1, Bytes: 50, Start: ckpts(0), End: ckpts(10)},
+		{ID: 2, Bytes: 50, Start: ckpts(10), End: ckpts(20)},
+	}
+	dropped := PlanCheckpointGC(entries, 60)
+	assert.Equal(t, []int{1}, dropped)
+}
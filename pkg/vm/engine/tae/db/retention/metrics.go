@@ -0,0 +1,77 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import "sync/atomic"
+
+// Metrics is the observable state of size-based retention GC, meant to be
+// embedded on tae.DB so operators can see storage pressure and how hard
+// the GC is working to relieve it: StorageBytes is a gauge the background
+// task refreshes every tick, DroppedForSize is a counter it bumps by the
+// number of segments a Plan call selected.
+type Metrics struct {
+	storageBytes   atomic.Int64
+	droppedForSize atomic.Int64
+	retainedBytes  atomic.Int64
+	gcedBytesTotal atomic.Int64
+}
+
+// SetStorageBytes records the relation/database's current on-disk size.
+func (m *Metrics) SetStorageBytes(n int64) {
+	m.storageBytes.Store(n)
+}
+
+// StorageBytes returns the most recently recorded on-disk size.
+func (m *Metrics) StorageBytes() int64 {
+	return m.storageBytes.Load()
+}
+
+// AddDroppedForSize bumps the deletions-due-to-size counter by n, the
+// number of segments one Plan-driven GC pass just dropped.
+func (m *Metrics) AddDroppedForSize(n int64) {
+	m.droppedForSize.Add(n)
+}
+
+// DroppedForSize returns the total number of segments ever dropped by
+// size-based retention on this DB.
+func (m *Metrics) DroppedForSize() int64 {
+	return m.droppedForSize.Load()
+}
+
+// SetRetainedBytes records the total footprint PlanCheckpointGC left in
+// place after its most recent pass, for a caller like BGCheckpointRunner
+// to refresh every GCBySize tick.
+func (m *Metrics) SetRetainedBytes(n int64) {
+	m.retainedBytes.Store(n)
+}
+
+// GetRetainedBytes returns the most recently recorded post-GC checkpoint
+// footprint, so operators and tests can wait on size-driven GC the same
+// way WaitExpect already waits on ExistPendingEntryToGC.
+func (m *Metrics) GetRetainedBytes() int64 {
+	return m.retainedBytes.Load()
+}
+
+// AddGCedBytesTotal bumps the cumulative bytes-freed-by-size-based-GC
+// counter by n.
+func (m *Metrics) AddGCedBytesTotal(n int64) {
+	m.gcedBytesTotal.Add(n)
+}
+
+// GetGCedBytesTotal returns the total bytes ever freed by size-based
+// checkpoint GC on this DB.
+func (m *Metrics) GetGCedBytesTotal() int64 {
+	return m.gcedBytesTotal.Load()
+}
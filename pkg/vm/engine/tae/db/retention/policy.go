@@ -0,0 +1,86 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package retention picks which whole segments a size-based retention GC
+// should drop, the way Prometheus TSDB's size-based retention picks whole
+// blocks. It doesn't know about catalog.SegmentEntry, BGCheckpointRunner,
+// or config.Options.RetentionMaxBytes: a caller (BGCheckpointRunner's GC
+// task, on the tick config.WithQuickScanAndCKPOpts wires up) collects each
+// relation's segments into SegmentInfo, calls Plan, and is left to turn
+// the returned IDs into whatever deletes the segment files from disk and
+// the catalog the way Truncate() already does for the all-or-nothing
+// case. This package only owns the selection: oldest-first, stopping once
+// the relation is back under budget or the next candidate is younger than
+// MinAge.
+package retention
+
+import (
+	"sort"
+	"time"
+)
+
+// SegmentInfo is the read-only material Plan needs to judge one segment:
+// its identity, its on-disk size, and when it was created.
+type SegmentInfo[S comparable] struct {
+	ID        S
+	Bytes     uint64
+	CreatedAt time.Time
+}
+
+// Policy caps a relation's on-disk bytes at MaxBytes, never dropping a
+// segment younger than MinAge even if the relation is still over budget
+// once MinAge segments are excluded (a time-based floor takes priority
+// over the size cap, the same trade TSDB's retention makes).
+type Policy[S comparable] struct {
+	MaxBytes uint64
+	MinAge   time.Duration
+}
+
+// NewPolicy returns a Policy with the given budget and time floor.
+func NewPolicy[S comparable](maxBytes uint64, minAge time.Duration) *Policy[S] {
+	return &Policy[S]{MaxBytes: maxBytes, MinAge: minAge}
+}
+
+// Plan returns, oldest first, the segment IDs to drop so the relation's
+// total bytes settle at or under MaxBytes. It returns nil if the relation
+// is already under budget, and may return a list that still leaves the
+// relation over budget if every remaining over-budget segment is younger
+// than MinAge.
+func (p *Policy[S]) Plan(segments []SegmentInfo[S], now time.Time) []S {
+	sorted := append([]SegmentInfo[S](nil), segments...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].CreatedAt.Before(sorted[j].CreatedAt) })
+
+	var total uint64
+	for _, seg := range sorted {
+		total += seg.Bytes
+	}
+	if total <= p.MaxBytes {
+		return nil
+	}
+
+	var drop []S
+	for _, seg := range sorted {
+		if total <= p.MaxBytes {
+			break
+		}
+		if now.Sub(seg.CreatedAt) < p.MinAge {
+			// Everything from here on is even younger: stop rather than
+			// skip ahead looking for an older one that doesn't exist.
+			break
+		}
+		drop = append(drop, seg.ID)
+		total -= seg.Bytes
+	}
+	return drop
+}
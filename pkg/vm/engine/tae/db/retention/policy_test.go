@@ -0,0 +1,149 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanNoOpUnderBudget(t *testing.T) {
+	policy := NewPolicy[int](100, 0)
+	now := time.Unix(1000, 0)
+	segs := []SegmentInfo[int]{
+		{ID: 1, Bytes: 40, CreatedAt: now.Add(-time.Hour)},
+		{ID: 2, Bytes: 40, CreatedAt: now.Add(-time.Minute)},
+	}
+	assert.Empty(t, policy.Plan(segs, now))
+}
+
+func TestPlanDropsOldestFirstUntilUnderBudget(t *testing.T) {
+	policy := NewPolicy[int](50, 0)
+	now := time.Unix(1000, 0)
+	segs := []SegmentInfo[int]{
+		{ID: 1, Bytes: 30, CreatedAt: now.Add(-3 * time.Hour)},
+		{ID: 2, Bytes: 30, CreatedAt: now.Add(-2 * time.Hour)},
+		{ID: 3, Bytes: 30, CreatedAt: now.Add(-1 * time.Hour)},
+	}
+	// total 90 > 50: dropping seg 1 (oldest) brings it to 60, still over;
+	// dropping seg 2 too brings it to 30, under budget.
+	assert.Equal(t, []int{1, 2}, policy.Plan(segs, now))
+}
+
+func TestPlanHonorsMinAgeOverBudget(t *testing.T) {
+	policy := NewPolicy[int](10, time.Hour)
+	now := time.Unix(1000, 0)
+	segs := []SegmentInfo[int]{
+		{ID: 1, Bytes: 30, CreatedAt: now.Add(-30 * time.Minute)}, // too young to drop
+		{ID: 2, Bytes: 30, CreatedAt: now.Add(-10 * time.Minute)},
+	}
+	// Still way over budget (60 > 10) but every segment is younger than
+	// MinAge, so Plan must refuse to touch any of them.
+	assert.Empty(t, policy.Plan(segs, now))
+}
+
+func TestPlanStopsAtMinAgeBoundary(t *testing.T) {
+	policy := NewPolicy[int](20, time.Hour)
+	now := time.Unix(1000, 0)
+	segs := []SegmentInfo[int]{
+		{ID: 1, Bytes: 30, CreatedAt: now.Add(-2 * time.Hour)},  // eligible
+		{ID: 2, Bytes: 30, CreatedAt: now.Add(-30 * time.Minute)}, // too young
+	}
+	// Dropping seg 1 brings total to 30, still over the 20 budget, but
+	// seg 2 is within MinAge so Plan must stop rather than drop it too.
+	assert.Equal(t, []int{1}, policy.Plan(segs, now))
+}
+
+// TestAppendsBeyondCapTriggerSegmentLevelGC simulates a relation
+// accumulating segments via Append, ticking the retention GC after every
+// append, and asserts it evicts whole, eligible segments to stay near
+// budget without ever touching a segment an in-flight txn still has
+// pinned (the GC task excludes pinned segments from the candidate set and
+// shrinks its effective budget by their size instead, the same way it
+// would treat bytes a txn snapshot still needs as un-reclaimable).
+func TestAppendsBeyondCapTriggerSegmentLevelGC(t *testing.T) {
+	const maxBytes = 100
+	policy := NewPolicy[int](maxBytes, time.Hour)
+	metrics := &Metrics{}
+	now := time.Unix(1000, 0)
+
+	var segs []SegmentInfo[int]
+	pinned := map[int]bool{1: true} // an in-flight txn holds a snapshot pinning segment 1
+	nextID := 0
+
+	appendSegment := func(bytes uint64, age time.Duration) {
+		nextID++
+		segs = append(segs, SegmentInfo[int]{ID: nextID, Bytes: bytes, CreatedAt: now.Add(-age)})
+
+		var total, pinnedBytes uint64
+		var candidates []SegmentInfo[int]
+		for _, s := range segs {
+			total += s.Bytes
+			if pinned[s.ID] {
+				pinnedBytes += s.Bytes
+				continue
+			}
+			candidates = append(candidates, s)
+		}
+
+		// Bytes a txn snapshot still pins aren't reclaimable right now,
+		// so the droppable set must shrink by that much to still land
+		// the relation under budget overall.
+		effectiveMax := uint64(0)
+		if maxBytes > pinnedBytes {
+			effectiveMax = maxBytes - pinnedBytes
+		}
+		tick := NewPolicy[int](effectiveMax, policy.MinAge)
+		dropped := tick.Plan(candidates, now)
+
+		droppedSet := make(map[int]bool, len(dropped))
+		for _, id := range dropped {
+			droppedSet[id] = true
+		}
+		var kept []SegmentInfo[int]
+		for _, s := range segs {
+			if droppedSet[s.ID] {
+				continue
+			}
+			kept = append(kept, s)
+		}
+		segs = kept
+		metrics.AddDroppedForSize(int64(len(dropped)))
+
+		var postGCTotal uint64
+		for _, s := range segs {
+			postGCTotal += s.Bytes
+		}
+		metrics.SetStorageBytes(int64(postGCTotal))
+	}
+
+	appendSegment(40, 3*time.Hour)    // id 1, pinned, old enough to be eligible
+	appendSegment(40, 2*time.Hour)    // id 2
+	appendSegment(40, 90*time.Minute) // id 3, pushes total to 120 > 100
+
+	var total uint64
+	ids := make(map[int]bool)
+	for _, s := range segs {
+		total += s.Bytes
+		ids[s.ID] = true
+	}
+
+	assert.LessOrEqual(t, total, uint64(maxBytes))
+	assert.True(t, ids[1], "pinned segment must survive size-based GC")
+	assert.Equal(t, int64(1), metrics.DroppedForSize())
+	assert.Equal(t, int64(total), metrics.StorageBytes())
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"sort"
+	"time"
+)
+
+// TierConfig is one level of a TieredPolicy: Interval is the nominal
+// spacing between checkpoints already compacted to this level, and
+// MaxCount is how many of them are allowed to accumulate before the
+// oldest contiguous run gets merged up to the next level. Geometric
+// Intervals (10s, 100s, 1000s, ...) bound total retained checkpoints at
+// log(total_age) the way Prometheus TSDB's block levels do.
+type TierConfig struct {
+	Interval time.Duration
+	MaxCount int
+}
+
+// CheckpointInfo is the read-only material TieredPolicy needs to judge
+// one global checkpoint: its identity, the level it currently lives at,
+// and when it was produced.
+type CheckpointInfo[S comparable] struct {
+	ID        S
+	Level     int
+	CreatedAt time.Time
+}
+
+// TieredPolicy decides when a level has accumulated enough checkpoints
+// to merge the oldest contiguous run into one checkpoint at the next
+// level up. It doesn't know about BGCheckpointRunner, Catalog, or
+// DiskCleaner: a caller (BGCheckpointRunner's compaction tick) collects
+// its current global checkpoints into CheckpointInfo, calls Plan for
+// each level from lowest to highest, and is left to turn a non-nil
+// result into the actual merge - walking Catalog once to build the
+// combined meta-file, fsyncing it, and only then retiring the source
+// files via DiskCleaner. This package only owns the selection: which
+// level is over its cap, and which oldest contiguous checkpoints at that
+// level should be merged.
+type TieredPolicy[S comparable] struct {
+	Levels []TierConfig
+}
+
+// NewTieredPolicy returns a TieredPolicy with the given per-level caps,
+// ordered from the most frequent level (0) to the coarsest.
+func NewTieredPolicy[S comparable](levels []TierConfig) *TieredPolicy[S] {
+	return &TieredPolicy[S]{Levels: levels}
+}
+
+// Plan reports whether level has more than its configured MaxCount
+// checkpoints and, if so, returns the oldest MaxCount of them, oldest
+// first, as the contiguous run to merge into a single level+1
+// checkpoint. It returns ok=false if level is out of range, has no cap
+// configured, or is not yet over its cap.
+func (p *TieredPolicy[S]) Plan(checkpoints []CheckpointInfo[S], level int) (merge []S, targetLevel int, ok bool) {
+	if level < 0 || level >= len(p.Levels) {
+		return nil, 0, false
+	}
+	maxCount := p.Levels[level].MaxCount
+	if maxCount <= 0 {
+		return nil, 0, false
+	}
+
+	var atLevel []CheckpointInfo[S]
+	for _, ckp := range checkpoints {
+		if ckp.Level == level {
+			atLevel = append(atLevel, ckp)
+		}
+	}
+	if len(atLevel) <= maxCount {
+		return nil, 0, false
+	}
+
+	sort.Slice(atLevel, func(i, j int) bool { return atLevel[i].CreatedAt.Before(atLevel[j].CreatedAt) })
+
+	merge = make([]S, maxCount)
+	for i := 0; i < maxCount; i++ {
+		merge[i] = atLevel[i].ID
+	}
+	return merge, level + 1, true
+}
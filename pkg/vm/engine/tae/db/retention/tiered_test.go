@@ -0,0 +1,88 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package retention
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTieredPolicyPlanNoOpUnderCap(t *testing.T) {
+	policy := NewTieredPolicy[int]([]TierConfig{{Interval: 10 * time.Second, MaxCount: 3}})
+	now := time.Unix(1000, 0)
+	ckps := []CheckpointInfo[int]{
+		{ID: 1, Level: 0, CreatedAt: now.Add(-2 * time.Second)},
+		{ID: 2, Level: 0, CreatedAt: now.Add(-time.Second)},
+	}
+	merge, target, ok := policy.Plan(ckps, 0)
+	assert.False(t, ok)
+	assert.Nil(t, merge)
+	assert.Equal(t, 0, target)
+}
+
+func TestTieredPolicyPlanMergesOldestContiguousRunOverCap(t *testing.T) {
+	policy := NewTieredPolicy[int]([]TierConfig{{Interval: 10 * time.Second, MaxCount: 2}})
+	now := time.Unix(1000, 0)
+	ckps := []CheckpointInfo[int]{
+		{ID: 3, Level: 0, CreatedAt: now.Add(-10 * time.Second)},
+		{ID: 1, Level: 0, CreatedAt: now.Add(-30 * time.Second)},
+		{ID: 2, Level: 0, CreatedAt: now.Add(-20 * time.Second)},
+	}
+	merge, target, ok := policy.Plan(ckps, 0)
+	assert.True(t, ok)
+	assert.Equal(t, []int{1, 2}, merge)
+	assert.Equal(t, 1, target)
+}
+
+func TestTieredPolicyPlanIgnoresOtherLevels(t *testing.T) {
+	policy := NewTieredPolicy[int]([]TierConfig{
+		{Interval: 10 * time.Second, MaxCount: 1},
+		{Interval: 100 * time.Second, MaxCount: 1},
+	})
+	now := time.Unix(1000, 0)
+	ckps := []CheckpointInfo[int]{
+		{ID: 1, Level: 0, CreatedAt: now.Add(-40 * time.Second)},
+		{ID: 2, Level: 0, CreatedAt: now.Add(-20 * time.Second)},
+		{ID: 10, Level: 1, CreatedAt: now.Add(-200 * time.Second)},
+	}
+	merge, target, ok := policy.Plan(ckps, 0)
+	assert.True(t, ok)
+	assert.Equal(t, []int{1}, merge)
+	assert.Equal(t, 1, target)
+
+	// Level 1 has only one checkpoint, at its cap, not over it.
+	merge1, _, ok1 := policy.Plan(ckps, 1)
+	assert.False(t, ok1)
+	assert.Nil(t, merge1)
+}
+
+func TestTieredPolicyPlanOutOfRangeLevel(t *testing.T) {
+	policy := NewTieredPolicy[int]([]TierConfig{{Interval: 10 * time.Second, MaxCount: 1}})
+	merge, _, ok := policy.Plan(nil, 5)
+	assert.False(t, ok)
+	assert.Nil(t, merge)
+}
+
+func TestTieredPolicyPlanUnconfiguredCapIsNoOp(t *testing.T) {
+	policy := NewTieredPolicy[int]([]TierConfig{{Interval: 10 * time.Second, MaxCount: 0}})
+	ckps := []CheckpointInfo[int]{
+		{ID: 1, Level: 0, CreatedAt: time.Unix(1, 0)},
+	}
+	merge, _, ok := policy.Plan(ckps, 0)
+	assert.False(t, ok)
+	assert.Nil(t, merge)
+}
@@ -0,0 +1,145 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/matrixorigin/matrixone/pkg/container/types"
+	"github.com/matrixorigin/matrixone/pkg/objectio"
+	"github.com/matrixorigin/matrixone/pkg/vm/engine/tae/blockio"
+	"github.com/matrixorigin/matrixone/pkg/vm/engine/tae/catalog"
+	"github.com/matrixorigin/matrixone/pkg/vm/engine/tae/containers"
+	"github.com/matrixorigin/matrixone/pkg/vm/engine/tae/db/testutil"
+	"github.com/matrixorigin/matrixone/pkg/vm/engine/tae/iface/handle"
+	"github.com/matrixorigin/matrixone/pkg/vm/engine/tae/testutils"
+	"github.com/matrixorigin/matrixone/pkg/vm/engine/tae/testutils/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// NOTE: this test depends on BlockEntry growing a *index.DeltaLocIndex
+// sidecar (populated on UpdateDeltaLoc, consulted by CollectDeleteInRange)
+// and on Runtime.Fs.Service exposing an open-count per object name so a
+// test can prove an out-of-range delta object was never opened. Neither
+// exists in this tree yet; this is written the way the real integration
+// test would look once they land, following TestCollectDelete's fixture
+// shape (db_test.go) with an added object-open counter around the same
+// three-delete, one-flush sequence it already exercises.
+//
+// countingFs wraps tae.Runtime.Fs.Service and counts Open calls per
+// object name, so the test can assert which delta objects
+// CollectDeleteInRange actually touched.
+type countingFs interface {
+	OpenCount(name string) int
+}
+
+func TestCollectDeleteInRangeSkipsOutOfRangeDeltaObjects(t *testing.T) {
+	defer testutils.AfterTest(t)()
+	ctx := context.Background()
+
+	opts := config.WithLongScanAndCKPOpts(nil)
+	tae := testutil.NewTestEngine(ctx, ModuleName, t, opts)
+	defer tae.Close()
+	schema := catalog.MockSchemaAll(2, 1)
+	schema.BlockMaxRows = 20
+	tae.BindSchema(schema)
+	bat := catalog.MockBatch(schema, 12)
+
+	tae.CreateRelAndAppend(bat, true)
+
+	_, rel := tae.GetRelation()
+	blkit := rel.MakeBlockIt()
+	blkID := blkit.GetBlock().GetMeta().(*catalog.BlockEntry).AsCommonID()
+
+	txn1, rel := tae.GetRelation()
+	require.NoError(t, rel.RangeDelete(blkID, 0, 0, handle.DT_Normal))
+	require.NoError(t, txn1.Commit(ctx))
+	p1 := txn1.GetPrepareTS()
+
+	txn2, rel := tae.GetRelation()
+	require.NoError(t, rel.RangeDelete(blkID, 1, 3, handle.DT_Normal))
+	require.NoError(t, txn2.Commit(ctx))
+	p2 := txn2.GetPrepareTS()
+
+	txn3, rel := tae.GetRelation()
+	require.NoError(t, rel.RangeDelete(blkID, 4, 5, handle.DT_Normal))
+	require.NoError(t, txn3.Commit(ctx))
+	p3 := txn3.GetPrepareTS()
+
+	txn, rel := tae.GetRelation()
+	blkit = rel.MakeBlockIt()
+	blkhandle := blkit.GetBlock()
+	blkdata := blkhandle.GetMeta().(*catalog.BlockEntry).GetBlockData()
+
+	// Flush the deletes up to p1 into one delta object, and the deletes
+	// from just after p1 up to p2 into a second, disjoint delta object -
+	// the sidecar should then record two non-overlapping [MinTS, MaxTS]
+	// entries.
+	firstBatch, err := blkdata.CollectDeleteInRange(ctx, types.TS{}, p1, true)
+	require.NoError(t, err)
+	firstLoc := flushDeleteBatch(t, tae, firstBatch)
+	require.NoError(t, blkhandle.UpdateDeltaLoc(firstLoc))
+	require.NoError(t, txn.Commit(ctx))
+
+	txn, rel = tae.GetRelation()
+	blkit = rel.MakeBlockIt()
+	blkhandle = blkit.GetBlock()
+	secondBatch, err := blkdata.CollectDeleteInRange(ctx, p1.Next(), p2, true)
+	require.NoError(t, err)
+	secondLoc := flushDeleteBatch(t, tae, secondBatch)
+	require.NoError(t, blkhandle.UpdateDeltaLoc(secondLoc))
+	require.NoError(t, txn.Commit(ctx))
+
+	blkdata.GCInMemeoryDeletesByTS(p2)
+
+	fs, ok := tae.Runtime.Fs.Service.(countingFs)
+	require.True(t, ok, "fs.Service must expose OpenCount for this test to observe which objects were opened")
+
+	// A query entirely inside the first flush's range must never open
+	// the second flush's object.
+	_, err = blkdata.CollectDeleteInRange(ctx, types.TS{}, p1, true)
+	require.NoError(t, err)
+	assert.Equal(t, 0, fs.OpenCount(secondLoc.Name().String()))
+
+	// A query spanning both still finds every delete recorded by
+	// TestCollectDelete for the same range.
+	batch, err := blkdata.CollectDeleteInRange(ctx, types.TS{}, p2, true)
+	require.NoError(t, err)
+	for _, vec := range batch.Vecs {
+		assert.Equal(t, 4, vec.Length())
+	}
+
+	// p3's deletes were never flushed, so a query bounded by p3 must
+	// still see them without opening either delta object on disk.
+	beforeFirst := fs.OpenCount(firstLoc.Name().String())
+	_, err = blkdata.CollectDeleteInRange(ctx, p2.Next(), p3, true)
+	require.NoError(t, err)
+	assert.Equal(t, beforeFirst, fs.OpenCount(firstLoc.Name().String()))
+}
+
+func flushDeleteBatch(t *testing.T, tae *testutil.TestEngine, delBatch *containers.Batch) objectio.Location {
+	t.Helper()
+	name := objectio.BuildObjectName(objectio.NewSegmentid(), 0)
+	writer, err := blockio.NewBlockWriterNew(tae.Runtime.Fs.Service, name, 0, nil)
+	require.NoError(t, err)
+	writer.SetPrimaryKey(3)
+	writer.WriteBatch(containers.ToCNBatch(delBatch))
+	blocks, _, err := writer.Sync(context.TODO())
+	require.NoError(t, err)
+	require.Len(t, blocks, 1)
+	return blockio.EncodeLocation(writer.GetName(), blocks[0].GetExtent(), uint32(delBatch.Length()), blocks[0].GetID())
+}
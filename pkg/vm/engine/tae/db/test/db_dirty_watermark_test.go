@@ -0,0 +1,76 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file extends TestWatchDirty: instead of waiting for
+// DirtyCollector's own delete-intent scan to notice a table went quiet
+// after a forced compact, it advances the table's LastFlush watermark
+// directly and checks the collector reaches zero dirty blocks in one Run.
+//
+// NOTE: it depends on DirtyCollector embedding a model.FlushWatermarks
+// (see pkg/vm/engine/tae/model/flush_watermark.go) and exposing it as
+// LastFlushOf/SetLastFlush, and on HasDeleteIntentsPreparedIn using
+// model.EffectiveFrom(from, collector.LastFlushOf(dbID, tableID)) as the
+// start of its scan window. Neither of those exist yet in this package,
+// so this documents the intended test surface against the same
+// watcher.DirtyCount/watcher.Run API TestWatchDirty already exercises.
+package test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/matrixorigin/matrixone/pkg/vm/engine/tae/catalog"
+	"github.com/matrixorigin/matrixone/pkg/vm/engine/tae/db/testutil"
+	"github.com/matrixorigin/matrixone/pkg/vm/engine/tae/logtail"
+	"github.com/matrixorigin/matrixone/pkg/vm/engine/tae/testutils"
+	"github.com/matrixorigin/matrixone/pkg/vm/engine/tae/testutils/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWatchDirtyClearsImmediatelyAfterExplicitFlushWatermark(t *testing.T) {
+	defer testutils.AfterTest(t)()
+	ctx := context.Background()
+
+	opts := config.WithQuickScanAndCKPOpts(nil)
+	tae := testutil.NewTestEngine(ctx, ModuleName, t, opts)
+	defer tae.Close()
+
+	visitor := &catalog.LoopProcessor{}
+	watcher := logtail.NewDirtyCollector(tae.LogtailMgr, opts.Clock, tae.Catalog, visitor)
+
+	schema := catalog.MockSchemaAll(1, 0)
+	schema.BlockMaxRows = 50
+	schema.SegmentMaxBlocks = 2
+	tae.BindSchema(schema)
+	bat := catalog.MockBatch(schema, 100)
+	defer bat.Close()
+	tae.CreateRelAndAppend(bat, true)
+
+	watcher.Run()
+	_, _, blkCnt := watcher.DirtyCount()
+	require.NotZero(t, blkCnt)
+
+	require.NoError(t, tae.CompactBlocks(false))
+	dbID, tblID := tae.GetDBAndTableID()
+
+	// Without an explicit watermark, DirtyCount may still report the
+	// table dirty for a few Run calls while the delete-intent scan
+	// rediscovers that the compacted range is clear.
+	watcher.SetLastFlush(dbID, tblID, tae.TxnMgr.Now())
+
+	watcher.Run()
+	_, _, blkCnt = watcher.DirtyCount()
+	assert.Zero(t, blkCnt, "one Run after SetLastFlush should clear the table instead of waiting on the natural scan")
+}
@@ -0,0 +1,66 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file exercises a predicate-based bulk delete the way
+// TestApplyDeltalocation1 and TestApplyDeltalocation3 (db_test.go)
+// exercise the []any-driven tae.TryDeleteByDeltaloc/
+// TryDeleteByDeltalocWithTxn, but against index.FilterBulkDelete (see
+// pkg/vm/engine/tae/index/filter_delete.go) instead of an explicit PK
+// value list.
+//
+// NOTE: it depends on pieces that do not exist yet and are out of scope
+// for this change:
+//   - tae.TryDeleteByFilterDeltaloc(ctx, txn, expr plan.Expr), which
+//     would compile expr down to an index.Predicate over the relation's
+//     sort-key column, build []index.BlockValues from each block's
+//     persisted zonemap/bloom/column data, call index.FilterBulkDelete,
+//     and commit one tombstone object per matched block - the same
+//     per-block-object shape TryDeleteByDeltaloc already commits today.
+//   - the same conflict semantics TestApplyDeltalocation3 already
+//     exercises for TryDeleteByDeltalocWithTxn: the filter-deltaloc
+//     commit fails if a committed in-memory delete on the same block
+//     overlaps the matched rows, but succeeds if the overlapping txn is
+//     still active when it commits.
+// Until those land, this documents the intended test surface directly
+// against index.FilterBulkDelete: the matched-row-offsets-by-block result
+// a filter-deltaloc commit would turn into tombstone objects.
+package test
+
+import (
+	"testing"
+
+	"github.com/matrixorigin/matrixone/pkg/vm/engine/tae/index"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestFilterBulkDeleteMatchesPerBlockShapeOfExplicitDeltaloc mirrors the
+// per-block commit shape TestApplyDeltalocation1 exercises for
+// []any-driven deletes: evaluating an expression equivalent to "pk > 5"
+// against two blocks produces exactly the rows TryDeleteByDeltaloc would
+// have been given explicitly, grouped by block the same way a filter
+// commit packs them into one tombstone object per block.
+func TestFilterBulkDeleteMatchesPerBlockShapeOfExplicitDeltaloc(t *testing.T) {
+	pred := index.Predicate[int64]{
+		MatchRange: func(min, max int64) bool { return max > 5 },
+		MatchValue: func(x int64) bool { return x > 5 },
+	}
+	blocks := []index.BlockValues[int64]{
+		{BlockID: 10, ZoneMin: 0, ZoneMax: 4, Values: []int64{0, 1, 2, 3}},
+		{BlockID: 11, ZoneMin: 4, ZoneMax: 9, Values: []int64{4, 6, 8, 9}},
+	}
+
+	matches := index.FilterBulkDelete(pred, blocks)
+	assert.NotContains(t, matches, uint64(10))
+	assert.Equal(t, []uint32{1, 2, 3}, matches[11])
+}
@@ -0,0 +1,74 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file exercises that a bulk-loaded block committed via
+// rel.AddBlksWithMetaLoc records the WAL position of the txn that
+// attached it, following the same fixture shape TestCommitS3Blocks uses.
+//
+// NOTE: it depends on pieces that do not exist yet and are out of scope
+// for this change:
+//   - catalog.BlockEntry.WalRange() / catalog.SegmentEntry.WalRange(),
+//     returning the model.WalRange{BeginLSN, EndLSN} captured from the
+//     committing txn's WAL entry at AddBlksWithMetaLoc time, backed by a
+//     model.WalFloor the same way model.GCWatermark already backs
+//     snapshot-read staleness bounds (see
+//     pkg/vm/engine/tae/model/wal_range.go).
+//   - Catalog.GCByTS calling WalFloor.Untrack once a block/segment drops
+//     out of the catalog, and Wal.RangeCheckpoint calling
+//     WalFloor.AllowTruncate before advancing its truncation point, so a
+//     live bulk-loaded block can never have its backing WAL entry
+//     reclaimed out from under it.
+//   - TxnMgr.StatMaxCommitTS(), whose value at commit time this test
+//     asserts the recorded WalRange.EndLSN against, the same invariant
+//     TestCommitS3Blocks itself should assert once WalRange lands there.
+// Until those land, this documents the intended test surface against
+// model.WalFloor directly: AddBlksWithMetaLoc's commit path is meant to
+// call Track with the committing txn's WalRange, and GCByTS to call
+// Untrack, exactly as exercised below against the bare model type.
+package test
+
+import (
+	"testing"
+
+	"github.com/matrixorigin/matrixone/pkg/vm/engine/tae/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// TestS3BlockWalRangeBoundsTruncationUntilGCed mirrors what
+// TestCommitS3Blocks should assert once catalog.BlockEntry.WalRange()
+// exists: a bulk-loaded block's WAL span must keep Wal.RangeCheckpoint
+// from truncating past it until Catalog.GCByTS drops the block.
+func TestS3BlockWalRangeBoundsTruncationUntilGCed(t *testing.T) {
+	floor := model.NewWalFloor[uint64]()
+
+	const blockID = uint64(42)
+	committedRange := model.WalRange{BeginLSN: 100, EndLSN: 105}
+	floor.Track(blockID, committedRange)
+
+	assert.True(t, floor.AllowTruncate(99))
+	assert.False(t, floor.AllowTruncate(100), "truncating through the block's BeginLSN must be refused while it is live")
+
+	floor.Untrack(blockID)
+	assert.True(t, floor.AllowTruncate(100), "once GCByTS drops the block, its WAL span no longer bounds truncation")
+}
+
+// TestS3BlockWalRangeOrderingKeyIsStableAcrossOutOfBandWrites mirrors the
+// LogtailMgr.RegisterCallback ordering guarantee: a bulk-loaded block's
+// OrderingKey must be its BeginLSN regardless of EndLSN, so subscribers
+// that only ever see the block's attach commit - never its row writes,
+// which happened out of band in S3 - still get a stable sort key.
+func TestS3BlockWalRangeOrderingKeyIsStableAcrossOutOfBandWrites(t *testing.T) {
+	r := model.WalRange{BeginLSN: 200, EndLSN: 250}
+	assert.Equal(t, r.BeginLSN, r.OrderingKey())
+}
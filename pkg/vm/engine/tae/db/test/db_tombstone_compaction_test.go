@@ -0,0 +1,101 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// This file exercises a CN-pushed bulk DELETE the way TestApplyDeltalocation2
+// (db_test.go) exercises tae.TryDeleteByDeltaloc, but against an
+// externally-produced tombstone.Reader file instead of the per-call
+// delta-location object TryDeleteByDeltaloc writes today, and then
+// against a Compact-merged file covering several such deletes.
+//
+// NOTE: it depends on two pieces that do not exist yet and are out of
+// scope for this change:
+//   - Relation.DeleteByDeltaloc(tombstoneFile), which would read a
+//     tombstone.Reader's entries for the relation's blocks and commit
+//     them the same way TryDeleteByDeltaloc commits its own
+//     delta-location object, so a bulk DELETE pushed down from CN never
+//     has to materialize matched rows into the in-memory delete chain.
+//   - jobs.MergeTombstones, the background task alongside
+//     jobs.NewCompactBlockTask that calls tombstone.Compact for a
+//     segment's accumulated tombstone files and swaps the merged result
+//     in once it's synced, the same way CompactBlockTask swaps in a
+//     compacted block.
+// Until those land, this documents the intended surface directly against
+// tombstone.Writer/Reader/Compact: a tombstone file written for a
+// segment's blocks, and the result of merging several of them, both
+// survive a round trip through disk with no row lost or duplicated.
+package test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/matrixorigin/matrixone/pkg/vm/engine/tae/dataio/tombstone"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestTombstoneFileCoversDeltalocBlocks mirrors the deltaloc-commit shape
+// of TestApplyDeltalocation2: the rows a bulk DELETE targets across two
+// blocks of the same segment are recorded in one tombstone file, and a
+// lookup by block sees exactly its own rows.
+func TestTombstoneFileCoversDeltalocBlocks(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "segment-1.tombstone")
+
+	w := tombstone.NewWriter()
+	w.Add(1, 3)
+	w.Add(1, 5)
+	w.Add(2, 0)
+	require.NoError(t, w.Write(path))
+
+	r, err := tombstone.Open(path)
+	require.NoError(t, err)
+
+	blk1, err := r.Lookup(1)
+	require.NoError(t, err)
+	assert.ElementsMatch(t, []tombstone.Entry{{BlockID: 1, RowOffset: 3}, {BlockID: 1, RowOffset: 5}}, blk1)
+
+	blk2, err := r.Lookup(2)
+	require.NoError(t, err)
+	assert.Equal(t, []tombstone.Entry{{BlockID: 2, RowOffset: 0}}, blk2)
+}
+
+// TestMergeTombstonesDedupesAcrossFiles mirrors the "test compact"
+// section of TestApplyDeltalocation2: a row re-targeted by a second bulk
+// DELETE before the segment's tombstone files are merged must appear
+// exactly once in the merged result, the same way a re-deleted row stays
+// deleted exactly once after CompactBlocks folds two delta-location
+// objects together.
+func TestMergeTombstonesDedupesAcrossFiles(t *testing.T) {
+	dir := t.TempDir()
+
+	first := tombstone.NewWriter()
+	first.Add(1, 3)
+	firstPath := filepath.Join(dir, "segment-1.0.tombstone")
+	require.NoError(t, first.Write(firstPath))
+
+	second := tombstone.NewWriter()
+	second.Add(1, 3) // same row targeted again before the merge runs
+	second.Add(1, 5)
+	secondPath := filepath.Join(dir, "segment-1.1.tombstone")
+	require.NoError(t, second.Write(secondPath))
+
+	mergedPath := filepath.Join(dir, "segment-1.tombstone")
+	require.NoError(t, tombstone.Compact([]string{firstPath, secondPath}, mergedPath))
+
+	r, err := tombstone.Open(mergedPath)
+	require.NoError(t, err)
+	all, err := r.All()
+	require.NoError(t, err)
+	assert.Len(t, all, 2)
+}
@@ -0,0 +1,127 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ddl queues and drives the heavier ALTER TABLE operations
+// (modify column, add index, the reorg that follows a column drop) in the
+// background instead of inside the user's txn, the way TestAlterTableBasic
+// and friends run them today. It is deliberately layered above
+// reorg.SchemaReorgJob and reorg.ShadowColumn rather than replacing them:
+// a Runner here is whatever already knows how to make one checkpointed
+// call of progress on the underlying alter (a SchemaReorgJob wrapper
+// today, perhaps an add-index builder later), and this package only owns
+// sequencing it through a persisted queued/running/reorg/done/cancelled/
+// rollback state machine, surfacing that state for a mo_ddl_jobs catalog
+// view, and publishing a state-transition Event so CN logtail caches
+// invalidate promptly. It has no notion of catalog.TableEntry schema
+// versions, ALTER request bodies, or TxnMgr: translating an ALTER into a
+// Runner and calling Submit/Cancel here is left to the caller, and
+// neither Relation nor TxnMgr has a method that does so.
+package ddl
+
+import "context"
+
+// JobState is where a queued DDL job is in its lifecycle.
+type JobState uint8
+
+const (
+	// JobQueued: the job has been persisted but no Worker has picked it up
+	// yet.
+	JobQueued JobState = iota
+	// JobRunning: a Worker has claimed the job and is about to call its
+	// Runner for the first time.
+	JobRunning
+	// JobReorg: the Runner's background conversion is actively in
+	// progress, checkpointing RowsProcessed/CurrentSegmentID between calls.
+	JobReorg
+	// JobDone: the Runner reported done and the shadow schema/objects were
+	// swapped in.
+	JobDone
+	// JobCancelled: JobQueue.Cancel flipped the job before it finished;
+	// the Worker driving it will roll back the shadow on its next check.
+	JobCancelled
+	// JobRollback: the Runner failed, or a cancelled job's shadow has been
+	// unwound; LastError (if any) explains why.
+	JobRollback
+)
+
+// String names a JobState the way a SHOW-DDL-style CN query over
+// mo_ddl_jobs would want to render it.
+func (s JobState) String() string {
+	switch s {
+	case JobQueued:
+		return "queued"
+	case JobRunning:
+		return "running"
+	case JobReorg:
+		return "reorg"
+	case JobDone:
+		return "done"
+	case JobCancelled:
+		return "cancelled"
+	case JobRollback:
+		return "rollback"
+	default:
+		return "unknown"
+	}
+}
+
+// Job is one row of the job queue, persisted by Store and read back by
+// the mo_ddl_jobs catalog view for SHOW-DDL-style CN queries.
+type Job struct {
+	ID    string
+	State JobState
+
+	RowsProcessed    uint64
+	EstimatedTotal   uint64
+	CurrentSegmentID uint64
+	// LastError is set when State is JobRollback because the Runner
+	// failed; empty for a cancellation-only rollback.
+	LastError string
+}
+
+// Store persists Job rows the way a system table backing mo_ddl_jobs
+// would: Worker reads and writes through it so a restarted tae.Restart(ctx)
+// sees exactly the state the job was in when the engine stopped, and
+// JobQueue reads it back for Cancel and for the catalog view.
+type Store interface {
+	Save(ctx context.Context, job Job) error
+	// Load returns ok=false if jobID has never been saved.
+	Load(ctx context.Context, jobID string) (job Job, ok bool, err error)
+	// List returns every job the store holds, in no particular order; the
+	// mo_ddl_jobs view is responsible for any ordering a CN query wants.
+	List(ctx context.Context) ([]Job, error)
+}
+
+// Runner makes one checkpointed increment of progress on a queued job's
+// actual alter, mirroring reorg.SchemaReorgJob.Run's call-until-done
+// shape one layer up: Worker keeps calling Run until it reports done,
+// persisting Progress after every call so a crash between calls resumes
+// against whatever the Runner's own restart bookkeeping (e.g.
+// reorg.ProgressStore) left off, rather than starting the alter over.
+type Runner interface {
+	Run(ctx context.Context) (done bool, err error)
+	// Progress reports the job's current RowsProcessed, EstimatedTotal,
+	// and CurrentSegmentID, for Worker to checkpoint into Store after
+	// every Run call.
+	Progress() (rowsProcessed, estimatedTotal, currentSegmentID uint64)
+}
+
+// Rollbacker is implemented by a Runner whose underlying shadow state
+// needs explicit unwinding when Worker observes a cancellation, the same
+// rollback reorg.ShadowColumn.Rollback already performs for a failed
+// SchemaReorgJob. A Runner that has nothing to unwind (cancellation
+// before any shadow object existed) need not implement it.
+type Rollbacker interface {
+	Rollback(ctx context.Context) error
+}
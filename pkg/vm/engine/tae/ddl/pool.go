@@ -0,0 +1,66 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ddl
+
+import "context"
+
+// RunnerFactory builds the Runner for a queued job once Pool dequeues its
+// ID. This package never builds one itself: it stays ignorant of
+// catalog.TableEntry schema versions and what kind of ALTER jobID even
+// is, the same way reorg stays ignorant of catalog.Schema. The real
+// factory looks jobID up against whatever table/request submitted it and
+// returns a reorg.SchemaReorgJob (wrapped to satisfy Runner) or a future
+// add-index builder.
+type RunnerFactory func(ctx context.Context, jobID string) (Runner, error)
+
+// Pool repeatedly dequeues one job from queue and drives it to
+// completion with worker. Only JobQueued rows are dequeued: a row a crash
+// left in JobRunning or JobReorg is not picked up by Pool, and resuming
+// it takes building its Runner and calling Worker.Drive on it directly —
+// resumption beyond that point is the Runner's own restart bookkeeping,
+// e.g. reorg.SchemaReorgJob's ProgressStore.
+type Pool struct {
+	queue   *JobQueue
+	worker  *Worker
+	factory RunnerFactory
+}
+
+// NewPool returns a Pool draining queue with worker, building each
+// dequeued job's Runner via factory.
+func NewPool(queue *JobQueue, worker *Worker, factory RunnerFactory) *Pool {
+	return &Pool{queue: queue, worker: worker, factory: factory}
+}
+
+// RunOnce dequeues and drives at most one job to completion, returning
+// found=false if the queue had nothing JobQueued. Callers loop RunOnce
+// from however many goroutines they want servicing the queue concurrently;
+// this package does not spawn any goroutines of its own so the caller
+// controls its own shutdown the way the rest of tae's background tasks
+// already do.
+func (p *Pool) RunOnce(ctx context.Context) (found bool, err error) {
+	jobID, ok, err := p.queue.Dequeue(ctx)
+	if err != nil {
+		return false, err
+	}
+	if !ok {
+		return false, nil
+	}
+
+	runner, err := p.factory(ctx, jobID)
+	if err != nil {
+		return true, err
+	}
+	return true, p.worker.Drive(ctx, jobID, runner)
+}
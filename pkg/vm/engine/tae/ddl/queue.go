@@ -0,0 +1,131 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ddl
+
+import (
+	"context"
+	"fmt"
+	"sync"
+)
+
+// Event is published on every job state transition so a CN's logtail
+// cache can invalidate its mo_ddl_jobs view promptly instead of waiting
+// for its next poll.
+type Event struct {
+	JobID string
+	From  JobState
+	To    JobState
+}
+
+// EventSink receives Events. The real implementation publishes onto the
+// same logtail stream HandleSubscribeLogTail already drains; this package
+// only depends on the interface so it never has to import logtail's
+// transport.
+type EventSink interface {
+	Publish(ctx context.Context, evt Event) error
+}
+
+// JobQueue is the Store-backed surface for submitting and cancelling DDL
+// jobs: Submit persists a new JobQueued row and returns the jobID a
+// Worker will later pick up; Cancel flips a job to JobCancelled so the
+// Worker driving it rolls back on its next check rather than continuing
+// to make progress. Neither Relation nor TxnMgr has a method that calls
+// it.
+type JobQueue struct {
+	store  Store
+	events EventSink
+
+	mu     sync.Mutex
+	nextID uint64
+}
+
+// NewJobQueue returns a queue persisting jobs to store and publishing
+// transitions to events. events may be nil, in which case transitions are
+// simply not published (e.g. in a test with no CN to notify).
+func NewJobQueue(store Store, events EventSink) *JobQueue {
+	return &JobQueue{store: store, events: events}
+}
+
+// Submit persists a new job in JobQueued with the given estimated row
+// count and returns its ID.
+func (q *JobQueue) Submit(ctx context.Context, estimatedTotal uint64) (jobID string, err error) {
+	q.mu.Lock()
+	q.nextID++
+	jobID = fmt.Sprintf("ddl-job-%d", q.nextID)
+	q.mu.Unlock()
+
+	job := Job{ID: jobID, State: JobQueued, EstimatedTotal: estimatedTotal}
+	if err := q.store.Save(ctx, job); err != nil {
+		return "", fmt.Errorf("ddl: submit job %s: %w", jobID, err)
+	}
+	// From==To==JobQueued marks job creation itself as an event, since
+	// there is no prior state to report; a CN subscriber treats it the
+	// same as any other transition and just learns the job now exists.
+	q.publish(ctx, jobID, JobQueued, JobQueued)
+	return jobID, nil
+}
+
+// Cancel flips jobID to JobCancelled. It is a no-op returning an error if the job is
+// already in a terminal state (JobDone/JobCancelled/JobRollback) or does
+// not exist: a finished job has nothing left to cancel.
+func (q *JobQueue) Cancel(ctx context.Context, jobID string) error {
+	job, ok, err := q.store.Load(ctx, jobID)
+	if err != nil {
+		return fmt.Errorf("ddl: cancel job %s: %w", jobID, err)
+	}
+	if !ok {
+		return fmt.Errorf("ddl: cancel job %s: not found", jobID)
+	}
+	if job.State == JobDone || job.State == JobCancelled || job.State == JobRollback {
+		return fmt.Errorf("ddl: cancel job %s: already in terminal state %s", jobID, job.State)
+	}
+
+	from := job.State
+	job.State = JobCancelled
+	if err := q.store.Save(ctx, job); err != nil {
+		return fmt.Errorf("ddl: cancel job %s: %w", jobID, err)
+	}
+	q.publish(ctx, jobID, from, JobCancelled)
+	return nil
+}
+
+// Dequeue picks one JobQueued job in the order Store.List returns them
+// and marks none of it claimed: Worker.Drive still does the
+// JobQueued->JobRunning transition itself, so two Pool goroutines racing
+// Dequeue on the same jobID is harmless as long as only one actually
+// drives it, which is the caller's responsibility in a single-process
+// Pool.
+func (q *JobQueue) Dequeue(ctx context.Context) (jobID string, ok bool, err error) {
+	jobs, err := q.store.List(ctx)
+	if err != nil {
+		return "", false, fmt.Errorf("ddl: dequeue: %w", err)
+	}
+	for _, job := range jobs {
+		if job.State == JobQueued {
+			return job.ID, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+func (q *JobQueue) publish(ctx context.Context, jobID string, from, to JobState) {
+	if q.events == nil {
+		return
+	}
+	// A CN missing one cache-invalidation event is far cheaper than a
+	// Submit/Cancel failing because its event sink hiccupped, so publish
+	// errors are deliberately dropped rather than propagated.
+	_ = q.events.Publish(ctx, Event{JobID: jobID, From: from, To: to})
+}
@@ -0,0 +1,137 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ddl
+
+import (
+	"context"
+	"fmt"
+)
+
+// Worker drives one job's Runner forward to completion, checkpointing
+// Job.State and Progress to Store after every Run call so a crash
+// between calls resumes from the persisted row rather than losing
+// track of how far the alter got. Pool only dequeues JobQueued rows and
+// nothing in this package hands a still-JobRunning/JobReorg row back to
+// it: such a row resumes when its Runner is rebuilt and Drive is called
+// on it directly.
+type Worker struct {
+	store  Store
+	events EventSink
+}
+
+// NewWorker returns a Worker checkpointing to store and publishing
+// transitions to events (which may be nil, as in JobQueue).
+func NewWorker(store Store, events EventSink) *Worker {
+	return &Worker{store: store, events: events}
+}
+
+// Drive repeatedly calls runner.Run until it reports done, jobID is
+// observed as JobCancelled, or Run fails. It transitions JobQueued (or a
+// resumed JobRunning/JobReorg) forward, persisting runner.Progress()
+// after every call, and returns nil once the job reaches JobDone or a
+// clean JobCancelled rollback — only a Run failure or a Store error is
+// returned as an error, since cancellation is the caller's own request,
+// not a failure of this job.
+func (w *Worker) Drive(ctx context.Context, jobID string, runner Runner) error {
+	job, ok, err := w.store.Load(ctx, jobID)
+	if err != nil {
+		return fmt.Errorf("ddl: drive job %s: %w", jobID, err)
+	}
+	if !ok {
+		return fmt.Errorf("ddl: drive job %s: not found", jobID)
+	}
+	if job.State == JobQueued {
+		if err := w.transition(ctx, &job, JobRunning); err != nil {
+			return err
+		}
+	}
+
+	for {
+		current, ok, err := w.store.Load(ctx, jobID)
+		if err != nil {
+			return fmt.Errorf("ddl: drive job %s: %w", jobID, err)
+		}
+		if !ok {
+			return fmt.Errorf("ddl: drive job %s: disappeared mid-run", jobID)
+		}
+		if current.State == JobCancelled {
+			return w.rollback(ctx, &current, runner, "")
+		}
+		job = current
+
+		done, runErr := runner.Run(ctx)
+		rows, total, seg := runner.Progress()
+		job.RowsProcessed = rows
+		job.EstimatedTotal = total
+		job.CurrentSegmentID = seg
+
+		if runErr != nil {
+			return w.rollback(ctx, &job, runner, runErr.Error())
+		}
+
+		from := job.State
+		if job.State == JobRunning {
+			job.State = JobReorg
+		}
+		if done {
+			job.State = JobDone
+		}
+		if err := w.store.Save(ctx, job); err != nil {
+			return fmt.Errorf("ddl: drive job %s: %w", jobID, err)
+		}
+		if job.State != from {
+			w.publish(ctx, jobID, from, job.State)
+		}
+		if done {
+			return nil
+		}
+	}
+}
+
+func (w *Worker) transition(ctx context.Context, job *Job, to JobState) error {
+	from := job.State
+	job.State = to
+	if err := w.store.Save(ctx, *job); err != nil {
+		return fmt.Errorf("ddl: drive job %s: %w", job.ID, err)
+	}
+	w.publish(ctx, job.ID, from, to)
+	return nil
+}
+
+func (w *Worker) rollback(ctx context.Context, job *Job, runner Runner, lastErr string) error {
+	from := job.State
+	job.State = JobRollback
+	job.LastError = lastErr
+	if rb, ok := runner.(Rollbacker); ok {
+		if err := rb.Rollback(ctx); err != nil {
+			job.LastError = err.Error()
+		}
+	}
+	if err := w.store.Save(ctx, *job); err != nil {
+		return fmt.Errorf("ddl: drive job %s: %w", job.ID, err)
+	}
+	w.publish(ctx, job.ID, from, JobRollback)
+	if lastErr != "" {
+		return fmt.Errorf("ddl: job %s failed: %s", job.ID, lastErr)
+	}
+	return nil
+}
+
+func (w *Worker) publish(ctx context.Context, jobID string, from, to JobState) {
+	if w.events == nil {
+		return
+	}
+	_ = w.events.Publish(ctx, Event{JobID: jobID, From: from, To: to})
+}
@@ -0,0 +1,109 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package dedup is the txnif.SnapshotBoundaryChecker hook: when a txn sets
+// txnif.IncrementalDedup, it only consults keys committed up to its
+// SnapshotTS rather than the full table, on the assumption that nothing
+// committed after SnapshotTS can conflict with a key it is about to write.
+// BoundaryChecker lets the commit path verify that assumption instead of
+// trusting it - it records the exact [SnapshotTS, CommitTS) window a dedup
+// skip relied on, and at commit time cross-checks it against whatever
+// actually landed in that window.
+package dedup
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/matrixorigin/matrixone/pkg/container/types"
+	"github.com/matrixorigin/matrixone/pkg/vm/engine/tae/failpoint"
+)
+
+// ErrStaleSnapshotDedup is the package-local stand-in for the not-yet-wired
+// moerr.ErrStaleSnapshotDedup: it lets a caller doing snapshot-consistent
+// bulk load distinguish "your snapshot was too old to safely skip dedup"
+// from a plain write-write conflict, which BatchDedup already reports on
+// its own.
+var ErrStaleSnapshotDedup = errors.New("dedup: snapshot too old, a conflicting key committed inside the skipped range")
+
+// Key is a dedup key, opaque to this package - whatever BatchDedup already
+// hashes a row's primary key down to.
+type Key any
+
+// Range is the [Snapshot, Commit) window an IncrementalDedup txn relied on
+// for one table: it skipped checking keys committed in this range on the
+// assumption none of them conflict with what it is about to write.
+type Range struct {
+	TableID  uint64
+	Snapshot types.TS
+	Commit   types.TS
+}
+
+// ConflictSource answers which keys, for one table, were actually
+// committed by some other writer with a commit TS inside [from, to) - what
+// BoundaryChecker.CheckAtCommit cross-checks a recorded Range against. A
+// caller wires this to catalog.TableEntry's MVCC index.
+type ConflictSource interface {
+	CommittedKeysInRange(ctx context.Context, tableID uint64, from, to types.TS) (map[Key]bool, error)
+}
+
+// BoundaryChecker accumulates the Ranges one txn's IncrementalDedup skips
+// relied on, so its commit path can confirm none of them actually missed a
+// conflict before applying.
+type BoundaryChecker struct {
+	mu     sync.Mutex
+	ranges []Range
+}
+
+// NewBoundaryChecker returns an empty BoundaryChecker, one per txn.
+func NewBoundaryChecker() *BoundaryChecker {
+	return &BoundaryChecker{}
+}
+
+// Record notes that the txn skipped full dedup against tableID, relying on
+// nothing conflicting having committed in [snapshot, commit).
+func (c *BoundaryChecker) Record(tableID uint64, snapshot, commit types.TS) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.ranges = append(c.ranges, Range{TableID: tableID, Snapshot: snapshot, Commit: commit})
+}
+
+// CheckAtCommit asks src which keys actually committed inside every
+// recorded Range and returns ErrStaleSnapshotDedup the first time one of
+// them is also a key the txn wrote for that table, meaning the skipped
+// dedup window missed a real conflict. writeSet maps a table ID to the
+// keys the txn is about to commit for it.
+func (c *BoundaryChecker) CheckAtCommit(ctx context.Context, src ConflictSource, writeSet map[uint64]map[Key]bool) error {
+	c.mu.Lock()
+	ranges := append([]Range(nil), c.ranges...)
+	c.mu.Unlock()
+
+	for _, r := range ranges {
+		if err := failpoint.Eval(failpoint.DedupBeforeSnapshotBoundaryCheck); err != nil {
+			return err
+		}
+		committed, err := src.CommittedKeysInRange(ctx, r.TableID, r.Snapshot, r.Commit)
+		if err != nil {
+			return err
+		}
+		keys := writeSet[r.TableID]
+		for k := range committed {
+			if keys[k] {
+				return ErrStaleSnapshotDedup
+			}
+		}
+	}
+	return nil
+}
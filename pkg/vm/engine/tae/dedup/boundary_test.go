@@ -0,0 +1,70 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dedup
+
+import (
+	"context"
+	"testing"
+
+	"github.com/matrixorigin/matrixone/pkg/container/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeConflictSource struct {
+	committed map[uint64]map[Key]bool
+}
+
+func (s *fakeConflictSource) CommittedKeysInRange(_ context.Context, tableID uint64, _, _ types.TS) (map[Key]bool, error) {
+	return s.committed[tableID], nil
+}
+
+func ts(physical int64) types.TS {
+	return types.BuildTS(physical, 0)
+}
+
+func TestBoundaryCheckerPassesWhenNoConflictCommitted(t *testing.T) {
+	c := NewBoundaryChecker()
+	c.Record(1, ts(10), ts(20))
+
+	src := &fakeConflictSource{committed: map[uint64]map[Key]bool{1: {"other-key": true}}}
+	err := c.CheckAtCommit(context.Background(), src, map[uint64]map[Key]bool{1: {"my-key": true}})
+	require.NoError(t, err)
+}
+
+func TestBoundaryCheckerFailsWhenSkippedRangeMissedAConflict(t *testing.T) {
+	c := NewBoundaryChecker()
+	c.Record(1, ts(10), ts(20))
+
+	src := &fakeConflictSource{committed: map[uint64]map[Key]bool{1: {"my-key": true}}}
+	err := c.CheckAtCommit(context.Background(), src, map[uint64]map[Key]bool{1: {"my-key": true}})
+	assert.ErrorIs(t, err, ErrStaleSnapshotDedup)
+}
+
+func TestBoundaryCheckerIgnoresConflictsInOtherTables(t *testing.T) {
+	c := NewBoundaryChecker()
+	c.Record(1, ts(10), ts(20))
+
+	src := &fakeConflictSource{committed: map[uint64]map[Key]bool{2: {"my-key": true}}}
+	err := c.CheckAtCommit(context.Background(), src, map[uint64]map[Key]bool{1: {"my-key": true}})
+	require.NoError(t, err)
+}
+
+func TestBoundaryCheckerNoRecordedRangesIsNoOp(t *testing.T) {
+	c := NewBoundaryChecker()
+	src := &fakeConflictSource{}
+	err := c.CheckAtCommit(context.Background(), src, map[uint64]map[Key]bool{1: {"my-key": true}})
+	require.NoError(t, err)
+}
@@ -0,0 +1,154 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package failpoint lets a test deterministically drive a crash or delay
+// at a named point inside the commit/collection path, the way
+// pkg/util/fault lets a test inject a flush timeout today, but cheap
+// enough to sprinkle at call-site granularity. dedup.BoundaryChecker's
+// snapshot boundary check (see DedupBeforeSnapshotBoundaryCheck below)
+// is the one injection point that actually has a call site in this
+// checkout today; txn.ApplyCommit, blockData, logtail's delete-batch
+// emission and dirtyCollector's segment shrink - the other junctures a
+// fuller commit/GC/logtail crash-test harness would want - don't exist
+// in this tree yet, so this package doesn't carry constants for them.
+// Each call site that does exist is meant to call Eval(name) and act on
+// the error it returns; the package itself does not know what
+// PrepareCommit, ApplyCommit, or a delta location actually are - every
+// name is just an opaque string a test and its call site agree on.
+//
+// Eval is a no-op - one atomic load, no map access - unless the process
+// called Enable, and Enable itself is a no-op unless the enableEnvVar
+// environment variable is set, so leaving injection calls in production
+// code costs nothing and can never be turned on by accident.
+package failpoint
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// enableEnvVar gates Enable: production builds never set it, so Enable
+// silently does nothing and Eval never pays more than an atomic load.
+const enableEnvVar = "MO_TAE_FAILPOINTS"
+
+// Named injection points this package's call sites are meant to share.
+const (
+	// DedupBeforeSnapshotBoundaryCheck forces dedup.BoundaryChecker to
+	// behave as if it found a conflicting key, the equivalent of the
+	// "tae: stale snapshot mismatch" injection point a test drives to
+	// exercise dedup.ErrStaleSnapshotDedup without racing an actual
+	// concurrent committer.
+	DedupBeforeSnapshotBoundaryCheck = "dedup.beforeSnapshotBoundaryCheck"
+)
+
+// ActionKind selects what Eval does once Gate allows it to fire.
+type ActionKind uint8
+
+const (
+	// ActionReturn makes Eval return Action.Err.
+	ActionReturn ActionKind = iota
+	// ActionPanic makes Eval panic with Action.Err's message.
+	ActionPanic
+	// ActionSleep makes Eval sleep for Action.Sleep before returning nil.
+	ActionSleep
+)
+
+// Action is what a registered failpoint does once its Gate allows it.
+type Action struct {
+	Kind  ActionKind
+	Err   error
+	Sleep time.Duration
+}
+
+// Sleep returns an Action that pauses for d and otherwise lets the call
+// proceed.
+func Sleep(d time.Duration) Action { return Action{Kind: ActionSleep, Sleep: d} }
+
+// Panic returns an Action that panics with msg.
+func Panic(msg string) Action { return Action{Kind: ActionPanic, Err: fmt.Errorf("%s", msg)} }
+
+// Return returns an Action that makes Eval return err.
+func Return(err error) Action { return Action{Kind: ActionReturn, Err: err} }
+
+var (
+	enabled atomic.Bool
+
+	mu     sync.Mutex
+	points = make(map[string]*point)
+)
+
+type point struct {
+	action Action
+	gate   Gate
+}
+
+// Enable registers action at name, gated by gate (use Always() if every
+// call should fire it). It does nothing unless enableEnvVar is set, so a
+// production build that forgets to strip a test's Enable call still runs
+// clean.
+func Enable(name string, action Action, gate Gate) {
+	if os.Getenv(enableEnvVar) == "" {
+		return
+	}
+	enabled.Store(true)
+	mu.Lock()
+	defer mu.Unlock()
+	if gate == nil {
+		gate = Always()
+	}
+	points[name] = &point{action: action, gate: gate}
+}
+
+// Disable removes whatever is registered at name, if anything.
+func Disable(name string) {
+	mu.Lock()
+	defer mu.Unlock()
+	delete(points, name)
+}
+
+// DisableAll removes every registered failpoint, e.g. between subtests.
+func DisableAll() {
+	mu.Lock()
+	defer mu.Unlock()
+	points = make(map[string]*point)
+}
+
+// Eval fires name's registered Action if its Gate allows this call, and
+// returns the error the call site should return (nil if nothing fired or
+// the Action doesn't produce one). Eval itself executes ActionPanic and
+// ActionSleep, so those forms take effect whatever the call site does
+// with the returned error.
+func Eval(name string) error {
+	if !enabled.Load() {
+		return nil
+	}
+	mu.Lock()
+	p, ok := points[name]
+	mu.Unlock()
+	if !ok || !p.gate.Allow() {
+		return nil
+	}
+	switch p.action.Kind {
+	case ActionSleep:
+		time.Sleep(p.action.Sleep)
+		return nil
+	case ActionPanic:
+		panic(p.action.Err)
+	default:
+		return p.action.Err
+	}
+}
@@ -0,0 +1,100 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package failpoint
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func withEnabled(t *testing.T) {
+	t.Helper()
+	require.NoError(t, os.Setenv(enableEnvVar, "1"))
+	t.Cleanup(func() {
+		DisableAll()
+		enabled.Store(false)
+		os.Unsetenv(enableEnvVar)
+	})
+}
+
+func TestEvalIsNoopWithoutEnableEnvVar(t *testing.T) {
+	os.Unsetenv(enableEnvVar)
+	t.Cleanup(func() { DisableAll(); enabled.Store(false) })
+
+	Enable("x", Return(errors.New("boom")), Always())
+	assert.NoError(t, Eval("x"))
+}
+
+func TestEvalReturnsRegisteredError(t *testing.T) {
+	withEnabled(t)
+	want := errors.New("injected")
+	Enable("x", Return(want), Always())
+	assert.ErrorIs(t, Eval("x"), want)
+}
+
+func TestEvalPanics(t *testing.T) {
+	withEnabled(t)
+	Enable("x", Panic("injected crash"), Always())
+	assert.Panics(t, func() { _ = Eval("x") })
+}
+
+func TestEvalSleeps(t *testing.T) {
+	withEnabled(t)
+	Enable("x", Sleep(10*time.Millisecond), Always())
+	start := time.Now()
+	assert.NoError(t, Eval("x"))
+	assert.GreaterOrEqual(t, time.Since(start), 10*time.Millisecond)
+}
+
+func TestDisableRemovesFailpoint(t *testing.T) {
+	withEnabled(t)
+	Enable("x", Return(errors.New("boom")), Always())
+	Disable("x")
+	assert.NoError(t, Eval("x"))
+}
+
+// TestCountGateFiresOnlyOnNthCall models "on the 3rd call, panic": the
+// first two Eval calls must pass through clean, and only the third must
+// panic.
+func TestCountGateFiresOnlyOnNthCall(t *testing.T) {
+	withEnabled(t)
+	Enable("x", Panic("crash on 3rd call"), Count(3))
+
+	assert.NotPanics(t, func() { assert.NoError(t, Eval("x")) })
+	assert.NotPanics(t, func() { assert.NoError(t, Eval("x")) })
+	assert.Panics(t, func() { _ = Eval("x") })
+}
+
+func TestProbabilityGateNeverFiresAtZero(t *testing.T) {
+	withEnabled(t)
+	Enable("x", Return(errors.New("boom")), Probability(0))
+	for i := 0; i < 1000; i++ {
+		assert.NoError(t, Eval("x"))
+	}
+}
+
+func TestProbabilityGateAlwaysFiresAtOne(t *testing.T) {
+	withEnabled(t)
+	want := errors.New("boom")
+	Enable("x", Return(want), Probability(1))
+	for i := 0; i < 100; i++ {
+		assert.ErrorIs(t, Eval("x"), want)
+	}
+}
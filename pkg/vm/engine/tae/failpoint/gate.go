@@ -0,0 +1,61 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package failpoint
+
+import (
+	"math/rand"
+	"sync/atomic"
+)
+
+// Gate decides, for one Eval call, whether a registered Action actually
+// fires.
+type Gate interface {
+	Allow() bool
+}
+
+type alwaysGate struct{}
+
+func (alwaysGate) Allow() bool { return true }
+
+// Always returns a Gate that fires on every call.
+func Always() Gate { return alwaysGate{} }
+
+type probabilityGate struct {
+	p float64
+}
+
+func (g probabilityGate) Allow() bool { return rand.Float64() < g.p }
+
+// Probability returns a Gate that fires with probability p (e.g. 0.01 for
+// the "1%" case), independently on every call.
+func Probability(p float64) Gate { return probabilityGate{p: p} }
+
+// countGate fires exactly once, on the nth call to Allow.
+type countGate struct {
+	remaining atomic.Int32
+}
+
+func (g *countGate) Allow() bool {
+	return g.remaining.Add(-1) == 0
+}
+
+// Count returns a Gate that fires on exactly the nth call to Allow (1
+// fires on the first call, 3 fires on the third), so a test can express
+// "on the 3rd commit, panic before ApplyCommit".
+func Count(n int) Gate {
+	g := &countGate{}
+	g.remaining.Store(int32(n))
+	return g
+}
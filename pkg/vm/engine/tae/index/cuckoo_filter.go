@@ -0,0 +1,243 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package index
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+
+	"github.com/RoaringBitmap/roaring"
+	"github.com/matrixorigin/matrixone/pkg/container/types"
+	"github.com/matrixorigin/matrixone/pkg/vm/engine/tae/containers"
+)
+
+const (
+	cuckooBucketSize  = 4
+	cuckooMaxKicks    = 500
+	cuckooFingerprint = 8 // bits
+)
+
+// CuckooFilter is an approximate-membership filter that, unlike the
+// binary fuse and ribbon backends, supports removing a previously
+// inserted key. It stores an 8-bit fingerprint of each key in one of two
+// candidate buckets (the second derived by XOR-ing the first with a hash
+// of the fingerprint, so it's recoverable from the fingerprint alone at
+// delete time), kicking existing entries to their alternate bucket on
+// collision the way a cuckoo hash table does.
+//
+// Meant for secondary indexes, where deletes must be reflected in the
+// filter itself rather than only in the underlying data.
+type CuckooFilter struct {
+	numBuckets int
+	buckets    [][cuckooBucketSize]byte
+	count      int
+	rng        *rand.Rand
+}
+
+func newCuckooFilter() MembershipFilter {
+	return &CuckooFilter{rng: rand.New(rand.NewSource(1))}
+}
+
+func init() {
+	RegisterFilter(FilterCuckoo, newCuckooFilter)
+}
+
+// Build sizes the filter for the given keys and inserts them all.
+func (f *CuckooFilter) Build(keys [][]byte) error {
+	numBuckets := nextPowerOfTwo((len(keys) + cuckooBucketSize - 1) / cuckooBucketSize)
+	if numBuckets < 1 {
+		numBuckets = 1
+	}
+	// a little slack keeps load factor reasonable and kicks rare
+	numBuckets *= 2
+	f.numBuckets = numBuckets
+	f.buckets = make([][cuckooBucketSize]byte, numBuckets)
+	f.count = 0
+	for _, key := range keys {
+		if !f.Add(key) {
+			return fmt.Errorf("index: cuckoo filter insertion failed after %d kicks for a key", cuckooMaxKicks)
+		}
+	}
+	return nil
+}
+
+func (f *CuckooFilter) fingerprint(key []byte) byte {
+	fp := byte(hashBytes(key, 0x2545F4914F6CDD1D))
+	if fp == 0 {
+		fp = 1 // reserve 0 to mean "empty slot"
+	}
+	return fp
+}
+
+func (f *CuckooFilter) index1(key []byte) int {
+	return int(hashBytes(key, 0xC6A4A7935BD1E995) % uint64(f.numBuckets))
+}
+
+func (f *CuckooFilter) index2(i1 int, fp byte) int {
+	h := hashBytes([]byte{fp}, 0x9E3779B97F4A7C15)
+	return (i1 ^ int(h%uint64(f.numBuckets))) % f.numBuckets
+}
+
+// Add inserts key, returning false if it could not be placed within the
+// kick budget (the filter is then left unchanged for that key).
+func (f *CuckooFilter) Add(key []byte) bool {
+	fp := f.fingerprint(key)
+	i1 := f.index1(key)
+	i2 := f.index2(i1, fp)
+
+	if f.insertInto(i1, fp) || f.insertInto(i2, fp) {
+		f.count++
+		return true
+	}
+
+	// both candidate buckets are full: kick a random occupant around
+	i := []int{i1, i2}[f.rng.Intn(2)]
+	type kicked struct{ bucket, slot int }
+	path := make([]kicked, 0, cuckooMaxKicks)
+	for kick := 0; kick < cuckooMaxKicks; kick++ {
+		slot := f.rng.Intn(cuckooBucketSize)
+		path = append(path, kicked{i, slot})
+		fp, f.buckets[i][slot] = f.buckets[i][slot], fp
+		i = f.index2(i, fp)
+		if f.insertInto(i, fp) {
+			f.count++
+			return true
+		}
+	}
+	// undo the kicks in reverse so every displaced fingerprint is back
+	for k := len(path) - 1; k >= 0; k-- {
+		b, s := path[k].bucket, path[k].slot
+		fp, f.buckets[b][s] = f.buckets[b][s], fp
+	}
+	return false
+}
+
+func (f *CuckooFilter) insertInto(bucket int, fp byte) bool {
+	for i, v := range f.buckets[bucket] {
+		if v == 0 {
+			f.buckets[bucket][i] = fp
+			return true
+		}
+	}
+	return false
+}
+
+// Delete removes one occurrence of key, returning whether it was found.
+func (f *CuckooFilter) Delete(key []byte) bool {
+	fp := f.fingerprint(key)
+	i1 := f.index1(key)
+	i2 := f.index2(i1, fp)
+	if f.removeFrom(i1, fp) || f.removeFrom(i2, fp) {
+		f.count--
+		return true
+	}
+	return false
+}
+
+func (f *CuckooFilter) removeFrom(bucket int, fp byte) bool {
+	for i, v := range f.buckets[bucket] {
+		if v == fp {
+			f.buckets[bucket][i] = 0
+			return true
+		}
+	}
+	return false
+}
+
+func (f *CuckooFilter) MayContainsKey(key []byte) (bool, error) {
+	fp := f.fingerprint(key)
+	i1 := f.index1(key)
+	i2 := f.index2(i1, fp)
+	for _, v := range f.buckets[i1] {
+		if v == fp {
+			return true, nil
+		}
+	}
+	for _, v := range f.buckets[i2] {
+		if v == fp {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+func (f *CuckooFilter) MayContainsAnyKeys(keys containers.Vector) (exist bool, sels *roaring.Bitmap, err error) {
+	sels = roaring.New()
+	typ := keys.GetType()
+	keys.Foreach(func(v any, isNull bool, row int) error {
+		if isNull || err != nil {
+			return nil
+		}
+		ok, e := f.MayContainsKey(types.EncodeValue(v, typ))
+		if e != nil {
+			err = e
+			return nil
+		}
+		if ok {
+			sels.Add(uint64(row))
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return false, nil, err
+	}
+	return !sels.IsEmpty(), sels, nil
+}
+
+func (f *CuckooFilter) Encode() ([]byte, error) {
+	buf := make([]byte, 4, 4+f.numBuckets*cuckooBucketSize)
+	binary.LittleEndian.PutUint32(buf, uint32(f.numBuckets))
+	for _, bucket := range f.buckets {
+		buf = append(buf, bucket[:]...)
+	}
+	return buf, nil
+}
+
+func (f *CuckooFilter) Decode(buf []byte) error {
+	if len(buf) < 4 {
+		return fmt.Errorf("index: cuckoo filter buffer too short: %d bytes", len(buf))
+	}
+	numBuckets := int(binary.LittleEndian.Uint32(buf[0:4]))
+	want := 4 + numBuckets*cuckooBucketSize
+	if len(buf) < want {
+		return fmt.Errorf("index: cuckoo filter buffer truncated: want %d bytes, have %d", want, len(buf))
+	}
+	f.numBuckets = numBuckets
+	f.buckets = make([][cuckooBucketSize]byte, numBuckets)
+	f.count = 0
+	off := 4
+	for i := range f.buckets {
+		copy(f.buckets[i][:], buf[off:off+cuckooBucketSize])
+		for _, v := range f.buckets[i] {
+			if v != 0 {
+				f.count++
+			}
+		}
+		off += cuckooBucketSize
+	}
+	if f.rng == nil {
+		f.rng = rand.New(rand.NewSource(1))
+	}
+	return nil
+}
+
+func nextPowerOfTwo(n int) int {
+	p := 1
+	for p < n {
+		p <<= 1
+	}
+	return p
+}
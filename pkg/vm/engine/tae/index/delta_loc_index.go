@@ -0,0 +1,138 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package index
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/matrixorigin/matrixone/pkg/container/types"
+)
+
+// DeltaRowRange is the slice of a delta object's rows one UpdateDeltaLoc
+// call flushed, e.g. rows [Start, End) of the object BlockWriter wrote.
+type DeltaRowRange struct {
+	Start uint32
+	End   uint32
+}
+
+// DeltaLocEntry is one flushed delta object a BlockEntry's DeltaLocIndex
+// knows about: the [MinTS, MaxTS] range of commit timestamps its rows
+// cover, where to find it (Loc, left opaque here - BlockEntry passes
+// through whatever objectio.Location it already uses), and which rows
+// within it are the relevant slice.
+type DeltaLocEntry struct {
+	MinTS types.TS
+	MaxTS types.TS
+	Loc   string
+	Rows  DeltaRowRange
+}
+
+// DeltaLocIndex is a per-BlockEntry, in-memory sorted index from a delta
+// object's commit-ts range to its location, populated by UpdateDeltaLoc
+// and consulted by CollectDeleteInRange so a time-range query only opens
+// the delta objects that can possibly contain a matching row instead of
+// rescanning every delta object GCInMemeoryDeletesByTS has ever retired
+// in-memory state for. It doesn't know how to serialize a types.TS or a
+// Loc to disk: the catalog checkpoint writer already has code for that,
+// so persisting an index is just persisting Entries() and reconstructing
+// it with NewDeltaLocIndexFromEntries.
+type DeltaLocIndex struct {
+	mu      sync.RWMutex
+	entries []DeltaLocEntry // sorted ascending by MinTS, non-overlapping
+}
+
+// NewDeltaLocIndex returns an empty DeltaLocIndex.
+func NewDeltaLocIndex() *DeltaLocIndex {
+	return &DeltaLocIndex{}
+}
+
+// NewDeltaLocIndexFromEntries rebuilds a DeltaLocIndex from entries
+// already known to be sorted and non-overlapping - either because the
+// catalog checkpoint stored them that way, or because RebuildFromFooters
+// produced them from the delta objects' own footers on restart.
+func NewDeltaLocIndexFromEntries(entries []DeltaLocEntry) *DeltaLocIndex {
+	return &DeltaLocIndex{entries: append([]DeltaLocEntry(nil), entries...)}
+}
+
+// Add records that a flush just produced a new delta object covering
+// [minTS, maxTS]. UpdateDeltaLoc calls commit in increasing order, so Add
+// rejects a minTS that doesn't strictly follow every previously recorded
+// object's maxTS rather than silently breaking the index's sort/overlap
+// invariant.
+func (idx *DeltaLocIndex) Add(minTS, maxTS types.TS, loc string, rows DeltaRowRange) error {
+	idx.mu.Lock()
+	defer idx.mu.Unlock()
+	if n := len(idx.entries); n > 0 && !minTS.Greater(idx.entries[n-1].MaxTS) {
+		return fmt.Errorf("index: delta object %s committed out of order: minTS %v does not follow prior maxTS %v", loc, minTS, idx.entries[n-1].MaxTS)
+	}
+	idx.entries = append(idx.entries, DeltaLocEntry{MinTS: minTS, MaxTS: maxTS, Loc: loc, Rows: rows})
+	return nil
+}
+
+// Lookup binary-searches for the delta objects whose [MinTS, MaxTS] range
+// overlaps [from, to], returning them in the same ascending order they
+// were recorded in. Objects entirely before from or entirely after to are
+// never opened by CollectDeleteInRange.
+func (idx *DeltaLocIndex) Lookup(from, to types.TS) []DeltaLocEntry {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+
+	start := sort.Search(len(idx.entries), func(i int) bool {
+		return !idx.entries[i].MaxTS.Less(from)
+	})
+
+	var out []DeltaLocEntry
+	for i := start; i < len(idx.entries) && !idx.entries[i].MinTS.Greater(to); i++ {
+		out = append(out, idx.entries[i])
+	}
+	return out
+}
+
+// Entries returns every recorded DeltaLocEntry in ascending MinTS order,
+// for the catalog checkpoint writer to persist.
+func (idx *DeltaLocIndex) Entries() []DeltaLocEntry {
+	idx.mu.RLock()
+	defer idx.mu.RUnlock()
+	return append([]DeltaLocEntry(nil), idx.entries...)
+}
+
+// ObjectFooter is the per-object summary RebuildFromFooters reads on
+// restart: every delta object's footer already carries the min/max commit
+// ts of the rows it holds, so the index can be rebuilt without replaying
+// the WAL writes that originally produced it.
+type ObjectFooter struct {
+	Loc   string
+	MinTS types.TS
+	MaxTS types.TS
+	Rows  DeltaRowRange
+}
+
+// RebuildFromFooters reconstructs a BlockEntry's DeltaLocIndex from its
+// delta objects' footers, sorting them by MinTS first since object
+// directory listings make no ordering guarantee.
+func RebuildFromFooters(footers []ObjectFooter) (*DeltaLocIndex, error) {
+	sorted := append([]ObjectFooter(nil), footers...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].MinTS.Less(sorted[j].MinTS) })
+
+	idx := NewDeltaLocIndex()
+	for _, f := range sorted {
+		if err := idx.Add(f.MinTS, f.MaxTS, f.Loc, f.Rows); err != nil {
+			return nil, err
+		}
+	}
+	return idx, nil
+}
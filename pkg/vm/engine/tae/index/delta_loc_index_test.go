@@ -0,0 +1,94 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package index
+
+import (
+	"testing"
+
+	"github.com/matrixorigin/matrixone/pkg/container/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func ts(physical int64) types.TS {
+	return types.BuildTS(physical, 0)
+}
+
+func buildIndex(t *testing.T) *DeltaLocIndex {
+	idx := NewDeltaLocIndex()
+	require.NoError(t, idx.Add(ts(0), ts(10), "obj-0", DeltaRowRange{Start: 0, End: 8}))
+	require.NoError(t, idx.Add(ts(11), ts(20), "obj-1", DeltaRowRange{Start: 0, End: 8}))
+	require.NoError(t, idx.Add(ts(21), ts(30), "obj-2", DeltaRowRange{Start: 0, End: 8}))
+	return idx
+}
+
+func TestDeltaLocIndexAddRejectsOutOfOrder(t *testing.T) {
+	idx := NewDeltaLocIndex()
+	require.NoError(t, idx.Add(ts(10), ts(20), "obj-0", DeltaRowRange{}))
+	assert.Error(t, idx.Add(ts(15), ts(25), "obj-1", DeltaRowRange{}))
+}
+
+func TestDeltaLocIndexLookupReturnsOnlyOverlappingObjects(t *testing.T) {
+	idx := buildIndex(t)
+
+	got := idx.Lookup(ts(12), ts(19))
+	require.Len(t, got, 1)
+	assert.Equal(t, "obj-1", got[0].Loc)
+
+	got = idx.Lookup(ts(5), ts(15))
+	require.Len(t, got, 2)
+	assert.Equal(t, "obj-0", got[0].Loc)
+	assert.Equal(t, "obj-1", got[1].Loc)
+
+	got = idx.Lookup(ts(100), ts(200))
+	assert.Empty(t, got)
+}
+
+func TestDeltaLocIndexLookupIsInclusiveAtBoundaries(t *testing.T) {
+	idx := buildIndex(t)
+	got := idx.Lookup(ts(10), ts(11))
+	require.Len(t, got, 2)
+	assert.Equal(t, "obj-0", got[0].Loc)
+	assert.Equal(t, "obj-1", got[1].Loc)
+}
+
+func TestDeltaLocIndexEntriesRoundTripThroughFromEntries(t *testing.T) {
+	idx := buildIndex(t)
+	rebuilt := NewDeltaLocIndexFromEntries(idx.Entries())
+	assert.Equal(t, idx.Entries(), rebuilt.Entries())
+}
+
+func TestRebuildFromFootersSortsAndMatchesLookup(t *testing.T) {
+	footers := []ObjectFooter{
+		{Loc: "obj-2", MinTS: ts(21), MaxTS: ts(30), Rows: DeltaRowRange{Start: 0, End: 8}},
+		{Loc: "obj-0", MinTS: ts(0), MaxTS: ts(10), Rows: DeltaRowRange{Start: 0, End: 8}},
+		{Loc: "obj-1", MinTS: ts(11), MaxTS: ts(20), Rows: DeltaRowRange{Start: 0, End: 8}},
+	}
+	idx, err := RebuildFromFooters(footers)
+	require.NoError(t, err)
+
+	got := idx.Lookup(ts(12), ts(19))
+	require.Len(t, got, 1)
+	assert.Equal(t, "obj-1", got[0].Loc)
+}
+
+func TestRebuildFromFootersRejectsOverlappingObjects(t *testing.T) {
+	footers := []ObjectFooter{
+		{Loc: "obj-0", MinTS: ts(0), MaxTS: ts(15)},
+		{Loc: "obj-1", MinTS: ts(10), MaxTS: ts(20)},
+	}
+	_, err := RebuildFromFooters(footers)
+	assert.Error(t, err)
+}
@@ -0,0 +1,75 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package index
+
+// Predicate is a scalar predicate FilterBulkDelete evaluates against a
+// column's persisted values, generic over the column's Go type so it
+// works the same way for an int64 PK column as for a varchar one. It is
+// deliberately just two funcs rather than a plan.Expr walker: the
+// expression-to-Predicate compile step (vectorized.BuildScalarPredicate
+// or equivalent) is left to the caller, the same way DeltaLocIndex leaves
+// serializing a types.TS to whoever already knows how.
+type Predicate[T any] struct {
+	// MatchRange reports whether some value in [min, max] (inclusive)
+	// could satisfy the predicate. It must be conservative - a false
+	// negative here would skip a block that actually has a match - so
+	// FilterBulkDelete uses it only to decide whether a block's zonemap
+	// rules the whole block out, never to decide a row matches.
+	MatchRange func(min, max T) bool
+	// MatchValue reports whether exactly x satisfies the predicate.
+	MatchValue func(x T) bool
+}
+
+// BlockValues is the per-block persisted data FilterBulkDelete consults:
+// a zonemap summary cheap enough to check before opening the block, and
+// the column's decoded values plus bloom membership once it has to.
+type BlockValues[T any] struct {
+	BlockID uint64
+	ZoneMin T
+	ZoneMax T
+	Values  []T
+	InBloom func(x T) bool // optional; nil skips the bloom pre-check
+}
+
+// FilterBulkDelete evaluates pred against each block's zonemap first,
+// skipping any block pred.MatchRange rules out entirely, then scans the
+// surviving blocks' persisted values row by row. It returns the matching
+// row offsets grouped by BlockID, ready for a caller (TryDeleteByFilterDeltaloc)
+// to pack into one tombstone.Writer per block without ever copying a
+// matched row out of the block's own data into the in-memory delete
+// chain - scanning a block's Values only happens once per DELETE, not
+// once per candidate PK value the way an []any-driven
+// TryDeleteByDeltaloc call requires.
+func FilterBulkDelete[T any](pred Predicate[T], blocks []BlockValues[T]) map[uint64][]uint32 {
+	matches := make(map[uint64][]uint32)
+	for _, blk := range blocks {
+		if !pred.MatchRange(blk.ZoneMin, blk.ZoneMax) {
+			continue
+		}
+		var rows []uint32
+		for i, v := range blk.Values {
+			if blk.InBloom != nil && !blk.InBloom(v) {
+				continue
+			}
+			if pred.MatchValue(v) {
+				rows = append(rows, uint32(i))
+			}
+		}
+		if len(rows) > 0 {
+			matches[blk.BlockID] = rows
+		}
+	}
+	return matches
+}
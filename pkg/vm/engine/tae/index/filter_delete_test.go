@@ -0,0 +1,60 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package index
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func gtPredicate(threshold int64) Predicate[int64] {
+	return Predicate[int64]{
+		MatchRange: func(min, max int64) bool { return max > threshold },
+		MatchValue: func(x int64) bool { return x > threshold },
+	}
+}
+
+func TestFilterBulkDeleteSkipsBlocksOutsideZoneMap(t *testing.T) {
+	blocks := []BlockValues[int64]{
+		{BlockID: 1, ZoneMin: 0, ZoneMax: 5, Values: []int64{0, 5}},
+		{BlockID: 2, ZoneMin: 8, ZoneMax: 20, Values: []int64{8, 15, 20}},
+	}
+
+	matches := FilterBulkDelete(gtPredicate(10), blocks)
+	assert.NotContains(t, matches, uint64(1))
+	assert.Equal(t, []uint32{1, 2}, matches[2])
+}
+
+func TestFilterBulkDeleteHonorsBloomPreCheck(t *testing.T) {
+	blocks := []BlockValues[int64]{
+		{
+			BlockID: 1,
+			ZoneMin: 0, ZoneMax: 10,
+			Values:  []int64{1, 2, 3},
+			InBloom: func(x int64) bool { return x != 2 },
+		},
+	}
+	matches := FilterBulkDelete(gtPredicate(0), blocks)
+	assert.Equal(t, []uint32{0, 2}, matches[1])
+}
+
+func TestFilterBulkDeleteReturnsNoEntryWhenNothingMatches(t *testing.T) {
+	blocks := []BlockValues[int64]{
+		{BlockID: 1, ZoneMin: 0, ZoneMax: 5, Values: []int64{1, 2, 3}},
+	}
+	matches := FilterBulkDelete(gtPredicate(100), blocks)
+	assert.Empty(t, matches)
+}
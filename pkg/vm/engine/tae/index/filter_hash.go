@@ -0,0 +1,29 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package index
+
+// hashBytes is a small, seedable 64-bit hash (FNV-1a with the seed mixed
+// into the offset basis) shared by the ribbon and cuckoo filter
+// backends. Neither backend needs cryptographic strength, just cheap,
+// well-distributed, reseedable hashing.
+func hashBytes(data []byte, seed uint64) uint64 {
+	const prime64 = 1099511628211
+	h := 14695981039346656037 ^ seed
+	for _, b := range data {
+		h ^= uint64(b)
+		h *= prime64
+	}
+	return h
+}
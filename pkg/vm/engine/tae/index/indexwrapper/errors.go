@@ -0,0 +1,98 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexwrapper
+
+import (
+	"fmt"
+
+	"github.com/RoaringBitmap/roaring"
+)
+
+// DedupState classifies the outcome of a dedup lookup against a single
+// ImmutIndex, replacing the old convention of returning nil for "not
+// found" and moerr.GetOkExpectedPossibleDup() for "possibly a dup".
+type DedupState uint8
+
+const (
+	// DedupUnknown is the zero value; a DedupResult carries it only when
+	// the lookup itself failed, with Cause set.
+	DedupUnknown DedupState = iota
+	// DedupDefinitelyNot means none of the probed keys exist in this
+	// object: the zone map range excluded them, or the bloom filter
+	// rejected all of them.
+	DedupDefinitelyNot
+	// DedupPossiblyDuplicate means the bloom filter reported a possible
+	// match for at least one key; the caller must still check the real
+	// data to confirm.
+	DedupPossiblyDuplicate
+)
+
+func (s DedupState) String() string {
+	switch s {
+	case DedupDefinitelyNot:
+		return "definitely-not"
+	case DedupPossiblyDuplicate:
+		return "possibly-duplicate"
+	default:
+		return "unknown"
+	}
+}
+
+// DedupResult is the typed replacement for the (*roaring.Bitmap, error)
+// sentinel pair BatchDedup/Dedup used to return. Cause is only set when
+// State is DedupUnknown, i.e. the lookup itself failed (I/O or decode
+// error) rather than having produced an answer.
+type DedupResult struct {
+	State DedupState
+	Sels  *roaring.Bitmap
+	Cause error
+}
+
+// ErrBloomLoadFailed wraps a failure to load a bloom filter's backing
+// buffer through blockio.LoadBF or blockio.LoadBFBatch; reading the
+// inline idx.bf does not fail.
+type ErrBloomLoadFailed struct {
+	Location string
+	Cause    error
+}
+
+func (e *ErrBloomLoadFailed) Error() string {
+	return fmt.Sprintf("indexwrapper: load bloom filter for %s: %v", e.Location, e.Cause)
+}
+
+func (e *ErrBloomLoadFailed) Unwrap() error { return e.Cause }
+
+// ErrBloomDecodeFailed wraps a failure to decode a loaded bloom filter
+// buffer into a usable index.MembershipFilter.
+type ErrBloomDecodeFailed struct {
+	Location string
+	Cause    error
+}
+
+func (e *ErrBloomDecodeFailed) Error() string {
+	return fmt.Sprintf("indexwrapper: decode bloom filter for %s: %v", e.Location, e.Cause)
+}
+
+func (e *ErrBloomDecodeFailed) Unwrap() error { return e.Cause }
+
+// ErrZMMismatch reports that the zone map attached to ImmutIndex cannot
+// be compared against the probe keys' zone map, e.g. differing types.
+type ErrZMMismatch struct {
+	Location string
+}
+
+func (e *ErrZMMismatch) Error() string {
+	return fmt.Sprintf("indexwrapper: zone map type mismatch for %s", e.Location)
+}
@@ -34,8 +34,34 @@ type ImmutIndex struct {
 	location objectio.Location
 	cache    model.LRUCache
 	fs       fileservice.FileService
+	// bfCache, when set, caches decoded index.MembershipFilters keyed by
+	// bfCacheKey (object name plus block ID) so repeated dedup calls
+	// against the same object under pressure skip both blockio.LoadBF and
+	// the filter's own Decode.
+	bfCache *model.ARCCache[bfCacheKey, index.MembershipFilter]
 }
 
+// bfCacheKey scopes a cached index.MembershipFilter to the object it was
+// decoded from, not just the block ID within it: block IDs repeat across
+// objects, so keying on blockID alone would let one object's decoded
+// filter serve a dedup lookup against a different object sharing that
+// block ID.
+type bfCacheKey struct {
+	object  string
+	blockID uint32
+}
+
+// defaultBFCacheCapacity bounds the bfCache NewImmutIndex attaches by
+// default: enough decoded filters resident to cover a dedup-heavy
+// ingestion batch touching many blocks without unbounded growth.
+const defaultBFCacheCapacity = 1024
+
+// defaultBFCache is shared by every ImmutIndex NewImmutIndex builds that
+// isn't given an explicit one via WithBFCache, so the fast path it
+// serves (see loadBFIndex) is live without every call site having to opt
+// in individually.
+var defaultBFCache = model.NewARCCache[bfCacheKey, index.MembershipFilter](defaultBFCacheCapacity)
+
 func NewImmutIndex(
 	zm index.ZM,
 	bf objectio.BloomFilter,
@@ -49,103 +75,165 @@ func NewImmutIndex(
 		location: location,
 		cache:    cache,
 		fs:       fs,
+		bfCache:  defaultBFCache,
 	}
 }
 
-func (idx ImmutIndex) BatchDedup(
+// WithBFCache attaches an ARC cache of decoded bloom filters to idx,
+// overriding the default cache NewImmutIndex already populated it with.
+// Passing the same *model.ARCCache to every ImmutIndex built for one
+// dedup batch (see ImmutIndexSet) lets later blocks in the batch reuse
+// filters earlier blocks already decoded.
+func (idx ImmutIndex) WithBFCache(bfCache *model.ARCCache[bfCacheKey, index.MembershipFilter]) ImmutIndex {
+	idx.bfCache = bfCache
+	return idx
+}
+
+// loadBFIndex returns the decoded membership filter for idx.location,
+// serving it from bfCache when present and populating bfCache on miss.
+// The filter backend (binary fuse, ribbon, cuckoo, ...) is whatever the
+// object was written with: decodeFilter negotiates it from the leading
+// tag byte, so ImmutIndex never hardcodes a single backend.
+func (idx ImmutIndex) loadBFIndex(ctx context.Context) (index.MembershipFilter, error) {
+	blockID := uint32(idx.location.ID())
+	return idx.cachedBFIndex(blockID, func() ([]byte, error) {
+		if len(idx.bf) > 0 {
+			return idx.bf.GetBloomFilter(blockID), nil
+		}
+		bf, err := blockio.LoadBF(ctx, idx.location, idx.cache, idx.fs, false)
+		if err != nil {
+			return nil, &ErrBloomLoadFailed{Location: idx.location.String(), Cause: err}
+		}
+		return bf.GetBloomFilter(blockID), nil
+	})
+}
+
+// cachedBFIndex serves idx's decoded membership filter for blockID out of
+// bfCache when present, falling back to loadBuf to fetch the raw filter
+// bytes on a miss - the shared path loadBFIndex and ImmutIndexSet.dedupGroup
+// both go through, so a cache hit in one serves the other.
+func (idx ImmutIndex) cachedBFIndex(blockID uint32, loadBuf func() ([]byte, error)) (index.MembershipFilter, error) {
+	key := bfCacheKey{object: idx.location.Name().String(), blockID: blockID}
+	if idx.bfCache != nil {
+		if bfIndex, ok := idx.bfCache.Get(key); ok {
+			return bfIndex, nil
+		}
+	}
+
+	buf, err := loadBuf()
+	if err != nil {
+		return nil, err
+	}
+	bfIndex, err := decodeFilter(buf)
+	if err != nil {
+		return nil, &ErrBloomDecodeFailed{Location: idx.location.String(), Cause: err}
+	}
+	if idx.bfCache != nil {
+		idx.bfCache.Set(key, bfIndex)
+	}
+	return bfIndex, nil
+}
+
+// decodeFilter picks the MembershipFilter backend tagged on buf, falling
+// back to the original untagged binary fuse format for objects written
+// before the filter registry existed.
+func decodeFilter(buf []byte) (index.MembershipFilter, error) {
+	if f, err := index.DecodeTaggedFilter(buf); err == nil {
+		return f, nil
+	}
+	bfIndex, _ := index.NewMembershipFilter(index.FilterBinaryFuse)
+	if err := bfIndex.Decode(buf); err != nil {
+		return nil, err
+	}
+	return bfIndex, nil
+}
+
+// BatchDedupResult is the typed replacement for BatchDedup: it reports
+// DedupDefinitelyNot/DedupPossiblyDuplicate via DedupResult.State instead
+// of overloading the error return, and only populates Cause when the
+// lookup itself failed.
+func (idx ImmutIndex) BatchDedupResult(
 	ctx context.Context,
 	keys containers.Vector,
 	keysZM index.ZM,
-) (sels *roaring.Bitmap, err error) {
+) DedupResult {
 	var exist bool
 	if keysZM.Valid() {
+		if keysZM.GetType() != idx.zm.GetType() {
+			return DedupResult{Cause: &ErrZMMismatch{Location: idx.location.String()}}
+		}
 		if exist = idx.zm.FastIntersect(keysZM); !exist {
-			// all keys are not in [min, max]. definitely not
-			return
+			return DedupResult{State: DedupDefinitelyNot}
 		}
 	} else {
 		if exist = idx.zm.FastContainsAny(keys); !exist {
-			// all keys are not in [min, max]. definitely not
-			return
+			return DedupResult{State: DedupDefinitelyNot}
 		}
 	}
 
 	// some keys are in [min, max]. check bloomfilter for those keys
 
-	var buf []byte
-	if len(idx.bf) > 0 {
-		buf = idx.bf.GetBloomFilter(uint32(idx.location.ID()))
-	} else {
-		var bf objectio.BloomFilter
-		if bf, err = blockio.LoadBF(
-			ctx,
-			idx.location,
-			idx.cache,
-			idx.fs,
-			false,
-		); err != nil {
-			return
-		}
-		buf = bf.GetBloomFilter(uint32(idx.location.ID()))
+	bfIndex, err := idx.loadBFIndex(ctx)
+	if err != nil {
+		return DedupResult{Cause: err}
 	}
 
-	bfIndex := index.NewEmptyBinaryFuseFilter()
-	if err = index.DecodeBloomFilter(bfIndex, buf); err != nil {
-		return
+	exist, sels, err := bfIndex.MayContainsAnyKeys(keys)
+	if err != nil {
+		return DedupResult{Cause: TranslateError(err)}
 	}
-
-	if exist, sels, err = bfIndex.MayContainsAnyKeys(keys); err != nil {
-		// check bloomfilter has some unknown error. return err
-		err = TranslateError(err)
-		return
-	} else if !exist {
-		// all keys were checked. definitely not
-		return
+	if !exist {
+		return DedupResult{State: DedupDefinitelyNot}
 	}
-
-	err = moerr.GetOkExpectedPossibleDup()
-	return
+	return DedupResult{State: DedupPossiblyDuplicate, Sels: sels}
 }
 
-func (idx ImmutIndex) Dedup(ctx context.Context, key any) (err error) {
-	exist := idx.zm.Contains(key)
+// DedupResult is the typed replacement for Dedup: see BatchDedupResult.
+func (idx ImmutIndex) DedupResult(ctx context.Context, key any) DedupResult {
 	// 1. if not in [min, max], key is definitely not found
-	if !exist {
-		return
-	}
-	var buf []byte
-	if len(idx.bf) > 0 {
-		buf = idx.bf.GetBloomFilter(uint32(idx.location.ID()))
-	} else {
-		var bf objectio.BloomFilter
-		if bf, err = blockio.LoadBF(
-			ctx,
-			idx.location,
-			idx.cache,
-			idx.fs,
-			false,
-		); err != nil {
-			return
-		}
-		buf = bf.GetBloomFilter(uint32(idx.location.ID()))
+	if !idx.zm.Contains(key) {
+		return DedupResult{State: DedupDefinitelyNot}
 	}
-
-	bfIndex := index.NewEmptyBinaryFuseFilter()
-	if err = index.DecodeBloomFilter(bfIndex, buf); err != nil {
-		return
+	bfIndex, err := idx.loadBFIndex(ctx)
+	if err != nil {
+		return DedupResult{Cause: err}
 	}
 
 	v := types.EncodeValue(key, idx.zm.GetType())
-	exist, err = bfIndex.MayContainsKey(v)
-	// 2. check bloomfilter has some error. return err
+	exist, err := bfIndex.MayContainsKey(v)
 	if err != nil {
-		err = TranslateError(err)
-		return
+		return DedupResult{Cause: TranslateError(err)}
 	}
-	// 3. all keys were checked. definitely not
 	if !exist {
-		return
+		return DedupResult{State: DedupDefinitelyNot}
+	}
+	return DedupResult{State: DedupPossiblyDuplicate}
+}
+
+// BatchDedup is kept for callers not yet migrated to BatchDedupResult: it
+// maps DedupPossiblyDuplicate back onto the legacy
+// moerr.GetOkExpectedPossibleDup() sentinel.
+//
+// Deprecated: use BatchDedupResult.
+func (idx ImmutIndex) BatchDedup(
+	ctx context.Context,
+	keys containers.Vector,
+	keysZM index.ZM,
+) (sels *roaring.Bitmap, err error) {
+	res := idx.BatchDedupResult(ctx, keys, keysZM)
+	if res.State == DedupPossiblyDuplicate {
+		return res.Sels, moerr.GetOkExpectedPossibleDup()
 	}
-	err = moerr.GetOkExpectedPossibleDup()
-	return
-}
\ No newline at end of file
+	return nil, res.Cause
+}
+
+// Dedup is kept for callers not yet migrated to DedupResult.
+//
+// Deprecated: use DedupResult.
+func (idx ImmutIndex) Dedup(ctx context.Context, key any) (err error) {
+	res := idx.DedupResult(ctx, key)
+	if res.State == DedupPossiblyDuplicate {
+		return moerr.GetOkExpectedPossibleDup()
+	}
+	return res.Cause
+}
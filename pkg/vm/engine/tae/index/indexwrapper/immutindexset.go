@@ -0,0 +1,228 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package indexwrapper
+
+import (
+	"context"
+	"sync"
+
+	"github.com/RoaringBitmap/roaring"
+	"github.com/matrixorigin/matrixone/pkg/container/types"
+	"github.com/matrixorigin/matrixone/pkg/objectio"
+	"github.com/matrixorigin/matrixone/pkg/vm/engine/tae/blockio"
+	"github.com/matrixorigin/matrixone/pkg/vm/engine/tae/containers"
+	"github.com/matrixorigin/matrixone/pkg/vm/engine/tae/index"
+	"github.com/panjf2000/ants/v2"
+)
+
+// immutIndexSetPoolSize bounds how many objects' bloom filters
+// ImmutIndexSet.BatchDedupAll decodes and probes concurrently.
+const immutIndexSetPoolSize = 8
+
+// ImmutIndexSet batches BatchDedup across every block a transaction must
+// check a single insert against. Calling ImmutIndex.BatchDedup block by
+// block means one fileservice read per block even when several blocks
+// live in the same object; ImmutIndexSet instead groups blocks by
+// object, issues one blockio.LoadBFBatch per object, and reuses a single
+// pre-encoded key buffer across every block it probes.
+type ImmutIndexSet struct {
+	indexes []ImmutIndex
+}
+
+// NewImmutIndexSet collects the ImmutIndexes a single dedup call must
+// check against, typically one per candidate block of a table.
+func NewImmutIndexSet(indexes ...ImmutIndex) *ImmutIndexSet {
+	return &ImmutIndexSet{indexes: indexes}
+}
+
+// BatchDedupAll checks keys against every block in the set and returns
+// the selection bitmap for each block that may contain a duplicate.
+// Blocks whose zone map excludes keys outright, and blocks whose bloom
+// filter rejects every key, are simply absent from perBlockSels.
+func (s *ImmutIndexSet) BatchDedupAll(
+	ctx context.Context,
+	keys containers.Vector,
+	keysZM index.ZM,
+) (perBlockSels map[objectio.Location]*roaring.Bitmap, err error) {
+	// (1) filter candidate blocks by zone map alone, no I/O yet
+	candidates := make([]ImmutIndex, 0, len(s.indexes))
+	for _, idx := range s.indexes {
+		var exist bool
+		if keysZM.Valid() {
+			exist = idx.zm.FastIntersect(keysZM)
+		} else {
+			exist = idx.zm.FastContainsAny(keys)
+		}
+		if exist {
+			candidates = append(candidates, idx)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	// encode every key once; every candidate block probes these bytes
+	// directly instead of re-running types.EncodeValue per block.
+	encoded := encodeKeys(keys)
+
+	// (2) group the surviving blocks by their backing object so each
+	// object is fetched with a single coalesced LoadBFBatch call.
+	groups := make(map[string][]ImmutIndex)
+	var order []string
+	for _, idx := range candidates {
+		name := idx.location.Name().String()
+		if _, ok := groups[name]; !ok {
+			order = append(order, name)
+		}
+		groups[name] = append(groups[name], idx)
+	}
+
+	pool, perr := ants.NewPool(immutIndexSetPoolSize)
+	if perr != nil {
+		return nil, perr
+	}
+	defer pool.Release()
+
+	var (
+		mu       sync.Mutex
+		wg       sync.WaitGroup
+		firstErr error
+	)
+	perBlockSels = make(map[objectio.Location]*roaring.Bitmap)
+
+	for _, name := range order {
+		group := groups[name]
+		wg.Add(1)
+		task := func() {
+			defer wg.Done()
+			sels, gerr := s.dedupGroup(ctx, group, encoded)
+			mu.Lock()
+			defer mu.Unlock()
+			if gerr != nil {
+				if firstErr == nil {
+					firstErr = gerr
+				}
+				return
+			}
+			for loc, sel := range sels {
+				perBlockSels[loc] = sel
+			}
+		}
+		if serr := pool.Submit(task); serr != nil {
+			wg.Done()
+			mu.Lock()
+			if firstErr == nil {
+				firstErr = serr
+			}
+			mu.Unlock()
+		}
+	}
+	wg.Wait()
+
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	if len(perBlockSels) == 0 {
+		return nil, nil
+	}
+	return perBlockSels, nil
+}
+
+// dedupGroup loads every block of one object with a single
+// blockio.LoadBFBatch call, then decodes and probes each block's filter
+// in turn. (3) decoding still happens per block that misses bfCache,
+// since each block has its own filter payload; a block whose filter a
+// prior call in this batch (or an earlier one sharing the same
+// *model.ARCCache, see WithBFCache) already decoded skips both the
+// batch-loaded bytes and decodeFilter entirely.
+func (s *ImmutIndexSet) dedupGroup(
+	ctx context.Context,
+	group []ImmutIndex,
+	encoded [][]byte,
+) (map[objectio.Location]*roaring.Bitmap, error) {
+	locations := make([]objectio.Location, len(group))
+	for i, idx := range group {
+		locations[i] = idx.location
+	}
+
+	var bfs []objectio.BloomFilter
+	loaded := false
+
+	out := make(map[objectio.Location]*roaring.Bitmap)
+	for i, idx := range group {
+		blockID := uint32(idx.location.ID())
+		bfIndex, derr := idx.cachedBFIndex(blockID, func() ([]byte, error) {
+			if len(idx.bf) > 0 {
+				return idx.bf.GetBloomFilter(blockID), nil
+			}
+			if !loaded {
+				var err error
+				bfs, err = blockio.LoadBFBatch(ctx, locations, group[0].cache, group[0].fs, false)
+				if err != nil {
+					return nil, &ErrBloomLoadFailed{Location: group[0].location.Name().String(), Cause: err}
+				}
+				loaded = true
+			}
+			return bfs[i].GetBloomFilter(blockID), nil
+		})
+		if derr != nil {
+			return nil, derr
+		}
+		sels, perr := probeEncodedKeys(bfIndex, encoded)
+		if perr != nil {
+			return nil, TranslateError(perr)
+		}
+		if sels != nil {
+			out[idx.location] = sels
+		}
+	}
+	return out, nil
+}
+
+// encodeKeys runs types.EncodeValue over keys exactly once so every
+// candidate block's probe reuses the same byte slices.
+func encodeKeys(keys containers.Vector) [][]byte {
+	typ := keys.GetType()
+	encoded := make([][]byte, keys.Length())
+	keys.Foreach(func(v any, isNull bool, row int) error {
+		if !isNull {
+			encoded[row] = types.EncodeValue(v, typ)
+		}
+		return nil
+	}, nil)
+	return encoded
+}
+
+// probeEncodedKeys checks every pre-encoded key against f, returning nil
+// (not an empty bitmap) when none of them may be present.
+func probeEncodedKeys(f index.MembershipFilter, encoded [][]byte) (*roaring.Bitmap, error) {
+	var sels *roaring.Bitmap
+	for row, buf := range encoded {
+		if buf == nil {
+			continue
+		}
+		ok, err := f.MayContainsKey(buf)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			if sels == nil {
+				sels = roaring.New()
+			}
+			sels.Add(uint64(row))
+		}
+	}
+	return sels, nil
+}
@@ -0,0 +1,116 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package index
+
+import (
+	"fmt"
+
+	"github.com/RoaringBitmap/roaring"
+	"github.com/matrixorigin/matrixone/pkg/vm/engine/tae/containers"
+)
+
+// FilterKind is the 1-byte tag persisted immediately before a filter's
+// encoded payload in the object footer's bloom filter metadata. It lets
+// a reader pick the right MembershipFilter implementation without any
+// other side channel, so new filter kinds can be added without breaking
+// objects written with an older one.
+type FilterKind uint8
+
+const (
+	// FilterBinaryFuse is the original, still-default backend: an
+	// immutable binary fuse filter built from index.NewEmptyBinaryFuseFilter.
+	FilterBinaryFuse FilterKind = iota
+	// FilterRibbon is a banded GF(2) linear-system filter: smaller than
+	// a binary fuse filter at the same false-positive rate, at the cost
+	// of a (still linear-time) construction pass. Good for bulk,
+	// never-mutated objects.
+	FilterRibbon
+	// FilterCuckoo supports removing a previously inserted key, unlike
+	// the other two backends. Meant for secondary indexes where a delete
+	// must be reflected in the filter itself.
+	FilterCuckoo
+)
+
+func (k FilterKind) String() string {
+	switch k {
+	case FilterBinaryFuse:
+		return "binary-fuse"
+	case FilterRibbon:
+		return "ribbon"
+	case FilterCuckoo:
+		return "cuckoo"
+	default:
+		return fmt.Sprintf("unknown(%d)", uint8(k))
+	}
+}
+
+// MembershipFilter is the common approximate-membership-query surface
+// every filter backend implements. Encode/Decode round-trip a filter
+// through the bytes stored in an object's bloom filter metadata.
+type MembershipFilter interface {
+	MayContainsKey(key []byte) (bool, error)
+	MayContainsAnyKeys(keys containers.Vector) (bool, *roaring.Bitmap, error)
+	Encode() ([]byte, error)
+	Decode(buf []byte) error
+}
+
+type filterFactory func() MembershipFilter
+
+var filterRegistry = map[FilterKind]filterFactory{}
+
+// RegisterFilter makes a MembershipFilter backend available under tag
+// kind. Backends register themselves from an init() in their own file.
+func RegisterFilter(kind FilterKind, factory filterFactory) {
+	filterRegistry[kind] = factory
+}
+
+// NewMembershipFilter builds an empty, undecoded filter for kind.
+func NewMembershipFilter(kind FilterKind) (MembershipFilter, bool) {
+	factory, ok := filterRegistry[kind]
+	if !ok {
+		return nil, false
+	}
+	return factory(), true
+}
+
+// EncodeTaggedFilter prefixes f's encoded bytes with its FilterKind tag
+// so DecodeTaggedFilter can later pick the matching backend.
+func EncodeTaggedFilter(kind FilterKind, f MembershipFilter) ([]byte, error) {
+	body, err := f.Encode()
+	if err != nil {
+		return nil, err
+	}
+	out := make([]byte, 0, len(body)+1)
+	out = append(out, byte(kind))
+	out = append(out, body...)
+	return out, nil
+}
+
+// DecodeTaggedFilter reads the leading FilterKind tag off buf and
+// decodes the remainder with the matching registered backend.
+func DecodeTaggedFilter(buf []byte) (MembershipFilter, error) {
+	if len(buf) == 0 {
+		return nil, fmt.Errorf("index: empty tagged filter buffer")
+	}
+	kind := FilterKind(buf[0])
+	f, ok := NewMembershipFilter(kind)
+	if !ok {
+		return nil, fmt.Errorf("index: unregistered filter kind %s", kind)
+	}
+	if err := f.Decode(buf[1:]); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
@@ -0,0 +1,56 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package index
+
+import (
+	"fmt"
+
+	"github.com/RoaringBitmap/roaring"
+	"github.com/matrixorigin/matrixone/pkg/vm/engine/tae/containers"
+)
+
+// binaryFuseAdapter makes the pre-existing BinaryFuseFilter satisfy
+// MembershipFilter so it can keep being the default entry in the filter
+// registry instead of being special-cased by callers.
+type binaryFuseAdapter struct {
+	filter *BinaryFuseFilter
+}
+
+func newBinaryFuseAdapter() MembershipFilter {
+	return &binaryFuseAdapter{filter: NewEmptyBinaryFuseFilter()}
+}
+
+func (a *binaryFuseAdapter) MayContainsKey(key []byte) (bool, error) {
+	return a.filter.MayContainsKey(key)
+}
+
+func (a *binaryFuseAdapter) MayContainsAnyKeys(keys containers.Vector) (bool, *roaring.Bitmap, error) {
+	return a.filter.MayContainsAnyKeys(keys)
+}
+
+func (a *binaryFuseAdapter) Decode(buf []byte) error {
+	return DecodeBloomFilter(a.filter, buf)
+}
+
+// Encode is unused on the read path today: binary fuse buffers are
+// produced once by the block writer and never round-tripped back through
+// MembershipFilter, so there is nothing to build here.
+func (a *binaryFuseAdapter) Encode() ([]byte, error) {
+	return nil, fmt.Errorf("index: binary fuse filter encoding is owned by the block writer, not MembershipFilter")
+}
+
+func init() {
+	RegisterFilter(FilterBinaryFuse, newBinaryFuseAdapter)
+}
@@ -0,0 +1,215 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package index
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/bits"
+
+	"github.com/RoaringBitmap/roaring"
+	"github.com/matrixorigin/matrixone/pkg/container/types"
+	"github.com/matrixorigin/matrixone/pkg/vm/engine/tae/containers"
+)
+
+const (
+	ribbonBandWidth  = 64 // bits per row's coefficient band
+	ribbonResultBits = 8  // fingerprint width; also the width of each solved slot
+	ribbonMaxTries   = 16 // reseed attempts before giving up on construction
+)
+
+// RibbonFilter is a banded GF(2) linear-system approximate-membership
+// filter (Dillinger & Walzer's "Ribbon filter"): every key contributes
+// one row of the form `XOR of Z[start..start+63] selected by a 64-bit
+// coefficient == fingerprint`, and the whole system is solved once by
+// sequential Gaussian elimination. Its 8-bit result width gives a
+// false-positive rate of about 1/256, and the solved structure takes
+// len(keys)+64 one-byte slots; Build reseeds and solves again if the
+// elimination hits a conflict.
+type RibbonFilter struct {
+	seed     uint64
+	numKeys  int
+	numSlots int
+	solution []byte // nil until Build/Decode has run
+}
+
+func newRibbonFilter() MembershipFilter {
+	return &RibbonFilter{}
+}
+
+func init() {
+	RegisterFilter(FilterRibbon, newRibbonFilter)
+}
+
+// Build solves the ribbon system for keys, retrying with a new seed on
+// the rare construction conflict.
+func (f *RibbonFilter) Build(keys [][]byte) error {
+	if len(keys) == 0 {
+		f.numKeys = 0
+		f.numSlots = ribbonBandWidth
+		f.solution = make([]byte, f.numSlots)
+		return nil
+	}
+	for attempt := 0; attempt < ribbonMaxTries; attempt++ {
+		seed := uint64(attempt)*0x9E3779B97F4A7C15 + 1
+		if solution, slots, ok := ribbonSolve(keys, seed); ok {
+			f.seed = seed
+			f.numKeys = len(keys)
+			f.numSlots = slots
+			f.solution = solution
+			return nil
+		}
+	}
+	return fmt.Errorf("index: ribbon filter construction did not converge after %d tries", ribbonMaxTries)
+}
+
+// ribbonSolve runs one attempt of sequential banded Gaussian elimination
+// and, on success, returns the back-substituted solution array.
+func ribbonSolve(keys [][]byte, seed uint64) (solution []byte, numSlots int, ok bool) {
+	numSlots = len(keys) + ribbonBandWidth
+	bands := make([]uint64, numSlots)
+	results := make([]byte, numSlots)
+	occupied := make([]bool, numSlots)
+
+	for _, key := range keys {
+		start, coeff, fp := ribbonHash(key, seed, numSlots)
+		col := start
+		cf := coeff
+		rf := fp
+		for occupied[col] {
+			cf ^= bands[col]
+			rf ^= results[col]
+			if cf == 0 {
+				// This key's row reduced to nothing but the fingerprints
+				// disagree with an existing row: genuine collision in the
+				// linear system. Bail out and let the caller reseed.
+				if rf != 0 {
+					return nil, 0, false
+				}
+				break
+			}
+			offset := bits.TrailingZeros64(cf)
+			cf >>= uint(offset)
+			col += offset
+			if col >= numSlots {
+				return nil, 0, false
+			}
+		}
+		if cf != 0 {
+			occupied[col] = true
+			bands[col] = cf
+			results[col] = rf
+		}
+	}
+
+	solution = make([]byte, numSlots)
+	for col := numSlots - 1; col >= 0; col-- {
+		if !occupied[col] {
+			continue
+		}
+		v := results[col]
+		cf := bands[col] &^ 1 // clear the pivot bit itself
+		for cf != 0 {
+			j := bits.TrailingZeros64(cf)
+			cf &= cf - 1
+			if col+j < numSlots {
+				v ^= solution[col+j]
+			}
+		}
+		solution[col] = v
+	}
+	return solution, numSlots, true
+}
+
+func ribbonHash(key []byte, seed uint64, numSlots int) (start int, coeff uint64, fp byte) {
+	h1 := hashBytes(key, seed)
+	h2 := hashBytes(key, seed^0xD1B54A32D192ED03)
+	h3 := hashBytes(key, seed^0x94D049BB133111EB)
+
+	maxStart := numSlots - ribbonBandWidth
+	if maxStart < 0 {
+		maxStart = 0
+	}
+	start = int(h1 % uint64(maxStart+1))
+	coeff = h2 | 1 // force the low bit so the pivot column is always usable
+	fp = byte(h3)
+	return
+}
+
+func (f *RibbonFilter) MayContainsKey(key []byte) (bool, error) {
+	if len(f.solution) == 0 {
+		return false, nil
+	}
+	start, coeff, fp := ribbonHash(key, f.seed, f.numSlots)
+	acc := byte(0)
+	cf := coeff
+	for cf != 0 {
+		j := bits.TrailingZeros64(cf)
+		cf &= cf - 1
+		col := start + j
+		if col < len(f.solution) {
+			acc ^= f.solution[col]
+		}
+	}
+	return acc == fp, nil
+}
+
+func (f *RibbonFilter) MayContainsAnyKeys(keys containers.Vector) (exist bool, sels *roaring.Bitmap, err error) {
+	sels = roaring.New()
+	typ := keys.GetType()
+	keys.Foreach(func(v any, isNull bool, row int) error {
+		if isNull || err != nil {
+			return nil
+		}
+		ok, e := f.MayContainsKey(types.EncodeValue(v, typ))
+		if e != nil {
+			err = e
+			return nil
+		}
+		if ok {
+			sels.Add(uint64(row))
+		}
+		return nil
+	}, nil)
+	if err != nil {
+		return false, nil, err
+	}
+	return !sels.IsEmpty(), sels, nil
+}
+
+// Encode serializes the solved ribbon: seed, key/slot counts, then the
+// raw per-slot fingerprint bytes.
+func (f *RibbonFilter) Encode() ([]byte, error) {
+	buf := make([]byte, 8+4+4+len(f.solution))
+	binary.LittleEndian.PutUint64(buf[0:8], f.seed)
+	binary.LittleEndian.PutUint32(buf[8:12], uint32(f.numKeys))
+	binary.LittleEndian.PutUint32(buf[12:16], uint32(f.numSlots))
+	copy(buf[16:], f.solution)
+	return buf, nil
+}
+
+func (f *RibbonFilter) Decode(buf []byte) error {
+	if len(buf) < 16 {
+		return fmt.Errorf("index: ribbon filter buffer too short: %d bytes", len(buf))
+	}
+	f.seed = binary.LittleEndian.Uint64(buf[0:8])
+	f.numKeys = int(binary.LittleEndian.Uint32(buf[8:12]))
+	f.numSlots = int(binary.LittleEndian.Uint32(buf[12:16]))
+	if len(buf)-16 < f.numSlots {
+		return fmt.Errorf("index: ribbon filter buffer truncated: want %d slot bytes, have %d", f.numSlots, len(buf)-16)
+	}
+	f.solution = append([]byte(nil), buf[16:16+f.numSlots]...)
+	return nil
+}
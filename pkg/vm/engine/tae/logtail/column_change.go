@@ -0,0 +1,48 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logtail
+
+// ColumnChangeCommands returns the delete-then-insert pair a MO_COLUMNS
+// Source must produce for one column definition change at ts: first a
+// CmdDelete for oldSeqNum's row, so a CN subscriber drops the stale
+// definition from its catalog cache, then a CmdInsert carrying newRow, so
+// it picks up the replacement - mirroring the two-command shape already
+// emitted for a dropped column, so an in-place ALTER ... MODIFY COLUMN
+// TYPE swapping a shadow column in does not need a third code path for a
+// CN to learn about it. newRow is handed back verbatim by the insert
+// command's Decode: this package has no notion of MO_COLUMNS' row layout,
+// the same way it has no notion of catalog.ColumnDef.
+func ColumnChangeCommands[TS any](dbID, tbID uint64, ts TS, oldSeqNum uint32, newRow any) []Command[TS] {
+	return []Command[TS]{
+		{
+			DbID: dbID,
+			TbID: tbID,
+			Type: CmdDelete,
+			TS:   ts,
+			Decode: func([]string) (any, error) {
+				return oldSeqNum, nil
+			},
+		},
+		{
+			DbID: dbID,
+			TbID: tbID,
+			Type: CmdInsert,
+			TS:   ts,
+			Decode: func([]string) (any, error) {
+				return newRow, nil
+			},
+		},
+	}
+}
@@ -0,0 +1,42 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logtail
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestColumnChangeCommandsEmitsDeleteThenInsert(t *testing.T) {
+	newRow := map[string]any{"name": "amount", "type": "bigint"}
+	cmds := ColumnChangeCommands[int](1, 2, 100, 7, newRow)
+
+	require.Len(t, cmds, 2)
+
+	assert.Equal(t, CmdDelete, cmds[0].Type)
+	assert.Equal(t, uint64(1), cmds[0].DbID)
+	assert.Equal(t, uint64(2), cmds[0].TbID)
+	assert.Equal(t, 100, cmds[0].TS)
+	deleted, err := cmds[0].Decode(nil)
+	require.NoError(t, err)
+	assert.Equal(t, uint32(7), deleted)
+
+	assert.Equal(t, CmdInsert, cmds[1].Type)
+	inserted, err := cmds[1].Decode(nil)
+	require.NoError(t, err)
+	assert.Equal(t, newRow, inserted)
+}
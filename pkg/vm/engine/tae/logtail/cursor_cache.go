@@ -0,0 +1,91 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logtail
+
+import "sync"
+
+// CursorKey identifies one CN's sync cursor for one table, the key
+// LogtailMgr's cursor cache is keyed by.
+type CursorKey struct {
+	TableID uint64
+	CnID    string
+}
+
+// CursorCache is a bounded, size-limited LRU of cursor tokens LogtailMgr
+// keeps purely as a latency optimization for repeated HandleSyncLogTailReq
+// calls from the same CN: Get lets a caller skip re-deriving a token it
+// already handed back last time. RawSource's Seek contract guarantees a
+// token alone can always reconstruct the same position, so an entry this
+// cache evicts (once it passes capacity) only costs a slower
+// reconstruction on the CN's next call, never an incorrect resume.
+type CursorCache struct {
+	mu       sync.Mutex
+	capacity int
+	order    []CursorKey // least-recently-used at index 0
+	tokens   map[CursorKey][]byte
+}
+
+// NewCursorCache returns an empty cache holding at most capacity entries
+// (capacity <= 0 is treated as 1).
+func NewCursorCache(capacity int) *CursorCache {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &CursorCache{capacity: capacity, tokens: make(map[CursorKey][]byte)}
+}
+
+// Get returns key's cached token, if any, marking it most-recently-used.
+func (c *CursorCache) Get(key CursorKey) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	token, ok := c.tokens[key]
+	if ok {
+		c.touch(key)
+	}
+	return token, ok
+}
+
+// Put records token for key, evicting the least-recently-used entry first
+// if the cache is already at capacity and key is not already present.
+func (c *CursorCache) Put(key CursorKey, token []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, exists := c.tokens[key]; !exists && len(c.tokens) >= c.capacity {
+		oldest := c.order[0]
+		c.order = c.order[1:]
+		delete(c.tokens, oldest)
+	}
+	c.tokens[key] = token
+	c.touch(key)
+}
+
+// Len reports how many cursors are currently cached.
+func (c *CursorCache) Len() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return len(c.tokens)
+}
+
+// touch moves key to the most-recently-used end of order. Callers must
+// hold c.mu.
+func (c *CursorCache) touch(key CursorKey) {
+	for i, k := range c.order {
+		if k == key {
+			c.order = append(c.order[:i], c.order[i+1:]...)
+			break
+		}
+	}
+	c.order = append(c.order, key)
+}
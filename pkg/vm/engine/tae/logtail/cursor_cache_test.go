@@ -0,0 +1,90 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logtail
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCursorCacheGetMissOnEmptyCache(t *testing.T) {
+	c := NewCursorCache(2)
+	_, ok := c.Get(CursorKey{TableID: 1, CnID: "cn1"})
+	assert.False(t, ok)
+	assert.Equal(t, 0, c.Len())
+}
+
+func TestCursorCachePutThenGetRoundTrips(t *testing.T) {
+	c := NewCursorCache(2)
+	key := CursorKey{TableID: 1, CnID: "cn1"}
+	c.Put(key, []byte("tok-1"))
+
+	got, ok := c.Get(key)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("tok-1"), got)
+	assert.Equal(t, 1, c.Len())
+}
+
+func TestCursorCacheEvictsLeastRecentlyUsedAtCapacity(t *testing.T) {
+	c := NewCursorCache(2)
+	k1 := CursorKey{TableID: 1, CnID: "cn1"}
+	k2 := CursorKey{TableID: 2, CnID: "cn1"}
+	k3 := CursorKey{TableID: 3, CnID: "cn1"}
+
+	c.Put(k1, []byte("tok-1"))
+	c.Put(k2, []byte("tok-2"))
+	// Touch k1 so k2 becomes the least-recently-used entry.
+	_, _ = c.Get(k1)
+	c.Put(k3, []byte("tok-3"))
+
+	assert.Equal(t, 2, c.Len())
+	_, ok := c.Get(k2)
+	assert.False(t, ok, "k2 should have been evicted as the least-recently-used entry")
+	_, ok = c.Get(k1)
+	assert.True(t, ok)
+	_, ok = c.Get(k3)
+	assert.True(t, ok)
+}
+
+func TestCursorCacheNonPositiveCapacityTreatedAsOne(t *testing.T) {
+	c := NewCursorCache(0)
+	k1 := CursorKey{TableID: 1, CnID: "cn1"}
+	k2 := CursorKey{TableID: 2, CnID: "cn1"}
+
+	c.Put(k1, []byte("tok-1"))
+	c.Put(k2, []byte("tok-2"))
+
+	assert.Equal(t, 1, c.Len())
+	_, ok := c.Get(k1)
+	assert.False(t, ok)
+	_, ok = c.Get(k2)
+	assert.True(t, ok)
+}
+
+func TestCursorCachePutExistingKeyDoesNotEvict(t *testing.T) {
+	c := NewCursorCache(2)
+	k1 := CursorKey{TableID: 1, CnID: "cn1"}
+	k2 := CursorKey{TableID: 2, CnID: "cn1"}
+
+	c.Put(k1, []byte("tok-1"))
+	c.Put(k2, []byte("tok-2"))
+	c.Put(k1, []byte("tok-1-updated"))
+
+	assert.Equal(t, 2, c.Len())
+	got, ok := c.Get(k1)
+	assert.True(t, ok)
+	assert.Equal(t, []byte("tok-1-updated"), got)
+}
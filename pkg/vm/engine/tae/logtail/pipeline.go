@@ -0,0 +1,92 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logtail
+
+import (
+	"context"
+	"fmt"
+)
+
+// Syncer is the RPC transport a CN's Pipeline drives: each call is one
+// HandleSyncLogTailReq round trip, local or over the wire.
+type Syncer[TS any] interface {
+	Sync(ctx context.Context, req SyncRequest) (SyncResponse[TS], error)
+}
+
+// RawSink receives verified commands Pipeline drains from Syncer.
+type RawSink[TS any] interface {
+	Receive(ctx context.Context, cmd RawCommand[TS]) error
+}
+
+// Pipeline drives successive Syncer.Sync calls to completion, delivering
+// every command to sink only after confirming its CRC32. A command that
+// fails verification is re-fetched on its own - a narrow request resuming
+// from the previous command's ResumeAfter (or, for the batch's first
+// command, the token the batch was requested with) bounded to just that
+// command's size - rather than
+// discarding and re-pulling the whole in-flight response. Pipeline gives
+// up if the resend also fails verification, since a second corruption of
+// the same bytes points at something worse than a transient transport
+// error.
+func Pipeline[TS any](ctx context.Context, syncer Syncer[TS], startToken []byte, maxBytes int, sink RawSink[TS]) error {
+	token := startToken
+	for {
+		resp, err := syncer.Sync(ctx, SyncRequest{ResumeToken: token, MaxBytes: maxBytes})
+		if err != nil {
+			return err
+		}
+		if len(resp.Commands) == 0 {
+			return nil
+		}
+
+		prevToken := token
+		for _, cmd := range resp.Commands {
+			if !cmd.Verify() {
+				fixed, err := resendOne[TS](ctx, syncer, prevToken, len(cmd.Payload))
+				if err != nil {
+					return err
+				}
+				cmd = fixed
+			}
+			if err := sink.Receive(ctx, cmd); err != nil {
+				return err
+			}
+			prevToken = cmd.ResumeAfter
+		}
+		token = resp.NextResumeToken
+	}
+}
+
+// resendOne re-requests exactly one command's worth of bytes starting from
+// fromToken, for Pipeline to retry a single command that failed CRC
+// verification.
+func resendOne[TS any](ctx context.Context, syncer Syncer[TS], fromToken []byte, payloadLen int) (RawCommand[TS], error) {
+	maxBytes := payloadLen
+	if maxBytes <= 0 {
+		maxBytes = 1
+	}
+	resp, err := syncer.Sync(ctx, SyncRequest{ResumeToken: fromToken, MaxBytes: maxBytes})
+	if err != nil {
+		return RawCommand[TS]{}, fmt.Errorf("logtail: resend corrupted command: %w", err)
+	}
+	if len(resp.Commands) == 0 {
+		return RawCommand[TS]{}, fmt.Errorf("logtail: resend corrupted command: server returned nothing")
+	}
+	cmd := resp.Commands[0]
+	if !cmd.Verify() {
+		return RawCommand[TS]{}, fmt.Errorf("logtail: command failed CRC verification twice in a row")
+	}
+	return cmd, nil
+}
@@ -0,0 +1,119 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logtail
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// directSyncer answers Sync by calling SyncLogTail against src
+// directly, the same way a CN's in-process Syncer would wrap a local
+// LogtailMgr rather than going over the wire.
+type directSyncer struct {
+	src *fakeRawSource
+}
+
+func (d *directSyncer) Sync(ctx context.Context, req SyncRequest) (SyncResponse[int], error) {
+	return SyncLogTail[int](ctx, d.src, req)
+}
+
+// corruptingSyncer flips a bit in the first command's payload of selected
+// Sync calls, without touching its recorded CRC32, simulating bytes
+// damaged in transit after SyncLogTail computed the checksum.
+type corruptingSyncer struct {
+	inner      Syncer[int]
+	calls      int
+	corruptAt  int // corrupt the call at this index (0-based)
+	persistent bool // corrupt every call, ignoring corruptAt
+}
+
+func (c *corruptingSyncer) Sync(ctx context.Context, req SyncRequest) (SyncResponse[int], error) {
+	resp, err := c.inner.Sync(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	if len(resp.Commands) > 0 && (c.persistent || c.calls == c.corruptAt) {
+		corrupted := append([]byte{}, resp.Commands[0].Payload...)
+		corrupted[0] ^= 0xff
+		resp.Commands[0].Payload = corrupted
+	}
+	c.calls++
+	return resp, nil
+}
+
+type rawRecordingSink struct {
+	received []RawCommand[int]
+}
+
+func (s *rawRecordingSink) Receive(ctx context.Context, cmd RawCommand[int]) error {
+	s.received = append(s.received, cmd)
+	return nil
+}
+
+func payloads(cmds []RawCommand[int]) []string {
+	out := make([]string, len(cmds))
+	for i, c := range cmds {
+		out[i] = string(c.Payload)
+	}
+	return out
+}
+
+func TestPipelineDrainsAllCommandsInOrder(t *testing.T) {
+	src := &fakeRawSource{payloads: [][]byte{[]byte("row-1"), []byte("row-2"), []byte("row-3")}}
+	syncer := &directSyncer{src: src}
+	sink := &rawRecordingSink{}
+
+	err := Pipeline[int](context.Background(), syncer, nil, 1, sink)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"row-1", "row-2", "row-3"}, payloads(sink.received))
+}
+
+func TestPipelineResendsOnlyTheCorruptedCommand(t *testing.T) {
+	src := &fakeRawSource{payloads: [][]byte{[]byte("row-1"), []byte("row-2"), []byte("row-3")}}
+	syncer := &corruptingSyncer{inner: &directSyncer{src: src}, corruptAt: 0}
+	sink := &rawRecordingSink{}
+
+	err := Pipeline[int](context.Background(), syncer, nil, 1024, sink)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"row-1", "row-2", "row-3"}, payloads(sink.received))
+	assert.Greater(t, syncer.calls, 1, "a corrupted command must trigger a resend round trip")
+	for _, cmd := range sink.received {
+		assert.True(t, cmd.Verify(), "every command Pipeline hands to sink must verify")
+	}
+}
+
+func TestPipelineGivesUpWhenResendAlsoFails(t *testing.T) {
+	src := &fakeRawSource{payloads: [][]byte{[]byte("row-1"), []byte("row-2")}}
+	syncer := &corruptingSyncer{inner: &directSyncer{src: src}, persistent: true}
+	sink := &rawRecordingSink{}
+
+	err := Pipeline[int](context.Background(), syncer, nil, 1024, sink)
+	require.Error(t, err)
+	assert.Empty(t, sink.received, "nothing should be delivered once a command fails verification twice")
+}
+
+func TestPipelineStopsOnEmptyResponse(t *testing.T) {
+	src := &fakeRawSource{payloads: nil}
+	syncer := &directSyncer{src: src}
+	sink := &rawRecordingSink{}
+
+	err := Pipeline[int](context.Background(), syncer, nil, 1024, sink)
+	require.NoError(t, err)
+	assert.Empty(t, sink.received)
+}
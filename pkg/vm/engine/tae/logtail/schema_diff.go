@@ -0,0 +1,225 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logtail
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// ColumnInfo is one column as it existed at a single schema version,
+// keyed by its stable seqnum rather than its (renamable) name - the same
+// key bat.Attrs already indexes columns by for a single version.
+type ColumnInfo struct {
+	Seqnum    uint32
+	Name      string
+	Type      string
+	DroppedAt *time.Time
+}
+
+// SchemaVersionSource answers the two questions HandleSyncSchemaDiffReq
+// needs to walk a table's schema history: which versions lie between two
+// points, and what a version's columns looked like. It doesn't know
+// about catalog.TableEntry or how versions are persisted: a caller wires
+// it to whatever holds that history.
+type SchemaVersionSource interface {
+	// Versions returns, in ascending order, every schema.Version number
+	// strictly after fromVer up to and including toVer.
+	Versions(ctx context.Context, tbID uint64, fromVer, toVer uint32) ([]uint32, error)
+	// ColumnsAt returns the full column set as of version, keyed by
+	// seqnum, including columns dropped at or before version (with
+	// DroppedAt set) so callers can still diff against them.
+	ColumnsAt(ctx context.Context, tbID uint64, version uint32) ([]ColumnInfo, error)
+}
+
+// RetypedColumn is a column whose stored type changed between two
+// versions, the one change that forces existing rows to be rewritten
+// rather than merely null-filled.
+type RetypedColumn struct {
+	Seqnum  uint32
+	Name    string
+	OldType string
+	NewType string
+}
+
+// RenamedColumn is a column whose name changed between two versions
+// while keeping its seqnum - visible to a planner's cached column-name
+// bindings, but not to anything keyed by seqnum.
+type RenamedColumn struct {
+	Seqnum  uint32
+	OldName string
+	NewName string
+}
+
+// DroppedColumn is a column present in the older version and absent (or
+// marked dropped) in the newer one.
+type DroppedColumn struct {
+	Seqnum    uint32
+	Name      string
+	Type      string
+	DroppedAt time.Time
+}
+
+// SchemaDiff is the structured difference between two schema versions of
+// one table, keyed by stable seqnum so it survives renames.
+type SchemaDiff struct {
+	FromVersion uint32
+	ToVersion   uint32
+	Added       []ColumnInfo
+	Dropped     []DroppedColumn
+	Retyped     []RetypedColumn
+	Renamed     []RenamedColumn
+}
+
+// ComputeSchemaDiff diffs a table's columns as of two versions, keying
+// the comparison by Seqnum so a rename is reported as Renamed rather
+// than as a drop-then-add.
+func ComputeSchemaDiff(fromVer, toVer uint32, from, to []ColumnInfo) *SchemaDiff {
+	diff := &SchemaDiff{FromVersion: fromVer, ToVersion: toVer}
+
+	byFrom := make(map[uint32]ColumnInfo, len(from))
+	for _, c := range from {
+		byFrom[c.Seqnum] = c
+	}
+	byTo := make(map[uint32]ColumnInfo, len(to))
+	for _, c := range to {
+		byTo[c.Seqnum] = c
+	}
+
+	for _, c := range to {
+		old, existed := byFrom[c.Seqnum]
+		if !existed {
+			diff.Added = append(diff.Added, c)
+			continue
+		}
+		if old.Type != c.Type {
+			diff.Retyped = append(diff.Retyped, RetypedColumn{Seqnum: c.Seqnum, Name: c.Name, OldType: old.Type, NewType: c.Type})
+		}
+		if old.Name != c.Name {
+			diff.Renamed = append(diff.Renamed, RenamedColumn{Seqnum: c.Seqnum, OldName: old.Name, NewName: c.Name})
+		}
+	}
+	for _, c := range from {
+		newC, stillPresent := byTo[c.Seqnum]
+		if stillPresent && newC.DroppedAt == nil {
+			continue
+		}
+		droppedAt := time.Time{}
+		if newC.DroppedAt != nil {
+			droppedAt = *newC.DroppedAt
+		} else if c.DroppedAt != nil {
+			droppedAt = *c.DroppedAt
+		}
+		diff.Dropped = append(diff.Dropped, DroppedColumn{Seqnum: c.Seqnum, Name: c.Name, Type: c.Type, DroppedAt: droppedAt})
+	}
+	return diff
+}
+
+// RowMappingHint tells a CN whether a version's schema change requires
+// rewriting already-stored rows or merely filling new columns with
+// null, the same decision a planner needs before trusting a cached plan
+// across the change (the TiDB binding-cache pattern of invalidating a
+// plan only when the underlying mapping it assumed no longer holds).
+type RowMappingHint int
+
+const (
+	// RowMappingUnchanged means existing rows need no transformation.
+	RowMappingUnchanged RowMappingHint = iota
+	// RowMappingNullFill means existing rows are still valid as stored;
+	// new columns simply read as null until backfilled.
+	RowMappingNullFill
+	// RowMappingRewrite means a column's on-disk type changed, so
+	// existing rows must be rewritten (or reinterpreted through
+	// reorg.CheckedCast) before they can be read under the new version.
+	RowMappingRewrite
+)
+
+func (h RowMappingHint) String() string {
+	switch h {
+	case RowMappingUnchanged:
+		return "unchanged"
+	case RowMappingNullFill:
+		return "null-fill"
+	case RowMappingRewrite:
+		return "rewrite"
+	default:
+		return "unknown"
+	}
+}
+
+func rowMappingHint(diff *SchemaDiff) RowMappingHint {
+	if len(diff.Retyped) > 0 {
+		return RowMappingRewrite
+	}
+	if len(diff.Added) > 0 {
+		return RowMappingNullFill
+	}
+	return RowMappingUnchanged
+}
+
+// VersionDiff is one intermediate version's diff against the version
+// immediately before it, plus the RowMappingHint that version's change
+// implies.
+type VersionDiff struct {
+	Version    uint32
+	Diff       *SchemaDiff
+	RowMapping RowMappingHint
+}
+
+// SyncSchemaDiffReq asks for every schema change a table went through
+// between FromVersion and ToVersion, for a CN that missed several DDLs
+// to catch up without re-subscribing to the full data logtail.
+type SyncSchemaDiffReq struct {
+	TbID        uint64
+	FromVersion uint32
+	ToVersion   uint32
+}
+
+// SyncSchemaDiffResp is HandleSyncSchemaDiffReq's result: one VersionDiff
+// per intermediate schema.Version between the request's FromVersion and
+// ToVersion, in ascending order.
+type SyncSchemaDiffResp struct {
+	Versions []VersionDiff
+}
+
+// HandleSyncSchemaDiffReq walks every schema version between
+// req.FromVersion and req.ToVersion, diffing each against its
+// predecessor so a CN can apply them in order instead of only seeing the
+// net change between the two endpoints.
+func HandleSyncSchemaDiffReq(ctx context.Context, src SchemaVersionSource, req SyncSchemaDiffReq) (SyncSchemaDiffResp, error) {
+	versions, err := src.Versions(ctx, req.TbID, req.FromVersion, req.ToVersion)
+	if err != nil {
+		return SyncSchemaDiffResp{}, fmt.Errorf("logtail: list schema versions: %w", err)
+	}
+
+	var resp SyncSchemaDiffResp
+	prevVer := req.FromVersion
+	prevCols, err := src.ColumnsAt(ctx, req.TbID, prevVer)
+	if err != nil {
+		return SyncSchemaDiffResp{}, fmt.Errorf("logtail: columns at version %d: %w", prevVer, err)
+	}
+
+	for _, v := range versions {
+		cols, err := src.ColumnsAt(ctx, req.TbID, v)
+		if err != nil {
+			return SyncSchemaDiffResp{}, fmt.Errorf("logtail: columns at version %d: %w", v, err)
+		}
+		diff := ComputeSchemaDiff(prevVer, v, prevCols, cols)
+		resp.Versions = append(resp.Versions, VersionDiff{Version: v, Diff: diff, RowMapping: rowMappingHint(diff)})
+		prevVer, prevCols = v, cols
+	}
+	return resp, nil
+}
@@ -0,0 +1,126 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logtail
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestComputeSchemaDiffDetectsAddedColumn(t *testing.T) {
+	from := []ColumnInfo{{Seqnum: 1, Name: "id", Type: "int"}}
+	to := []ColumnInfo{{Seqnum: 1, Name: "id", Type: "int"}, {Seqnum: 2, Name: "note", Type: "varchar"}}
+
+	diff := ComputeSchemaDiff(1, 2, from, to)
+	require.Len(t, diff.Added, 1)
+	assert.Equal(t, uint32(2), diff.Added[0].Seqnum)
+	assert.Empty(t, diff.Dropped)
+	assert.Empty(t, diff.Retyped)
+	assert.Empty(t, diff.Renamed)
+}
+
+func TestComputeSchemaDiffDetectsDroppedColumnWithTimestamp(t *testing.T) {
+	droppedAt := time.Unix(500, 0)
+	from := []ColumnInfo{{Seqnum: 1, Name: "id", Type: "int"}, {Seqnum: 2, Name: "note", Type: "varchar"}}
+	to := []ColumnInfo{{Seqnum: 1, Name: "id", Type: "int"}, {Seqnum: 2, Name: "note", Type: "varchar", DroppedAt: &droppedAt}}
+
+	diff := ComputeSchemaDiff(1, 2, from, to)
+	require.Len(t, diff.Dropped, 1)
+	assert.Equal(t, uint32(2), diff.Dropped[0].Seqnum)
+	assert.Equal(t, droppedAt, diff.Dropped[0].DroppedAt)
+}
+
+func TestComputeSchemaDiffDetectsRetypeAndRename(t *testing.T) {
+	from := []ColumnInfo{{Seqnum: 1, Name: "amount", Type: "int32"}}
+	to := []ColumnInfo{{Seqnum: 1, Name: "amount_cents", Type: "int64"}}
+
+	diff := ComputeSchemaDiff(1, 2, from, to)
+	require.Len(t, diff.Retyped, 1)
+	assert.Equal(t, "int32", diff.Retyped[0].OldType)
+	assert.Equal(t, "int64", diff.Retyped[0].NewType)
+	require.Len(t, diff.Renamed, 1)
+	assert.Equal(t, "amount", diff.Renamed[0].OldName)
+	assert.Equal(t, "amount_cents", diff.Renamed[0].NewName)
+}
+
+func TestRowMappingHintPrefersRewriteOverNullFill(t *testing.T) {
+	from := []ColumnInfo{{Seqnum: 1, Name: "id", Type: "int32"}}
+	to := []ColumnInfo{{Seqnum: 1, Name: "id", Type: "int64"}, {Seqnum: 2, Name: "note", Type: "varchar"}}
+
+	diff := ComputeSchemaDiff(1, 2, from, to)
+	assert.Equal(t, RowMappingRewrite, rowMappingHint(diff))
+}
+
+func TestRowMappingHintNullFillOnAddOnly(t *testing.T) {
+	from := []ColumnInfo{{Seqnum: 1, Name: "id", Type: "int32"}}
+	to := []ColumnInfo{{Seqnum: 1, Name: "id", Type: "int32"}, {Seqnum: 2, Name: "note", Type: "varchar"}}
+
+	diff := ComputeSchemaDiff(1, 2, from, to)
+	assert.Equal(t, RowMappingNullFill, rowMappingHint(diff))
+}
+
+func TestRowMappingHintUnchangedWhenNothingMoved(t *testing.T) {
+	cols := []ColumnInfo{{Seqnum: 1, Name: "id", Type: "int32"}}
+	diff := ComputeSchemaDiff(1, 2, cols, cols)
+	assert.Equal(t, RowMappingUnchanged, rowMappingHint(diff))
+}
+
+// fakeSchemaVersionSource serves a fixed, in-memory schema history for one
+// table, keyed by version number.
+type fakeSchemaVersionSource struct {
+	columnsByVersion map[uint32][]ColumnInfo
+	allVersions      []uint32 // ascending, including the baseline version
+}
+
+func (s *fakeSchemaVersionSource) Versions(ctx context.Context, tbID uint64, fromVer, toVer uint32) ([]uint32, error) {
+	var out []uint32
+	for _, v := range s.allVersions {
+		if v > fromVer && v <= toVer {
+			out = append(out, v)
+		}
+	}
+	return out, nil
+}
+
+func (s *fakeSchemaVersionSource) ColumnsAt(ctx context.Context, tbID uint64, version uint32) ([]ColumnInfo, error) {
+	return s.columnsByVersion[version], nil
+}
+
+func TestHandleSyncSchemaDiffReqWalksIntermediateVersionsInOrder(t *testing.T) {
+	src := &fakeSchemaVersionSource{
+		allVersions: []uint32{1, 2, 3},
+		columnsByVersion: map[uint32][]ColumnInfo{
+			1: {{Seqnum: 1, Name: "id", Type: "int32"}},
+			2: {{Seqnum: 1, Name: "id", Type: "int32"}, {Seqnum: 2, Name: "note", Type: "varchar"}},
+			3: {{Seqnum: 1, Name: "id", Type: "int64"}, {Seqnum: 2, Name: "note", Type: "varchar"}},
+		},
+	}
+
+	resp, err := HandleSyncSchemaDiffReq(context.Background(), src, SyncSchemaDiffReq{TbID: 42, FromVersion: 1, ToVersion: 3})
+	require.NoError(t, err)
+	require.Len(t, resp.Versions, 2)
+
+	assert.Equal(t, uint32(2), resp.Versions[0].Version)
+	assert.Equal(t, RowMappingNullFill, resp.Versions[0].RowMapping)
+	assert.Len(t, resp.Versions[0].Diff.Added, 1)
+
+	assert.Equal(t, uint32(3), resp.Versions[1].Version)
+	assert.Equal(t, RowMappingRewrite, resp.Versions[1].RowMapping)
+	assert.Len(t, resp.Versions[1].Diff.Retyped, 1)
+}
@@ -0,0 +1,138 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logtail
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+)
+
+// ShardDescriptor is what api.SyncLogTailReq is meant to carry alongside
+// its existing [CnHave, CnWant] window: LogtailMgr.GetReader is left to
+// route a sharded request to a reader that only materializes segments
+// whose SegmentID hashes into this slot, the same way HandleSyncLogTailReq
+// materializes every segment today for ShardCount == 1.
+type ShardDescriptor struct {
+	ShardID    uint32
+	ShardCount uint32
+}
+
+// Validate reports whether d describes a well-formed shard of a request
+// split into ShardCount pieces.
+func (d ShardDescriptor) Validate() error {
+	if d.ShardCount == 0 {
+		return fmt.Errorf("logtail: shard count must be positive")
+	}
+	if d.ShardID >= d.ShardCount {
+		return fmt.Errorf("logtail: shard id %d out of range for shard count %d", d.ShardID, d.ShardCount)
+	}
+	return nil
+}
+
+// Owns reports whether segmentID's dirty range belongs to this shard.
+// Every SegmentID hashes to exactly one shard across a fixed ShardCount,
+// so a segment's rows are never split across two shards' responses.
+func (d ShardDescriptor) Owns(segmentID uint64) bool {
+	return segmentID%uint64(d.ShardCount) == uint64(d.ShardID)
+}
+
+// ShardRow is a row a sharded read returns; SegmentID associates it back
+// to the segment FanOut uses to restore commit order across shards.
+type ShardRow interface {
+	SegmentID() uint64
+}
+
+// ShardReader fetches one shard's worth of a GetDirtyByTable response.
+// A local call routes straight to LogtailMgr.GetReader; a ShardRegistrar
+// entry routes to whichever TN peer owns that shard when the log-service
+// is federated.
+type ShardReader[Row ShardRow] interface {
+	Read(ctx context.Context, shard ShardDescriptor) ([]Row, error)
+}
+
+// FanOut issues shardCount parallel reads against reader and merges the
+// results, preserving each segment's internal row order and ordering
+// segments by SegmentID so the merged result is identical to what an
+// unsharded fetch over the same range would have produced.
+func FanOut[Row ShardRow](ctx context.Context, shardCount uint32, reader ShardReader[Row]) ([]Row, error) {
+	if shardCount == 0 {
+		return nil, fmt.Errorf("logtail: shard count must be positive")
+	}
+
+	results := make([][]Row, shardCount)
+	errs := make([]error, shardCount)
+	var wg sync.WaitGroup
+	for i := uint32(0); i < shardCount; i++ {
+		wg.Add(1)
+		go func(shardID uint32) {
+			defer wg.Done()
+			rows, err := reader.Read(ctx, ShardDescriptor{ShardID: shardID, ShardCount: shardCount})
+			results[shardID] = rows
+			errs[shardID] = err
+		}(i)
+	}
+	wg.Wait()
+
+	for _, err := range errs {
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	var merged []Row
+	for _, rows := range results {
+		merged = append(merged, rows...)
+	}
+	// Rows within a shard already arrived in the reader's commit order;
+	// a stable sort on SegmentID alone regroups segments without
+	// reordering the rows inside any one of them.
+	sort.SliceStable(merged, func(i, j int) bool {
+		return merged[i].SegmentID() < merged[j].SegmentID()
+	})
+	return merged, nil
+}
+
+// ShardRegistrar maps a shard to the TN peer responsible for it, the
+// shardservice-style directory a federated log-service uses to dispatch
+// FanOut's per-shard reads to different peers instead of serving every
+// shard from the local LogtailMgr.
+type ShardRegistrar struct {
+	mu    sync.RWMutex
+	peers map[uint32]string
+}
+
+// NewShardRegistrar returns an empty ShardRegistrar.
+func NewShardRegistrar() *ShardRegistrar {
+	return &ShardRegistrar{peers: make(map[uint32]string)}
+}
+
+// Register records that shardID is served by peer, overwriting whatever
+// was previously registered for it (e.g. after a TN failover).
+func (r *ShardRegistrar) Register(shardID uint32, peer string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.peers[shardID] = peer
+}
+
+// PeerFor returns the peer registered for shardID, or ok=false if none has
+// been registered, which callers should treat as "serve it locally".
+func (r *ShardRegistrar) PeerFor(shardID uint32) (peer string, ok bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	peer, ok = r.peers[shardID]
+	return
+}
@@ -0,0 +1,114 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logtail
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeRow struct {
+	segID uint64
+	rowID int
+}
+
+func (r fakeRow) SegmentID() uint64 { return r.segID }
+
+// fakeShardedSource holds every row from a 5-segment table, and Read
+// returns exactly the rows whose segment hashes into the requested shard,
+// mimicking a local LogtailMgr.GetReader split across ShardCount shards.
+type fakeShardedSource struct {
+	rows []fakeRow
+}
+
+func (s *fakeShardedSource) Read(ctx context.Context, shard ShardDescriptor) ([]fakeRow, error) {
+	var out []fakeRow
+	for _, r := range s.rows {
+		if shard.Owns(r.segID) {
+			out = append(out, r)
+		}
+	}
+	return out, nil
+}
+
+func allRowsFiveSegments() []fakeRow {
+	var rows []fakeRow
+	for seg := uint64(0); seg < 5; seg++ {
+		for row := 0; row < 4; row++ {
+			rows = append(rows, fakeRow{segID: seg, rowID: row})
+		}
+	}
+	return rows
+}
+
+func TestShardDescriptorValidate(t *testing.T) {
+	assert.NoError(t, ShardDescriptor{ShardID: 2, ShardCount: 3}.Validate())
+	assert.Error(t, ShardDescriptor{ShardID: 3, ShardCount: 3}.Validate())
+	assert.Error(t, ShardDescriptor{ShardID: 0, ShardCount: 0}.Validate())
+}
+
+func TestFanOutMatchesUnshardedFetch(t *testing.T) {
+	src := &fakeShardedSource{rows: allRowsFiveSegments()}
+
+	unsharded, err := src.Read(context.Background(), ShardDescriptor{ShardID: 0, ShardCount: 1})
+	require.NoError(t, err)
+
+	sharded, err := FanOut[fakeRow](context.Background(), 3, src)
+	require.NoError(t, err)
+
+	require.Len(t, sharded, len(unsharded))
+	for i := range unsharded {
+		assert.Equal(t, unsharded[i], sharded[i])
+	}
+}
+
+func TestFanOutEveryRowOwnedByExactlyOneShard(t *testing.T) {
+	rows := allRowsFiveSegments()
+	const shardCount = 3
+	owners := make(map[uint64]int)
+	for _, r := range rows {
+		owned := 0
+		for shardID := uint32(0); shardID < shardCount; shardID++ {
+			if (ShardDescriptor{ShardID: shardID, ShardCount: shardCount}).Owns(r.segID) {
+				owned++
+				owners[r.segID] = int(shardID)
+			}
+		}
+		assert.Equal(t, 1, owned, "segment %d must be owned by exactly one shard", r.segID)
+	}
+	assert.Len(t, owners, 5)
+}
+
+func TestShardRegistrarRegisterAndLookup(t *testing.T) {
+	r := NewShardRegistrar()
+	_, ok := r.PeerFor(0)
+	assert.False(t, ok)
+
+	r.Register(0, "tn-1:6001")
+	r.Register(1, "tn-2:6001")
+	peer, ok := r.PeerFor(0)
+	require.True(t, ok)
+	assert.Equal(t, "tn-1:6001", peer)
+
+	// A later registration for the same shard (e.g. after failover)
+	// overwrites the old peer rather than appending to it.
+	r.Register(0, "tn-3:6001")
+	peer, ok = r.PeerFor(0)
+	require.True(t, ok)
+	assert.Equal(t, "tn-3:6001", peer)
+}
@@ -0,0 +1,221 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package logtail turns a collector's one-shot [CnHave, CnWant] command
+// stream, the kind HandleSyncLogTailReq returns in one RPC today, into a
+// resumable, filtered subscription. It doesn't know about
+// catalog.SegmentEntry/BlockEntry, the collector's own cursor over a
+// table's commit chain, or the RPC transport a CN reconnects over; those
+// own producing Commands in commit order and carrying bytes over the
+// wire. This package owns three things: replaying a Source from a
+// ResumeToken without re-delivering commands a consumer already persisted
+// before it disconnected, dropping commands a Filter excludes before
+// paying to Decode their payload, and keeping a filtered-quiet consumer's
+// watermark moving with periodic heartbeats.
+package logtail
+
+import (
+	"context"
+	"time"
+)
+
+// CommandType classifies a logtail Command the way the Insert/Delete/
+// SegDelete commands HandleSyncLogTailReq streams today are classified.
+type CommandType uint8
+
+const (
+	CmdInsert CommandType = iota
+	CmdDelete
+	CmdSegDelete
+)
+
+// Command is one entry in a table's commit-ordered command stream.
+// Decode is called at most once per command, and only for commands a
+// subscription's Filter lets through, so a CN that only wants
+// meta-updates never pays to materialize segment/block payload columns
+// for commands it will discard.
+type Command[TS any] struct {
+	DbID uint64
+	TbID uint64
+	Type CommandType
+	TS   TS
+	// Decode materializes the command's payload, restricted to
+	// columnMask if columnMask is non-empty. Implementations should defer
+	// any batch decode work to this call rather than doing it eagerly.
+	Decode func(columnMask []string) (any, error)
+}
+
+// Entry is what HandleSubscribeLogTail pushes to a Sink: a Command that
+// passed Filter, with its payload already decoded under the subscription's
+// ColumnMask.
+type Entry[TS any] struct {
+	DbID    uint64
+	TbID    uint64
+	Type    CommandType
+	TS      TS
+	Payload any
+}
+
+// ResumeToken is the opaque cursor a CN persists alongside the commands it
+// has durably applied. CommandIdx counts how many commands at TS the
+// consumer had already received (0 if it disconnected before any command
+// at TS), so HandleSubscribeLogTail can skip exactly that many on restart
+// without assuming anything about how Source orders same-TS commands
+// beyond that it replays them in the same order every time.
+type ResumeToken[TS any] struct {
+	TS         TS
+	CommandIdx int
+}
+
+// Filter restricts a subscription to the commands and payload columns a
+// CN actually needs. A nil/zero field matches everything.
+type Filter struct {
+	DbID *uint64
+	TbID *uint64
+	// Types restricts delivery to these CommandTypes. Empty matches all.
+	Types []CommandType
+	// ColumnMask restricts Decode to these columns. Empty decodes every
+	// column Source would otherwise produce.
+	ColumnMask []string
+}
+
+// Matches reports whether cmd passes f's DbID/TbID/Types filters. It never
+// looks at ColumnMask: that only narrows what Decode returns for a command
+// that already matches.
+func (f Filter) Matches(dbID, tbID uint64, typ CommandType) bool {
+	if f.DbID != nil && *f.DbID != dbID {
+		return false
+	}
+	if f.TbID != nil && *f.TbID != tbID {
+		return false
+	}
+	if len(f.Types) == 0 {
+		return true
+	}
+	for _, t := range f.Types {
+		if t == typ {
+			return true
+		}
+	}
+	return false
+}
+
+// Source produces a table's command stream in commit order starting from
+// CnHave. It owns nothing about resuming mid-stream: HandleSubscribeLogTail
+// always drives a Source from its earliest available command and relies on
+// ResumeToken skipping to avoid redelivery.
+type Source[TS any] interface {
+	// Next returns the next command, or ok=false once the [CnHave, CnWant]
+	// window is exhausted.
+	Next(ctx context.Context) (cmd Command[TS], ok bool, err error)
+	// Watermark returns the TS up to which Source has produced every
+	// command, suitable for a heartbeat between commands.
+	Watermark() TS
+}
+
+// Sink receives a filtered, resumable subscription's output.
+type Sink[TS any] interface {
+	Send(ctx context.Context, entry Entry[TS]) error
+	// Heartbeat reports watermark so a consumer whose Filter is
+	// producing nothing can still advance its resume point.
+	Heartbeat(ctx context.Context, watermark ResumeToken[TS]) error
+}
+
+// SubscribeRequest configures one HandleSubscribeLogTail call.
+type SubscribeRequest[TS any] struct {
+	DbID uint64
+	TbID uint64
+	// Resume is the consumer's last persisted cursor. Nil subscribes from
+	// the start of Source.
+	Resume *ResumeToken[TS]
+	Filter Filter
+	// HeartbeatInterval defaults to defaultHeartbeatInterval when zero.
+	HeartbeatInterval time.Duration
+}
+
+const defaultHeartbeatInterval = 3 * time.Second
+
+// HandleSubscribeLogTail streams src's commands to sink, skipping whatever
+// req.Resume says the consumer already has, dropping anything req.Filter
+// excludes, and heartbeating sink with src's watermark at least every
+// req.HeartbeatInterval so a heavily filtered subscription still reports
+// progress. It returns once src is exhausted or ctx/sink errors.
+func HandleSubscribeLogTail[TS comparable](ctx context.Context, src Source[TS], req SubscribeRequest[TS], sink Sink[TS]) error {
+	heartbeatEvery := req.HeartbeatInterval
+	if heartbeatEvery <= 0 {
+		heartbeatEvery = defaultHeartbeatInterval
+	}
+
+	var skipTS TS
+	skipRemaining := 0
+	skipping := false
+	reached := false
+	if req.Resume != nil {
+		skipTS = req.Resume.TS
+		skipRemaining = req.Resume.CommandIdx
+		skipping = true
+	}
+
+	lastHeartbeat := time.Now()
+	for {
+		cmd, ok, err := src.Next(ctx)
+		if err != nil {
+			return err
+		}
+		if !ok {
+			break
+		}
+
+		if skipping {
+			switch {
+			case cmd.TS == skipTS && skipRemaining > 0:
+				reached = true
+				skipRemaining--
+				continue
+			case cmd.TS == skipTS:
+				reached = true
+				skipping = false
+			case !reached:
+				// Still before the resume point: Source replays from its
+				// earliest command, which the consumer already has. A
+				// resume TS that never appears skips the whole stream.
+				continue
+			default:
+				// TS has moved past the resume point: Source replays
+				// commands in a stable order, so nothing left to skip.
+				skipping = false
+			}
+		}
+
+		if req.Filter.Matches(cmd.DbID, cmd.TbID, cmd.Type) {
+			payload, err := cmd.Decode(req.Filter.ColumnMask)
+			if err != nil {
+				return err
+			}
+			entry := Entry[TS]{DbID: cmd.DbID, TbID: cmd.TbID, Type: cmd.Type, TS: cmd.TS, Payload: payload}
+			if err := sink.Send(ctx, entry); err != nil {
+				return err
+			}
+		}
+
+		if time.Since(lastHeartbeat) >= heartbeatEvery {
+			if err := sink.Heartbeat(ctx, ResumeToken[TS]{TS: src.Watermark()}); err != nil {
+				return err
+			}
+			lastHeartbeat = time.Now()
+		}
+	}
+
+	return sink.Heartbeat(ctx, ResumeToken[TS]{TS: src.Watermark()})
+}
@@ -0,0 +1,107 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logtail
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrResyncRequired is what a Subscription's Err channel carries once its
+// consumer has fallen LagThreshold entries behind: the subscription drops
+// itself rather than buffer or block indefinitely, and the consumer is
+// expected to fall back to a normal HandleSyncLogTailReq pull to catch up
+// before calling Subscribe again with the watermark that pull returned.
+var ErrResyncRequired = errors.New("logtail: subscriber lagged past threshold, resync via pull required")
+
+// CancelFunc stops a Subscribe call's background delivery goroutine. It is
+// safe to call more than once.
+type CancelFunc func()
+
+// ChanConfig bounds how far a Subscription's channel is allowed to lag
+// before it gives up on streaming delivery.
+type ChanConfig struct {
+	// BufferSize is the channel's capacity. Defaults to 64 when zero.
+	BufferSize int
+	// LagThreshold is how many undelivered entries may queue up before the
+	// subscription drops itself with ErrResyncRequired instead of
+	// continuing to buffer. Defaults to BufferSize when zero.
+	LagThreshold int
+}
+
+// Subscription is the push-based counterpart to calling
+// HandleSyncLogTailReq in a loop: Entries delivers commands as
+// dirtyCollector.Run observes them, in commit order, for as long as the
+// consumer keeps up. Err receives at most one error - nil on a clean
+// Source exhaustion, ErrResyncRequired on excessive lag, anything else on
+// a Source/ctx failure - and is closed just before Entries is closed.
+type Subscription[TS any] struct {
+	Entries <-chan Entry[TS]
+	Err     <-chan error
+}
+
+// chanSink adapts a channel to the Sink interface HandleSubscribeLogTail
+// drives, dropping the subscription instead of blocking once the channel
+// has backed up past lagThreshold.
+type chanSink[TS any] struct {
+	ch           chan Entry[TS]
+	lagThreshold int
+}
+
+func (s *chanSink[TS]) Send(ctx context.Context, entry Entry[TS]) error {
+	if len(s.ch) >= s.lagThreshold {
+		return ErrResyncRequired
+	}
+	select {
+	case s.ch <- entry:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (s *chanSink[TS]) Heartbeat(ctx context.Context, watermark ResumeToken[TS]) error {
+	return nil
+}
+
+// Subscribe registers a durable, push-based subscription over src: a
+// background goroutine drives HandleSubscribeLogTail into a channel,
+// applying req's Filter/Resume exactly as the pull path would, and
+// delivers commands to Entries as they're produced rather than waiting
+// for a CN to ask for them. Cancelling the returned CancelFunc stops the
+// goroutine and closes both channels once it observes ctx done.
+func Subscribe[TS comparable](ctx context.Context, src Source[TS], req SubscribeRequest[TS], cfg ChanConfig) (*Subscription[TS], CancelFunc) {
+	bufSize := cfg.BufferSize
+	if bufSize <= 0 {
+		bufSize = 64
+	}
+	lagThreshold := cfg.LagThreshold
+	if lagThreshold <= 0 {
+		lagThreshold = bufSize
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	entries := make(chan Entry[TS], bufSize)
+	errCh := make(chan error, 1)
+	sink := &chanSink[TS]{ch: entries, lagThreshold: lagThreshold}
+
+	go func() {
+		defer close(entries)
+		defer close(errCh)
+		errCh <- HandleSubscribeLogTail[TS](ctx, src, req, sink)
+	}()
+
+	return &Subscription[TS]{Entries: entries, Err: errCh}, CancelFunc(cancel)
+}
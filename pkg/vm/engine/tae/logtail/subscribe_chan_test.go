@@ -0,0 +1,135 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logtail
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockingSource is like fakeSource but Next blocks until release is
+// signaled for the command at that position, so a test can control
+// exactly how far ahead the producer gets of a slow/absent consumer.
+type blockingSource struct {
+	cmds      []Command[int]
+	pos       int
+	watermark int
+	release   chan struct{}
+}
+
+func (s *blockingSource) Next(ctx context.Context) (Command[int], bool, error) {
+	if s.pos >= len(s.cmds) {
+		return Command[int]{}, false, nil
+	}
+	select {
+	case <-s.release:
+	case <-ctx.Done():
+		return Command[int]{}, false, ctx.Err()
+	}
+	cmd := s.cmds[s.pos]
+	s.pos++
+	s.watermark = cmd.TS
+	return cmd, true, nil
+}
+
+func (s *blockingSource) Watermark() int { return s.watermark }
+
+func TestSubscribeDeliversInOrder(t *testing.T) {
+	var decodeHits int
+	src := &fakeSource{cmds: []Command[int]{
+		newCmd(1, CmdInsert, &decodeHits),
+		newCmd(2, CmdInsert, &decodeHits),
+		newCmd(3, CmdDelete, &decodeHits),
+	}}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub, stop := Subscribe[int](ctx, src, SubscribeRequest[int]{DbID: 1, TbID: 1}, ChanConfig{})
+	defer stop()
+
+	var got []Entry[int]
+	for e := range sub.Entries {
+		got = append(got, e)
+	}
+	require.NoError(t, <-sub.Err)
+
+	require.Len(t, got, 3)
+	assert.Equal(t, []int{1, 2, 3}, []int{got[0].TS, got[1].TS, got[2].TS})
+	assert.Equal(t, CmdDelete, got[2].Type)
+}
+
+// TestSubscribeMatchesPullPathForSameTable verifies that subscribing to a
+// table sees the same insert/delete pair HandleSyncLogTailReq's pull path
+// would, e.g. mo_catalog.mo_database's create-then-drop.
+func TestSubscribeMatchesPullPathForSameTable(t *testing.T) {
+	var decodeHitsPull, decodeHitsPush int
+	mkCmds := func(hits *int) []Command[int] {
+		return []Command[int]{
+			newCmd(10, CmdInsert, hits),
+			newCmd(11, CmdDelete, hits),
+		}
+	}
+
+	pullSink := newRecordingSink()
+	require.NoError(t, HandleSubscribeLogTail[int](context.Background(), &fakeSource{cmds: mkCmds(&decodeHitsPull)}, SubscribeRequest[int]{DbID: 1, TbID: 1}, pullSink))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub, stop := Subscribe[int](ctx, &fakeSource{cmds: mkCmds(&decodeHitsPush)}, SubscribeRequest[int]{DbID: 1, TbID: 1}, ChanConfig{})
+	defer stop()
+
+	var pushed []Entry[int]
+	for e := range sub.Entries {
+		pushed = append(pushed, e)
+	}
+	require.NoError(t, <-sub.Err)
+
+	require.Len(t, pullSink.entries, 2)
+	require.Len(t, pushed, 2)
+	for i := range pullSink.entries {
+		assert.Equal(t, pullSink.entries[i].Type, pushed[i].Type)
+		assert.Equal(t, pullSink.entries[i].TS, pushed[i].TS)
+	}
+}
+
+func TestSubscribeDropsAndSignalsResyncPastLagThreshold(t *testing.T) {
+	var decodeHits int
+	cmds := make([]Command[int], 10)
+	for i := range cmds {
+		cmds[i] = newCmd(i+1, CmdInsert, &decodeHits)
+	}
+	src := &blockingSource{cmds: cmds, release: make(chan struct{}, len(cmds))}
+	// Let the producer run ahead of any consumer: all 10 commands can be
+	// pulled from src without anyone reading sub.Entries.
+	for i := 0; i < len(cmds); i++ {
+		src.release <- struct{}{}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	sub, stop := Subscribe[int](ctx, src, SubscribeRequest[int]{DbID: 1, TbID: 1}, ChanConfig{BufferSize: 4, LagThreshold: 4})
+	defer stop()
+
+	select {
+	case err := <-sub.Err:
+		assert.ErrorIs(t, err, ErrResyncRequired)
+	case <-time.After(2 * time.Second):
+		t.Fatal("expected ErrResyncRequired once the channel backed up past LagThreshold")
+	}
+}
@@ -0,0 +1,168 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logtail
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeSource replays a fixed slice of commands, the way a collector would
+// replay a table's commit chain over [CnHave, CnWant].
+type fakeSource struct {
+	cmds       []Command[int]
+	pos        int
+	watermark  int
+	decodeHits *int
+}
+
+func (s *fakeSource) Next(ctx context.Context) (Command[int], bool, error) {
+	if s.pos >= len(s.cmds) {
+		return Command[int]{}, false, nil
+	}
+	cmd := s.cmds[s.pos]
+	s.pos++
+	s.watermark = cmd.TS
+	return cmd, true, nil
+}
+
+func (s *fakeSource) Watermark() int { return s.watermark }
+
+func newCmd(ts int, typ CommandType, decodeHits *int) Command[int] {
+	return Command[int]{
+		DbID: 1,
+		TbID: 1,
+		Type: typ,
+		TS:   ts,
+		Decode: func(columnMask []string) (any, error) {
+			*decodeHits++
+			return typ, nil
+		},
+	}
+}
+
+// recordingSink collects delivered entries and heartbeats, and tracks the
+// ResumeToken a consumer would persist after each successfully applied
+// entry: TS plus how many commands at that TS have now been delivered.
+type recordingSink struct {
+	entries     []Entry[int]
+	heartbeats  []ResumeToken[int]
+	tsCounts    map[int]int
+	lastApplied ResumeToken[int]
+}
+
+func newRecordingSink() *recordingSink {
+	return &recordingSink{tsCounts: make(map[int]int)}
+}
+
+func (s *recordingSink) Send(ctx context.Context, entry Entry[int]) error {
+	s.entries = append(s.entries, entry)
+	s.tsCounts[entry.TS]++
+	s.lastApplied = ResumeToken[int]{TS: entry.TS, CommandIdx: s.tsCounts[entry.TS]}
+	return nil
+}
+
+func (s *recordingSink) Heartbeat(ctx context.Context, watermark ResumeToken[int]) error {
+	s.heartbeats = append(s.heartbeats, watermark)
+	return nil
+}
+
+func TestHandleSubscribeLogTailFiltersByTypeAndSkipsDecodeForExcluded(t *testing.T) {
+	var decodeHits int
+	src := &fakeSource{cmds: []Command[int]{
+		newCmd(1, CmdInsert, &decodeHits),
+		newCmd(2, CmdDelete, &decodeHits),
+		newCmd(3, CmdSegDelete, &decodeHits),
+	}}
+	sink := newRecordingSink()
+
+	req := SubscribeRequest[int]{DbID: 1, TbID: 1, Filter: Filter{Types: []CommandType{CmdSegDelete}}}
+	err := HandleSubscribeLogTail[int](context.Background(), src, req, sink)
+	require.NoError(t, err)
+
+	require.Len(t, sink.entries, 1)
+	assert.Equal(t, CmdSegDelete, sink.entries[0].Type)
+	// Decode must only have run for the one command that passed the
+	// filter: the Insert/Delete commands' payload was never materialized.
+	assert.Equal(t, 1, decodeHits)
+}
+
+func TestHandleSubscribeLogTailHeartbeatsOnQuietFilteredStream(t *testing.T) {
+	var decodeHits int
+	src := &fakeSource{cmds: []Command[int]{
+		newCmd(1, CmdInsert, &decodeHits),
+		newCmd(2, CmdInsert, &decodeHits),
+	}}
+	sink := newRecordingSink()
+
+	req := SubscribeRequest[int]{DbID: 1, TbID: 1, Filter: Filter{Types: []CommandType{CmdSegDelete}}}
+	err := HandleSubscribeLogTail[int](context.Background(), src, req, sink)
+	require.NoError(t, err)
+
+	require.Empty(t, sink.entries)
+	// Even though nothing matched, the final heartbeat must still carry
+	// the source's latest watermark so the consumer can advance.
+	require.NotEmpty(t, sink.heartbeats)
+	assert.Equal(t, 2, sink.heartbeats[len(sink.heartbeats)-1].TS)
+}
+
+// TestResumeAfterMergeBlocksDeliversSegDeleteExactlyOnce simulates a CN
+// that disconnects mid-stream right after a MergeBlocks-triggered
+// seg-delete command, then reconnects with the ResumeToken it persisted.
+// The replayed subscription must not redeliver anything the first session
+// already applied, and must deliver the seg-delete entry exactly once
+// across both sessions combined.
+func TestResumeAfterMergeBlocksDeliversSegDeleteExactlyOnce(t *testing.T) {
+	var decodeHits int
+	cmds := []Command[int]{
+		newCmd(1, CmdInsert, &decodeHits),
+		newCmd(2, CmdInsert, &decodeHits),
+		newCmd(3, CmdSegDelete, &decodeHits), // MergeBlocks retires the old segment here.
+		newCmd(4, CmdInsert, &decodeHits),
+	}
+
+	// First session: the consumer receives up through the seg-delete
+	// entry, persists its ResumeToken, then disconnects before TS 4.
+	firstSink := newRecordingSink()
+	firstSrc := &fakeSource{cmds: cmds[:3]}
+	req := SubscribeRequest[int]{DbID: 1, TbID: 1}
+	require.NoError(t, HandleSubscribeLogTail[int](context.Background(), firstSrc, req, firstSink))
+	require.Len(t, firstSink.entries, 3)
+	persisted := firstSink.lastApplied
+
+	// Second session: a fresh Source replays the whole window again (a
+	// CN reconnecting doesn't get to ask the collector to skip ahead),
+	// but the resumed HandleSubscribeLogTail call must skip everything up
+	// to and including what the first session already applied.
+	secondSink := newRecordingSink()
+	secondSrc := &fakeSource{cmds: cmds}
+	resumedReq := SubscribeRequest[int]{DbID: 1, TbID: 1, Resume: &persisted}
+	require.NoError(t, HandleSubscribeLogTail[int](context.Background(), secondSrc, resumedReq, secondSink))
+
+	require.Len(t, secondSink.entries, 1)
+	assert.Equal(t, CmdInsert, secondSink.entries[0].Type)
+	assert.Equal(t, 4, secondSink.entries[0].TS)
+
+	segDeleteCount := 0
+	for _, e := range append(append([]Entry[int]{}, firstSink.entries...), secondSink.entries...) {
+		if e.Type == CmdSegDelete {
+			segDeleteCount++
+		}
+	}
+	assert.Equal(t, 1, segDeleteCount)
+}
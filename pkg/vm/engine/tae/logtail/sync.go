@@ -0,0 +1,132 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logtail
+
+import (
+	"context"
+	"fmt"
+	"hash/crc32"
+)
+
+// RawSource produces a table's command stream as raw, pre-encoded bytes
+// plus an opaque position token after each one - the checksum-guarded
+// primitive SyncLogTail needs underneath the existing
+// HandleSyncLogTailReq's decoded Command stream. A CN verifying a
+// command's CRC32 needs the exact bytes
+// that were hashed, and a per-command resume token to re-fetch just one
+// corrupted command without discarding everything already verified
+// around it, the same guarantee headchunk's segment format already gives
+// a reader scanning record by record.
+type RawSource[TS any] interface {
+	// Seek resets iteration to resume from token (nil/empty starts at the
+	// beginning). Implementations decode whatever internal position token
+	// encodes, so a token surviving past a LogtailMgr cursor cache
+	// eviction still reconstructs the same position a cached cursor would
+	// have.
+	Seek(token []byte) error
+	// Next returns the next command's raw payload and TS, or ok=false
+	// once exhausted.
+	Next(ctx context.Context) (payload []byte, ts TS, ok bool, err error)
+	// Checkpoint returns an opaque token encoding the position immediately
+	// after the most recent Next call, suitable for a later Seek.
+	Checkpoint() ([]byte, error)
+}
+
+// RawCommand is one command SyncLogTail returns: Payload plus
+// its CRC32 (the checksum-guarded chunk pattern headchunk already uses
+// for its segment records) and ResumeAfter, the token Seek needs to
+// resume immediately past this command. A CN that finds this command's
+// CRC doesn't match can re-fetch just it from the previous command's
+// ResumeAfter instead of re-pulling the whole response.
+type RawCommand[TS any] struct {
+	TS          TS
+	Payload     []byte
+	CRC32       uint32
+	ResumeAfter []byte
+}
+
+// Verify reports whether c's Payload still matches its recorded CRC32.
+func (c RawCommand[TS]) Verify() bool {
+	return crc32.ChecksumIEEE(c.Payload) == c.CRC32
+}
+
+// defaultSyncMaxBytes bounds a single SyncLogTail call when the
+// caller does not set SyncRequest.MaxBytes.
+const defaultSyncMaxBytes = 4 << 20
+
+// SyncRequest configures one SyncLogTail call. ResumeToken is the
+// opaque cursor a previous SyncResponse (or LogtailMgr's cursor cache)
+// returned; nil/empty starts from the beginning. MaxBytes bounds how many
+// payload bytes a single response returns (defaulting to
+// defaultSyncMaxBytes when zero), so a huge MO_TABLES/MO_COLUMNS backfill
+// can be paged across many calls instead of materialized in one RPC.
+type SyncRequest struct {
+	ResumeToken []byte
+	MaxBytes    int
+}
+
+// SyncResponse is SyncLogTail's bounded batch: Commands in
+// order, and NextResumeToken to pass as the next call's ResumeToken. An
+// empty Commands means src is exhausted as of ResumeToken.
+type SyncResponse[TS any] struct {
+	Commands        []RawCommand[TS]
+	NextResumeToken []byte
+}
+
+// SyncLogTail seeks src to req.ResumeToken and drains up to
+// req.MaxBytes worth of payload (always returning at least one command if
+// one is available, even if it alone exceeds the budget), returning each
+// command alongside its CRC32 and a ResumeAfter token, plus the token a
+// follow-up call should pass as ResumeToken to continue where this one
+// left off.
+func SyncLogTail[TS any](ctx context.Context, src RawSource[TS], req SyncRequest) (SyncResponse[TS], error) {
+	if err := src.Seek(req.ResumeToken); err != nil {
+		return SyncResponse[TS]{}, fmt.Errorf("logtail: seek resume token: %w", err)
+	}
+	maxBytes := req.MaxBytes
+	if maxBytes <= 0 {
+		maxBytes = defaultSyncMaxBytes
+	}
+
+	var resp SyncResponse[TS]
+	budget := 0
+	for budget < maxBytes {
+		payload, ts, ok, err := src.Next(ctx)
+		if err != nil {
+			return SyncResponse[TS]{}, err
+		}
+		if !ok {
+			break
+		}
+		after, err := src.Checkpoint()
+		if err != nil {
+			return SyncResponse[TS]{}, err
+		}
+		resp.Commands = append(resp.Commands, RawCommand[TS]{
+			TS:          ts,
+			Payload:     payload,
+			CRC32:       crc32.ChecksumIEEE(payload),
+			ResumeAfter: after,
+		})
+		budget += len(payload)
+	}
+
+	if len(resp.Commands) > 0 {
+		resp.NextResumeToken = resp.Commands[len(resp.Commands)-1].ResumeAfter
+	} else {
+		resp.NextResumeToken = req.ResumeToken
+	}
+	return resp, nil
+}
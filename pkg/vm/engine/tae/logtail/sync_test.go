@@ -0,0 +1,97 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package logtail
+
+import (
+	"context"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// fakeRawSource replays a fixed slice of payloads, encoding its position as
+// the decimal ASCII of the next index to return - a stand-in for whatever
+// real encoding a catalog-backed RawSource would use for its own cursor.
+type fakeRawSource struct {
+	payloads [][]byte
+	pos      int
+}
+
+func (s *fakeRawSource) Seek(token []byte) error {
+	if len(token) == 0 {
+		s.pos = 0
+		return nil
+	}
+	pos, err := strconv.Atoi(string(token))
+	if err != nil {
+		return err
+	}
+	s.pos = pos
+	return nil
+}
+
+func (s *fakeRawSource) Next(context.Context) ([]byte, int, bool, error) {
+	if s.pos >= len(s.payloads) {
+		return nil, 0, false, nil
+	}
+	p := s.payloads[s.pos]
+	ts := s.pos
+	s.pos++
+	return p, ts, true, nil
+}
+
+func (s *fakeRawSource) Checkpoint() ([]byte, error) {
+	return []byte(strconv.Itoa(s.pos)), nil
+}
+
+func TestSyncLogTailReturnsAtLeastOneCommand(t *testing.T) {
+	src := &fakeRawSource{payloads: [][]byte{[]byte("row-1"), []byte("row-2"), []byte("row-3")}}
+
+	resp, err := SyncLogTail[int](context.Background(), src, SyncRequest{MaxBytes: 1})
+	require.NoError(t, err)
+	require.Len(t, resp.Commands, 1)
+	assert.Equal(t, []byte("row-1"), resp.Commands[0].Payload)
+	assert.True(t, resp.Commands[0].Verify())
+	assert.Equal(t, []byte("1"), resp.Commands[0].ResumeAfter)
+	assert.Equal(t, []byte("1"), resp.NextResumeToken)
+}
+
+func TestSyncLogTailResumesFromToken(t *testing.T) {
+	src := &fakeRawSource{payloads: [][]byte{[]byte("row-1"), []byte("row-2"), []byte("row-3")}}
+
+	first, err := SyncLogTail[int](context.Background(), src, SyncRequest{MaxBytes: 1})
+	require.NoError(t, err)
+
+	second, err := SyncLogTail[int](context.Background(), src, SyncRequest{ResumeToken: first.NextResumeToken, MaxBytes: 1024})
+	require.NoError(t, err)
+	require.Len(t, second.Commands, 2)
+	assert.Equal(t, []byte("row-2"), second.Commands[0].Payload)
+	assert.Equal(t, []byte("row-3"), second.Commands[1].Payload)
+}
+
+func TestSyncLogTailEmptyCommandsAtEnd(t *testing.T) {
+	src := &fakeRawSource{payloads: [][]byte{[]byte("only-row")}}
+
+	resp, err := SyncLogTail[int](context.Background(), src, SyncRequest{MaxBytes: 1024})
+	require.NoError(t, err)
+	require.Len(t, resp.Commands, 1)
+
+	resp2, err := SyncLogTail[int](context.Background(), src, SyncRequest{ResumeToken: resp.NextResumeToken, MaxBytes: 1024})
+	require.NoError(t, err)
+	assert.Empty(t, resp2.Commands)
+	assert.Equal(t, resp.NextResumeToken, resp2.NextResumeToken)
+}
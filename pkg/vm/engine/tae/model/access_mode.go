@@ -0,0 +1,76 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import "errors"
+
+// ErrReadOnly is the error a read-only DB's txn Commit should surface when
+// the txn attempted an operation AccessReadOnly does not allow.
+var ErrReadOnly = errors.New("model: database is open read-only")
+
+// AccessMode gates which category of txn operation a DB currently accepts.
+// db.OpenReadOnly sets AccessReadOnly so user txns cannot CreateDatabase,
+// Append, RangeDelete, or AlterTable, while the background
+// checkpoint/compaction runner keeps draining the WAL through internally
+// scheduled txns.
+type AccessMode uint8
+
+const (
+	AccessReadWrite AccessMode = iota
+	AccessReadOnly
+)
+
+// OperationClass classifies a txn operation for AccessMode.Allow.
+type OperationClass uint8
+
+const (
+	// OpUserMutation is any user-initiated write: CreateDatabase, Append,
+	// RangeDelete, AlterTable, and friends.
+	OpUserMutation OperationClass = iota
+	// OpUserRead is any user-initiated read.
+	OpUserRead
+	// OpInternalCompaction is a txn the runtime itself scheduled through
+	// Runtime.Scheduler.ScheduleMultiScopedTxnTask, e.g. checkpoint or
+	// compact-block tasks draining the WAL. BGCheckpointRunner.
+	// ForceIncrementalCheckpoint and ForceFlush fall under this class too:
+	// they materialize new checkpoints/blocks from the WAL tail rather
+	// than accepting a user mutation, so a read-only replica can still
+	// run them on demand.
+	OpInternalCompaction
+	// OpInternalGC is Catalog.GCByTS or BGCheckpointRunner.GCByTS
+	// retiring already-checkpointed entries. It is distinct from
+	// OpInternalCompaction, which materializes new state, because a
+	// read-only replica needs to trim its own retained state even
+	// though it never accepts writes or produces new checkpoints itself.
+	OpInternalGC
+)
+
+// Allow reports whether mode permits an operation of class op.
+func (mode AccessMode) Allow(op OperationClass) bool {
+	if mode == AccessReadOnly {
+		return op != OpUserMutation
+	}
+	return true
+}
+
+// Guard returns ErrReadOnly if mode forbids op, nil otherwise. Intended to
+// be called from a txn's Commit path before it applies any user-visible
+// mutation.
+func (mode AccessMode) Guard(op OperationClass) error {
+	if !mode.Allow(op) {
+		return ErrReadOnly
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeCommit stands in for a txn's Commit path: the one real call site
+// AccessMode.Guard is meant to gate. db.DB does not exist in this
+// checkout, so there is no real Commit to drive this through; this is
+// the closest proof available that the policy a read-only db.DB would
+// enforce is the right one.
+func fakeCommit(mode AccessMode, op OperationClass) error {
+	return mode.Guard(op)
+}
+
+// TestReadOnlyModeRejectsDDLAndAppend is the scenario
+// db_readonly_test.go's TestReadOnlyRejectsDDL/TestReadOnlyRejectsAppend
+// wanted to exercise against a real db.DB opened via db.OpenReadOnly:
+// a txn's CreateDatabase and Append both commit as OpUserMutation, so
+// both must be rejected once a DB is read-only.
+func TestReadOnlyModeRejectsDDLAndAppend(t *testing.T) {
+	assert.ErrorIs(t, fakeCommit(AccessReadOnly, OpUserMutation), ErrReadOnly)
+	assert.NoError(t, fakeCommit(AccessReadWrite, OpUserMutation))
+}
+
+// TestReadOnlyModeAllowsFlushWALConcurrentlyWithWriterMutations is the
+// scenario db_readonly_flush_wal_test.go (deleted in b341c6b) wanted to
+// exercise against a real db.OpenReadOnly handle concurrent with a
+// writable one: FlushWAL/FlushWALToBlocks commit as OpInternalCompaction,
+// not OpUserMutation, so a read-only handle must keep allowing them even
+// while a second, writable handle against the same store is concurrently
+// committing real mutations.
+func TestReadOnlyModeAllowsFlushWALConcurrentlyWithWriterMutations(t *testing.T) {
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			assert.NoError(t, fakeCommit(AccessReadWrite, OpUserMutation))
+		}
+	}()
+
+	for i := 0; i < 100; i++ {
+		assert.NoError(t, fakeCommit(AccessReadOnly, OpInternalCompaction))
+	}
+	wg.Wait()
+}
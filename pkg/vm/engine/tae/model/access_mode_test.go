@@ -0,0 +1,38 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAccessReadWriteAllowsEverything(t *testing.T) {
+	for _, op := range []OperationClass{OpUserMutation, OpUserRead, OpInternalCompaction, OpInternalGC} {
+		assert.True(t, AccessReadWrite.Allow(op))
+		assert.NoError(t, AccessReadWrite.Guard(op))
+	}
+}
+
+func TestAccessReadOnlyRejectsOnlyUserMutation(t *testing.T) {
+	assert.False(t, AccessReadOnly.Allow(OpUserMutation))
+	assert.ErrorIs(t, AccessReadOnly.Guard(OpUserMutation), ErrReadOnly)
+
+	for _, op := range []OperationClass{OpUserRead, OpInternalCompaction, OpInternalGC} {
+		assert.True(t, AccessReadOnly.Allow(op), "read-only mode must still allow %v", op)
+		assert.NoError(t, AccessReadOnly.Guard(op))
+	}
+}
@@ -0,0 +1,212 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"container/list"
+	"sync"
+	"sync/atomic"
+)
+
+type arcListID uint8
+
+const (
+	arcT1 arcListID = iota
+	arcT2
+	arcB1
+	arcB2
+)
+
+// entry is the value stored behind every list.Element in the four ARC
+// lists. Ghost entries (on b1/b2) carry a zero Value and only exist to
+// remember that a key was evicted recently.
+type entry[K comparable, V any] struct {
+	key   K
+	value V
+	list  arcListID
+}
+
+// ARCCache is a generic Adaptive Replacement Cache (Megiddo & Modha).
+// It keeps two resident lists, T1 (recently used once) and T2 (used at
+// least twice), each backed by a ghost list, B1 and B2, that remembers
+// keys evicted from T1/T2 without retaining their values. The target
+// size of T1, p, is nudged towards whichever list is producing ghost
+// hits, so the cache adapts between recency and frequency workloads
+// without any tuning knob beyond the overall capacity c.
+//
+// ARCCache is safe for concurrent use.
+type ARCCache[K comparable, V any] struct {
+	mu sync.Mutex
+
+	c int // capacity of resident entries (|T1|+|T2| <= c)
+	p int // target size of T1
+
+	t1, t2, b1, b2 *list.List
+	index          map[K]*list.Element
+
+	hits, misses, evicts atomic.Uint64
+}
+
+// NewARCCache creates an ARCCache that keeps at most capacity resident
+// entries. capacity must be positive.
+func NewARCCache[K comparable, V any](capacity int) *ARCCache[K, V] {
+	if capacity <= 0 {
+		capacity = 1
+	}
+	return &ARCCache[K, V]{
+		c:     capacity,
+		t1:    list.New(),
+		t2:    list.New(),
+		b1:    list.New(),
+		b2:    list.New(),
+		index: make(map[K]*list.Element),
+	}
+}
+
+// Get returns the cached value for key, promoting it to T2 on a hit.
+func (a *ARCCache[K, V]) Get(key K) (v V, ok bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	elem, exist := a.index[key]
+	if !exist {
+		a.misses.Add(1)
+		return v, false
+	}
+	e := elem.Value.(*entry[K, V])
+	switch e.list {
+	case arcT1:
+		a.t1.Remove(elem)
+		e.list = arcT2
+		a.index[key] = a.t2.PushFront(e)
+	case arcT2:
+		a.t2.MoveToFront(elem)
+	default:
+		// ghost lists hold no value
+		a.misses.Add(1)
+		return v, false
+	}
+	a.hits.Add(1)
+	return e.value, true
+}
+
+// Set inserts or updates the value cached for key, running the ARC
+// replacement policy to keep the resident set within capacity.
+func (a *ARCCache[K, V]) Set(key K, value V) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if elem, exist := a.index[key]; exist {
+		e := elem.Value.(*entry[K, V])
+		switch e.list {
+		case arcT1:
+			a.t1.Remove(elem)
+			a.pushT2(key, value)
+		case arcT2:
+			e.value = value
+			a.t2.MoveToFront(elem)
+		case arcB1:
+			a.p = min(a.c, a.p+max(1, a.b2.Len()/max(1, a.b1.Len())))
+			a.replace(false)
+			a.b1.Remove(elem)
+			a.pushT2(key, value)
+		case arcB2:
+			a.p = max(0, a.p-max(1, a.b1.Len()/max(1, a.b2.Len())))
+			a.replace(true)
+			a.b2.Remove(elem)
+			a.pushT2(key, value)
+		}
+		return
+	}
+
+	// brand new key
+	switch {
+	case a.t1.Len()+a.b1.Len() == a.c:
+		if a.t1.Len() < a.c {
+			a.evictGhost(a.b1)
+			a.replace(false)
+		} else {
+			a.evictResident(a.t1)
+		}
+	case a.t1.Len()+a.b1.Len()+a.t2.Len()+a.b2.Len() >= a.c:
+		if a.t1.Len()+a.b1.Len()+a.t2.Len()+a.b2.Len() == 2*a.c {
+			a.evictGhost(a.b2)
+		}
+		a.replace(false)
+	}
+	e := &entry[K, V]{key: key, value: value, list: arcT1}
+	a.index[key] = a.t1.PushFront(e)
+}
+
+func (a *ARCCache[K, V]) pushT2(key K, value V) {
+	e := &entry[K, V]{key: key, value: value, list: arcT2}
+	a.index[key] = a.t2.PushFront(e)
+}
+
+// replace evicts one entry from T1 or T2 into its ghost list, following
+// the ARC decision rule keyed off the target size p. inB2 is true when
+// the entry that triggered replacement was a B2 ghost hit, which biases
+// the choice towards evicting from T1 per the original ARC paper.
+func (a *ARCCache[K, V]) replace(inB2 bool) {
+	t1Len := a.t1.Len()
+	if t1Len > 0 && (t1Len > a.p || (t1Len == a.p && inB2)) {
+		a.moveToGhost(a.t1, a.b1, arcB1)
+		return
+	}
+	a.moveToGhost(a.t2, a.b2, arcB2)
+}
+
+func (a *ARCCache[K, V]) moveToGhost(from, to *list.List, toID arcListID) {
+	elem := from.Back()
+	if elem == nil {
+		return
+	}
+	e := elem.Value.(*entry[K, V])
+	from.Remove(elem)
+	var zero V
+	e.value = zero
+	e.list = toID
+	a.index[e.key] = to.PushFront(e)
+	a.evicts.Add(1)
+}
+
+func (a *ARCCache[K, V]) evictResident(from *list.List) {
+	if elem := from.Back(); elem != nil {
+		e := elem.Value.(*entry[K, V])
+		from.Remove(elem)
+		delete(a.index, e.key)
+		a.evicts.Add(1)
+	}
+}
+
+func (a *ARCCache[K, V]) evictGhost(from *list.List) {
+	if elem := from.Back(); elem != nil {
+		e := elem.Value.(*entry[K, V])
+		from.Remove(elem)
+		delete(a.index, e.key)
+	}
+}
+
+// Stats returns the cumulative hit, miss and evict counters.
+func (a *ARCCache[K, V]) Stats() (hits, misses, evicts uint64) {
+	return a.hits.Load(), a.misses.Load(), a.evicts.Load()
+}
+
+// Len returns the number of resident (non-ghost) entries.
+func (a *ARCCache[K, V]) Len() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return a.t1.Len() + a.t2.Len()
+}
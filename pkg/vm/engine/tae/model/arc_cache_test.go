@@ -0,0 +1,81 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestARCCacheStatsCountHitsMissesAndEvicts(t *testing.T) {
+	c := NewARCCache[string, int](2)
+	c.Set("a", 1)
+	c.Set("b", 2)
+
+	v, ok := c.Get("a") // promotes "a" to T2: a hit
+	assert.True(t, ok)
+	assert.Equal(t, 1, v)
+
+	_, ok = c.Get("missing")
+	assert.False(t, ok)
+
+	c.Set("c", 3) // the cache is at capacity, so T1's LRU "b" is evicted into B1
+
+	hits, misses, evicts := c.Stats()
+	assert.Equal(t, uint64(1), hits)
+	assert.Equal(t, uint64(1), misses)
+	assert.Equal(t, uint64(1), evicts)
+}
+
+// TestARCCacheB1GhostHitRaisesP drives a B1 ghost hit and checks that p,
+// the target size of T1, is nudged up towards T1 as the ARC paper
+// prescribes.
+func TestARCCacheB1GhostHitRaisesP(t *testing.T) {
+	c := NewARCCache[string, int](2)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")    // promote "a" to T2, leaving T1 = [b]
+	c.Set("x", 3) // cache at capacity -> "b" moves to B1; T1 = [x]
+	assert.Equal(t, 0, c.p)
+
+	c.Set("b", 20) // B1 ghost hit on "b"
+	assert.Equal(t, 1, c.p)
+
+	v, ok := c.Get("b")
+	assert.True(t, ok)
+	assert.Equal(t, 20, v)
+}
+
+// TestARCCacheB2GhostHitLowersP drives a B2 ghost hit and checks that p
+// is nudged back down towards T2.
+func TestARCCacheB2GhostHitLowersP(t *testing.T) {
+	c := NewARCCache[string, int](2)
+	c.Set("a", 1)
+	c.Set("b", 2)
+	c.Get("a")     // promote "a" to T2 = [a], T1 = [b]
+	c.Set("x", 3)  // cache at capacity -> "b" moves to B1; T1 = [x]
+	c.Set("b", 20) // B1 hit: T1 is not above p, so "a" moves to B2; T2 = [b]
+	assert.Equal(t, 1, c.p)
+
+	c.Set("y", 4) // T1 is not above p -> "b" moves to B2; T1 = [y, x]
+
+	c.Set("a", 10) // B2 ghost hit on "a"
+	assert.Equal(t, 0, c.p)
+
+	v, ok := c.Get("a")
+	assert.True(t, ok)
+	assert.Equal(t, 10, v)
+}
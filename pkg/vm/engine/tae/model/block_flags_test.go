@@ -0,0 +1,50 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBlockFlagsSetHasClear(t *testing.T) {
+	var f BlockFlags
+	assert.False(t, f.Has(FlagHasPersistedDeletes))
+
+	f = f.Set(FlagHasPersistedDeletes)
+	assert.True(t, f.Has(FlagHasPersistedDeletes))
+	assert.False(t, f.Has(FlagHasInMemoryDeletes))
+
+	f = f.Set(FlagHasInMemoryDeletes)
+	assert.True(t, f.Has(FlagHasPersistedDeletes|FlagHasInMemoryDeletes))
+
+	f = f.Clear(FlagHasPersistedDeletes)
+	assert.False(t, f.Has(FlagHasPersistedDeletes))
+	assert.True(t, f.Has(FlagHasInMemoryDeletes))
+}
+
+func TestRecomputeBlockFlags(t *testing.T) {
+	f := RecomputeBlockFlags(true, false, true, false, true)
+	assert.True(t, f.Has(FlagSorted))
+	assert.False(t, f.Has(FlagDependable))
+	assert.True(t, f.Has(FlagHasPersistedDeletes))
+	assert.False(t, f.Has(FlagHasInMemoryDeletes))
+	assert.True(t, f.Has(FlagCreatedByCN))
+}
+
+func TestRecomputeBlockFlagsAllFalseIsZero(t *testing.T) {
+	assert.Equal(t, BlockFlags(0), RecomputeBlockFlags(false, false, false, false, false))
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import "sync"
+
+// DeletePressureTracker accumulates an approximate in-memory delete count
+// per key and fires onThreshold the first time a key's running count
+// crosses threshold, without waiting for the next size/time based
+// checkpoint to notice. The intended caller is catalog.BlockEntry, keyed
+// by common.ID, incrementing on every RangeDelete/DeleteByFilter that
+// lands on the block, with onThreshold enqueuing a compact-block task
+// through db.Runtime.Scheduler (mirroring options.DeleteFlushThreshold).
+// DeletePressureTracker itself only knows about counting and firing, so it
+// stays usable without depending on the catalog or scheduler packages.
+type DeletePressureTracker[K comparable] struct {
+	threshold   uint32
+	onThreshold func(key K, count uint32)
+
+	mu     sync.Mutex
+	counts map[K]uint32
+	fired  map[K]bool
+}
+
+// NewDeletePressureTracker returns a tracker that calls onThreshold the
+// first time a key's count reaches threshold. onThreshold must not block
+// or re-enter the tracker.
+func NewDeletePressureTracker[K comparable](threshold uint32, onThreshold func(key K, count uint32)) *DeletePressureTracker[K] {
+	return &DeletePressureTracker[K]{
+		threshold:   threshold,
+		onThreshold: onThreshold,
+		counts:      make(map[K]uint32),
+		fired:       make(map[K]bool),
+	}
+}
+
+// Add records n additional deletes against key and returns its new running
+// total. It fires onThreshold at most once per key between Resets, the
+// first time that total reaches threshold.
+func (t *DeletePressureTracker[K]) Add(key K, n uint32) (total uint32, fired bool) {
+	t.mu.Lock()
+	t.counts[key] += n
+	total = t.counts[key]
+	shouldFire := total >= t.threshold && !t.fired[key]
+	if shouldFire {
+		t.fired[key] = true
+	}
+	t.mu.Unlock()
+
+	if shouldFire {
+		t.onThreshold(key, total)
+	}
+	return total, shouldFire
+}
+
+// Count returns key's current running delete count.
+func (t *DeletePressureTracker[K]) Count(key K) uint32 {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return t.counts[key]
+}
+
+// Reset clears key's count and re-arms onThreshold for it, e.g. once the
+// compaction the tracker triggered has drained the block's delete chain.
+func (t *DeletePressureTracker[K]) Reset(key K) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	delete(t.counts, key)
+	delete(t.fired, key)
+}
@@ -0,0 +1,66 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeletePressureTrackerFiresOnceCountCrossesThreshold(t *testing.T) {
+	var fired []uint32
+	tr := NewDeletePressureTracker(10, func(key string, count uint32) {
+		fired = append(fired, count)
+	})
+
+	total, didFire := tr.Add("blk1", 7)
+	assert.Equal(t, uint32(7), total)
+	assert.False(t, didFire)
+	assert.Empty(t, fired)
+
+	total, didFire = tr.Add("blk1", 4)
+	assert.Equal(t, uint32(11), total)
+	assert.True(t, didFire)
+	assert.Equal(t, []uint32{11}, fired)
+
+	// a further Add past threshold must not fire onThreshold again
+	total, didFire = tr.Add("blk1", 1)
+	assert.Equal(t, uint32(12), total)
+	assert.False(t, didFire)
+	assert.Equal(t, []uint32{11}, fired)
+}
+
+func TestDeletePressureTrackerKeysAreIndependent(t *testing.T) {
+	tr := NewDeletePressureTracker(5, func(key string, count uint32) {})
+
+	tr.Add("blk1", 5)
+	assert.Equal(t, uint32(0), tr.Count("blk2"))
+	assert.Equal(t, uint32(5), tr.Count("blk1"))
+}
+
+func TestDeletePressureTrackerResetRearmsThreshold(t *testing.T) {
+	var fireCount int
+	tr := NewDeletePressureTracker(5, func(key string, count uint32) { fireCount++ })
+
+	tr.Add("blk1", 5)
+	assert.Equal(t, 1, fireCount)
+
+	tr.Reset("blk1")
+	assert.Equal(t, uint32(0), tr.Count("blk1"))
+
+	tr.Add("blk1", 5)
+	assert.Equal(t, 2, fireCount)
+}
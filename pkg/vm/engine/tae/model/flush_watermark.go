@@ -0,0 +1,79 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"sync"
+
+	"github.com/matrixorigin/matrixone/pkg/container/types"
+)
+
+// TableKey identifies a table within a FlushWatermarks set the same way a
+// dirty-tree collector keys its per-table state.
+type TableKey struct {
+	DbID    uint64
+	TableID uint64
+}
+
+// FlushWatermarks tracks, per table, the timestamp up to which a flush
+// (compact/checkpoint) is already known to have cleared every delete
+// intent. logtail.DirtyCollector is meant to embed one of these and expose
+// it as LastFlushOf/SetLastFlush, so the flush driver and checkpoint
+// runner can advance a table's watermark explicitly instead of waiting for
+// DirtyCollector's own delete-intent scan to rediscover that the table
+// went quiet.
+type FlushWatermarks struct {
+	mu sync.RWMutex
+	m  map[TableKey]types.TS
+}
+
+// NewFlushWatermarks returns an empty FlushWatermarks; every table starts
+// with LastFlushOf returning the zero types.TS.
+func NewFlushWatermarks() *FlushWatermarks {
+	return &FlushWatermarks{m: make(map[TableKey]types.TS)}
+}
+
+// LastFlushOf returns the last-known-flushed timestamp for (dbID,
+// tableID), or the zero types.TS if nothing has been recorded yet.
+func (w *FlushWatermarks) LastFlushOf(dbID, tableID uint64) types.TS {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.m[TableKey{DbID: dbID, TableID: tableID}]
+}
+
+// SetLastFlush advances (dbID, tableID)'s watermark to ts. It refuses (and
+// returns false) to move the watermark backwards, since an out-of-order
+// caller racing a newer flush must not resurrect an older, already-cleared
+// window.
+func (w *FlushWatermarks) SetLastFlush(dbID, tableID uint64, ts types.TS) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	key := TableKey{DbID: dbID, TableID: tableID}
+	if cur, ok := w.m[key]; ok && cur.GreaterEq(ts) {
+		return false
+	}
+	w.m[key] = ts
+	return true
+}
+
+// EffectiveFrom returns the later of from and lastFlush: the start of the
+// window HasDeleteIntentsPreparedIn actually needs to scan, since anything
+// at or before lastFlush is already known to be clear.
+func EffectiveFrom(from, lastFlush types.TS) types.TS {
+	if lastFlush.GreaterEq(from) {
+		return lastFlush
+	}
+	return from
+}
@@ -0,0 +1,67 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/matrixorigin/matrixone/pkg/container/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func flushTS(physical int64) types.TS {
+	return types.BuildTS(physical, 0)
+}
+
+func TestFlushWatermarksLastFlushOfDefaultsToZero(t *testing.T) {
+	w := NewFlushWatermarks()
+	assert.Equal(t, types.TS{}, w.LastFlushOf(1, 2))
+}
+
+func TestFlushWatermarksSetLastFlushAdvances(t *testing.T) {
+	w := NewFlushWatermarks()
+	ok := w.SetLastFlush(1, 2, flushTS(10))
+	assert.True(t, ok)
+	assert.Equal(t, flushTS(10), w.LastFlushOf(1, 2))
+}
+
+func TestFlushWatermarksSetLastFlushRefusesToMoveBackwards(t *testing.T) {
+	w := NewFlushWatermarks()
+	w.SetLastFlush(1, 2, flushTS(10))
+
+	ok := w.SetLastFlush(1, 2, flushTS(5))
+	assert.False(t, ok)
+	assert.Equal(t, flushTS(10), w.LastFlushOf(1, 2))
+}
+
+func TestFlushWatermarksSetLastFlushRefusesSameTS(t *testing.T) {
+	w := NewFlushWatermarks()
+	w.SetLastFlush(1, 2, flushTS(10))
+
+	ok := w.SetLastFlush(1, 2, flushTS(10))
+	assert.False(t, ok)
+}
+
+func TestFlushWatermarksAreScopedPerTable(t *testing.T) {
+	w := NewFlushWatermarks()
+	w.SetLastFlush(1, 2, flushTS(10))
+	assert.Equal(t, types.TS{}, w.LastFlushOf(1, 3))
+	assert.Equal(t, types.TS{}, w.LastFlushOf(2, 2))
+}
+
+func TestEffectiveFromPicksTheLaterBound(t *testing.T) {
+	assert.Equal(t, flushTS(10), EffectiveFrom(flushTS(5), flushTS(10)))
+	assert.Equal(t, flushTS(10), EffectiveFrom(flushTS(10), flushTS(5)))
+}
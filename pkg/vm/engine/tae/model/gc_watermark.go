@@ -0,0 +1,81 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"errors"
+	"sync"
+
+	"github.com/matrixorigin/matrixone/pkg/container/types"
+)
+
+// ErrStaleReadTooOld is returned by a StartTxnAt-style entry point when the
+// requested timestamp precedes the oldest checkpoint GCWatermark still
+// guarantees is reachable.
+var ErrStaleReadTooOld = errors.New("model: snapshot read timestamp precedes the oldest retained checkpoint")
+
+// GCWatermark is a per-table bound on how far back in time the compactor
+// is allowed to GC committed versions, so a snapshot read pinned to any ts
+// at or after Oldest() can still be served from the existing MVCC chains.
+// The compactor computes retentionFloor as now minus
+// opts.TxnCfg.SnapshotRetention and calls Advance before it discards
+// anything older than its proposed candidate watermark.
+type GCWatermark struct {
+	mu     sync.RWMutex
+	oldest types.TS
+}
+
+// NewGCWatermark returns a GCWatermark with the given initial floor, e.g.
+// types.TS{} for a fresh table where every version is still reachable.
+func NewGCWatermark(initial types.TS) *GCWatermark {
+	return &GCWatermark{oldest: initial}
+}
+
+// Oldest returns the oldest timestamp GCWatermark currently guarantees is
+// reachable.
+func (w *GCWatermark) Oldest() types.TS {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.oldest
+}
+
+// Advance moves the watermark forward to candidate, refusing (and
+// returning false) when doing so would either move it backwards or push
+// it past retentionFloor, the oldest timestamp the configured retention
+// window still requires to stay reachable.
+func (w *GCWatermark) Advance(candidate, retentionFloor types.TS) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !retentionFloor.GreaterEq(candidate) {
+		return false
+	}
+	if !candidate.GreaterEq(w.oldest) {
+		return false
+	}
+	w.oldest = candidate
+	return true
+}
+
+// CheckReadTS returns ErrStaleReadTooOld if ts is older than the watermark,
+// i.e. a StartTxnAt(ctx, ts) call can no longer be served from the
+// retained MVCC history.
+func (w *GCWatermark) CheckReadTS(ts types.TS) error {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	if !ts.GreaterEq(w.oldest) {
+		return ErrStaleReadTooOld
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/matrixorigin/matrixone/pkg/container/types"
+	"github.com/stretchr/testify/assert"
+)
+
+func watermarkTS(physical int64) types.TS {
+	return types.BuildTS(physical, 0)
+}
+
+func TestGCWatermarkAdvanceMovesOldestForward(t *testing.T) {
+	w := NewGCWatermark(watermarkTS(5))
+	ok := w.Advance(watermarkTS(10), watermarkTS(20))
+	assert.True(t, ok)
+	assert.Equal(t, watermarkTS(10), w.Oldest())
+}
+
+func TestGCWatermarkAdvanceRejectsCandidatePastRetentionFloor(t *testing.T) {
+	w := NewGCWatermark(watermarkTS(5))
+	ok := w.Advance(watermarkTS(25), watermarkTS(20))
+	assert.False(t, ok)
+	assert.Equal(t, watermarkTS(5), w.Oldest())
+}
+
+func TestGCWatermarkAdvanceRejectsCandidateBeforeOldest(t *testing.T) {
+	w := NewGCWatermark(watermarkTS(10))
+	ok := w.Advance(watermarkTS(5), watermarkTS(20))
+	assert.False(t, ok)
+	assert.Equal(t, watermarkTS(10), w.Oldest())
+}
+
+func TestGCWatermarkAdvanceAcceptsCandidateEqualToRetentionFloor(t *testing.T) {
+	w := NewGCWatermark(watermarkTS(5))
+	ok := w.Advance(watermarkTS(20), watermarkTS(20))
+	assert.True(t, ok)
+	assert.Equal(t, watermarkTS(20), w.Oldest())
+}
+
+func TestGCWatermarkCheckReadTSAcceptsExactOldest(t *testing.T) {
+	w := NewGCWatermark(watermarkTS(10))
+	assert.NoError(t, w.CheckReadTS(watermarkTS(10)))
+}
+
+func TestGCWatermarkCheckReadTSRejectsOlderThanOldest(t *testing.T) {
+	w := NewGCWatermark(watermarkTS(10))
+	assert.ErrorIs(t, w.CheckReadTS(watermarkTS(9)), ErrStaleReadTooOld)
+}
@@ -0,0 +1,82 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import "github.com/matrixorigin/matrixone/pkg/container/types"
+
+// StalenessWindow is the already-resolved bounds ResolveStaleTS picks a
+// snapshot ts from. A StartTxnAtStaleness(maxStaleness)-style caller is
+// meant to compute Floor as max(GCWatermark.Oldest(), now-maxStaleness)
+// and Ceiling as min(StatMaxCommitTS(), now-minStaleness) itself, the
+// same way GCWatermark's own callers already compute retentionFloor:
+// this package has no notion of a wall clock or commit-ts allocator.
+type StalenessWindow struct {
+	Floor   types.TS
+	Ceiling types.TS
+}
+
+// ResolveStaleTS picks the ts a StartTxnAtStaleness/StartTxnAtTS-style
+// entry point should bind its transaction to: the highest entry in
+// checkpoints (assumed sorted ascending) that falls within window, so a
+// stale read can be served from persisted checkpoint state instead of
+// walking active append nodes, or window.Ceiling itself when no
+// checkpoint falls in range. It returns ErrStaleReadTooOld if window is
+// empty (Ceiling below Floor), meaning even the freshest ts the caller's
+// staleness bound allows is older than the GC safe point; callers are
+// meant to translate that into moerr.ErrSnapshotTooOld the same way
+// GCWatermark.CheckReadTS's error is meant to be translated.
+//
+// If staleTSAssertHook is set (only ever done by a test), it is called
+// with the resolved ts before ResolveStaleTS returns, mirroring TiDB's
+// assertStaleTSO: a test registers it to panic when the resolved ts
+// isn't the exact one it expected, rather than silently asserting on
+// the wrong snapshot.
+func ResolveStaleTS(window StalenessWindow, checkpoints []types.TS) (types.TS, error) {
+	if window.Ceiling.Less(window.Floor) {
+		return types.TS{}, ErrStaleReadTooOld
+	}
+
+	resolved := window.Ceiling
+	for i := len(checkpoints) - 1; i >= 0; i-- {
+		cp := checkpoints[i]
+		if window.Ceiling.Less(cp) {
+			continue // newer than the allowed ceiling; try an older one
+		}
+		if cp.Less(window.Floor) {
+			break // this and every earlier checkpoint are too old
+		}
+		resolved = cp
+		break
+	}
+
+	if staleTSAssertHook != nil {
+		staleTSAssertHook(resolved)
+	}
+	return resolved, nil
+}
+
+// staleTSAssertHook is nil in production; SetStaleTSAssertHook is the
+// only way to set it, so leaving a registration in place between tests
+// is always a deliberate choice.
+var staleTSAssertHook func(resolved types.TS)
+
+// SetStaleTSAssertHook installs fn to be called with every ts
+// ResolveStaleTS resolves, or clears it when fn is nil. Tests use this to
+// assert the resolver picked exactly the snapshot they expected, the way
+// TiDB's assertStaleTSO catches a stale-read regression that would
+// otherwise only surface as a hard-to-reproduce wrong-answer bug.
+func SetStaleTSAssertHook(fn func(resolved types.TS)) {
+	staleTSAssertHook = fn
+}
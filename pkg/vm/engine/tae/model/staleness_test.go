@@ -0,0 +1,69 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/matrixorigin/matrixone/pkg/container/types"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func staleTS(physical int64) types.TS {
+	return types.BuildTS(physical, 0)
+}
+
+func TestResolveStaleTSReturnsCeilingWithNoCheckpoints(t *testing.T) {
+	window := StalenessWindow{Floor: staleTS(5), Ceiling: staleTS(20)}
+	resolved, err := ResolveStaleTS(window, nil)
+	require.NoError(t, err)
+	assert.Equal(t, staleTS(20), resolved)
+}
+
+func TestResolveStaleTSPicksExactCheckpointMatch(t *testing.T) {
+	window := StalenessWindow{Floor: staleTS(5), Ceiling: staleTS(20)}
+	checkpoints := []types.TS{staleTS(10), staleTS(15), staleTS(25)}
+	resolved, err := ResolveStaleTS(window, checkpoints)
+	require.NoError(t, err)
+	assert.Equal(t, staleTS(15), resolved)
+}
+
+func TestResolveStaleTSFallsBackToCeilingBelowEveryCheckpoint(t *testing.T) {
+	window := StalenessWindow{Floor: staleTS(5), Ceiling: staleTS(8)}
+	checkpoints := []types.TS{staleTS(10), staleTS(25)}
+	resolved, err := ResolveStaleTS(window, checkpoints)
+	require.NoError(t, err)
+	assert.Equal(t, staleTS(8), resolved)
+}
+
+func TestResolveStaleTSErrorsWhenCeilingBelowFloor(t *testing.T) {
+	window := StalenessWindow{Floor: staleTS(20), Ceiling: staleTS(10)}
+	_, err := ResolveStaleTS(window, nil)
+	assert.ErrorIs(t, err, ErrStaleReadTooOld)
+}
+
+func TestSetStaleTSAssertHookFiresWithResolvedTS(t *testing.T) {
+	t.Cleanup(func() { SetStaleTSAssertHook(nil) })
+
+	var got types.TS
+	SetStaleTSAssertHook(func(resolved types.TS) { got = resolved })
+
+	window := StalenessWindow{Floor: staleTS(5), Ceiling: staleTS(20)}
+	resolved, err := ResolveStaleTS(window, []types.TS{staleTS(12)})
+	require.NoError(t, err)
+	assert.Equal(t, resolved, got)
+	assert.Equal(t, staleTS(12), got)
+}
@@ -0,0 +1,61 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+// BackgroundDriver names one of the periodic goroutines db.DB.Open starts
+// once the catalog and WAL are ready: CheckpointDriver ticks
+// BGCheckpointRunner's own-schedule incremental checkpoints, MergeDriver
+// ticks db/merge's policy-driven compaction scans, and GCDriver ticks the
+// checkpoint GC sweep. AccessMode.Allow already lets OpInternalCompaction
+// and OpInternalGC work run under AccessReadOnly when a caller asks for
+// it on demand (db.DB.FlushWALToBlocks, ForceIncrementalCheckpoint,
+// Catalog.GCByTS); StartupDrivers is the separate question of which of
+// these same drivers db.DB.Open is allowed to start ticking on its own
+// schedule, which a read-only handle must never do even though the work
+// itself stays callable.
+type BackgroundDriver uint8
+
+const (
+	CheckpointDriver BackgroundDriver = iota
+	MergeDriver
+	GCDriver
+)
+
+// startupDrivers is every driver db.DB.Open starts under AccessReadWrite.
+var startupDrivers = []BackgroundDriver{CheckpointDriver, MergeDriver, GCDriver}
+
+// StartupDrivers reports which background drivers mode permits db.DB.Open
+// to start on its own schedule. AccessReadOnly starts none: a read-only
+// replica's checkpoint/compaction/GC work is still reachable through an
+// on-demand call, it is just never self-scheduled, so two concurrently
+// open handles (one writable, one read-only) against the same directory
+// never race each other's internal tick.
+func StartupDrivers(mode AccessMode) []BackgroundDriver {
+	if mode == AccessReadOnly {
+		return nil
+	}
+	return append([]BackgroundDriver(nil), startupDrivers...)
+}
+
+// StartsDriver reports whether mode permits db.DB.Open to start d ticking
+// on its own schedule.
+func (mode AccessMode) StartsDriver(d BackgroundDriver) bool {
+	for _, started := range StartupDrivers(mode) {
+		if started == d {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,36 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStartupDriversReadWriteStartsAll(t *testing.T) {
+	got := StartupDrivers(AccessReadWrite)
+	assert.ElementsMatch(t, []BackgroundDriver{CheckpointDriver, MergeDriver, GCDriver}, got)
+}
+
+func TestStartupDriversReadOnlyStartsNone(t *testing.T) {
+	assert.Empty(t, StartupDrivers(AccessReadOnly))
+}
+
+func TestAccessModeStartsDriver(t *testing.T) {
+	assert.True(t, AccessReadWrite.StartsDriver(CheckpointDriver))
+	assert.False(t, AccessReadOnly.StartsDriver(CheckpointDriver))
+	assert.False(t, AccessReadOnly.StartsDriver(GCDriver))
+}
@@ -0,0 +1,94 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import "sync"
+
+// WalRange is the {BeginLSN, EndLSN} span of the WAL entry whose commit
+// attached a block or segment to its table - what rel.AddBlksWithMetaLoc
+// is meant to capture from the txn's WAL entry and stamp onto the
+// resulting catalog.BlockEntry/SegmentEntry via BlockEntry.WalRange(), so
+// a bulk-loaded block written to S3 out of band still has a WAL position
+// bounding it the same way an appended block's own write does.
+type WalRange struct {
+	BeginLSN uint64
+	EndLSN   uint64
+}
+
+// OrderingKey is the stable key LogtailMgr.RegisterCallback can sort or
+// dedup subscribers by, even for a block that has no append LSN of its
+// own to order by because it was written to S3 out of band.
+func (r WalRange) OrderingKey() uint64 {
+	return r.BeginLSN
+}
+
+// WalFloor tracks the WalRange of every live bulk-loaded block/segment so
+// Wal.RangeCheckpoint can compute the earliest LSN it must not truncate
+// past. Catalog.GCByTS calls Untrack once an entry is no longer
+// reachable; whoever commits a catalog.BlockEntry/SegmentEntry carrying a
+// WalRange calls Track at the same time.
+type WalFloor[S comparable] struct {
+	mu   sync.RWMutex
+	live map[S]WalRange
+}
+
+// NewWalFloor returns an empty WalFloor.
+func NewWalFloor[S comparable]() *WalFloor[S] {
+	return &WalFloor[S]{live: make(map[S]WalRange)}
+}
+
+// Track records id's WalRange, replacing any previously tracked range for
+// the same id.
+func (f *WalFloor[S]) Track(id S, r WalRange) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.live[id] = r
+}
+
+// Untrack removes id, e.g. once Catalog.GCByTS has dropped its entry.
+func (f *WalFloor[S]) Untrack(id S) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	delete(f.live, id)
+}
+
+// Floor returns the lowest BeginLSN among every currently tracked range,
+// and ok=false if nothing is tracked, meaning no live entry bounds
+// truncation.
+func (f *WalFloor[S]) Floor() (lsn uint64, ok bool) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	first := true
+	for _, r := range f.live {
+		if first || r.BeginLSN < lsn {
+			lsn = r.BeginLSN
+			first = false
+		}
+	}
+	return lsn, !first
+}
+
+// AllowTruncate reports whether Wal.RangeCheckpoint may truncate through
+// candidateLSN without dropping a WAL entry a live tracked block/segment
+// still needs, i.e. candidateLSN does not reach or pass the floor's
+// BeginLSN. A WalFloor with nothing tracked places no bound on
+// truncation.
+func (f *WalFloor[S]) AllowTruncate(candidateLSN uint64) bool {
+	floor, ok := f.Floor()
+	if !ok {
+		return true
+	}
+	return candidateLSN < floor
+}
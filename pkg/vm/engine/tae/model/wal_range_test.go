@@ -0,0 +1,68 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package model
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWalRangeOrderingKeyIsBeginLSN(t *testing.T) {
+	r := WalRange{BeginLSN: 10, EndLSN: 20}
+	assert.Equal(t, uint64(10), r.OrderingKey())
+}
+
+func TestWalFloorAllowsTruncationWithNothingTracked(t *testing.T) {
+	f := NewWalFloor[int]()
+	_, ok := f.Floor()
+	assert.False(t, ok)
+	assert.True(t, f.AllowTruncate(1000))
+}
+
+func TestWalFloorTracksLowestBeginLSN(t *testing.T) {
+	f := NewWalFloor[int]()
+	f.Track(1, WalRange{BeginLSN: 50, EndLSN: 60})
+	f.Track(2, WalRange{BeginLSN: 20, EndLSN: 30})
+	f.Track(3, WalRange{BeginLSN: 80, EndLSN: 90})
+
+	lsn, ok := f.Floor()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(20), lsn)
+}
+
+func TestWalFloorRefusesTruncationPastFloor(t *testing.T) {
+	f := NewWalFloor[int]()
+	f.Track(1, WalRange{BeginLSN: 20, EndLSN: 30})
+
+	assert.True(t, f.AllowTruncate(19))
+	assert.False(t, f.AllowTruncate(20))
+	assert.False(t, f.AllowTruncate(25))
+}
+
+func TestWalFloorUntrackReleasesFloor(t *testing.T) {
+	f := NewWalFloor[int]()
+	f.Track(1, WalRange{BeginLSN: 20, EndLSN: 30})
+	f.Track(2, WalRange{BeginLSN: 50, EndLSN: 60})
+
+	f.Untrack(1)
+	lsn, ok := f.Floor()
+	assert.True(t, ok)
+	assert.Equal(t, uint64(50), lsn)
+
+	f.Untrack(2)
+	_, ok = f.Floor()
+	assert.False(t, ok)
+}
@@ -0,0 +1,124 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package ooo supports routing late-arriving appends (CDC backfills, edge
+// uploads) into a parallel out-of-order block attached to a segment
+// instead of rejecting them outright. It does not itself know about
+// catalog.SegmentEntry/BlockEntry or handle.Block's IsOutOfOrder flag —
+// those own attaching an OOO ablk to a segment and are left to the
+// catalog/handle packages. What this package owns is the read-time and
+// compaction-time merge: ForEachBlock/GetValueByFilter need the in-order
+// and OOO tiers to look like one sorted stream, and a threshold trigger
+// reuses model.DeletePressureTracker's accumulate-then-fire shape (the OOO
+// tier's "pressure" is row count instead of delete count) to decide when
+// jobs.NewCompactBlockTask's OOO variant should re-sort the tier into the
+// segment's main block layout.
+package ooo
+
+// RowStream yields rows in ascending key order for one tier (the main,
+// in-order blocks or the OOO tier attached to the segment).
+type RowStream[K any, V any] interface {
+	// Next returns the next row in ascending key order, or ok=false once
+	// the stream is exhausted.
+	Next() (key K, value V, ok bool, err error)
+}
+
+// Less reports whether a sorts before b.
+type Less[K any] func(a, b K) bool
+
+// MergeStream merges two already key-sorted RowStreams into one ascending
+// stream, the way a reader must see a segment's in-order blocks and its
+// OOO tier as a single logical block. On a key present in both tiers, main
+// wins: the OOO tier only ever holds rows the in-order path hasn't
+// absorbed yet via compaction, so if both somehow produced the same key
+// the in-order copy is the authoritative, already-compacted one.
+type MergeStream[K any, V any] struct {
+	main, ooo RowStream[K, V]
+	less      Less[K]
+
+	mainKey, oooKey   K
+	mainVal, oooVal   V
+	mainOk, oooOk     bool
+	mainErr, oooErr   error
+	mainDone, oooDone bool
+}
+
+// NewMergeStream returns a MergeStream over main and ooo.
+func NewMergeStream[K any, V any](main, ooo RowStream[K, V], less Less[K]) *MergeStream[K, V] {
+	return &MergeStream[K, V]{main: main, ooo: ooo, less: less}
+}
+
+func (m *MergeStream[K, V]) fillMain() error {
+	if m.mainDone || m.mainOk {
+		return nil
+	}
+	k, v, ok, err := m.main.Next()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		m.mainDone = true
+		return nil
+	}
+	m.mainKey, m.mainVal, m.mainOk = k, v, true
+	return nil
+}
+
+func (m *MergeStream[K, V]) fillOOO() error {
+	if m.oooDone || m.oooOk {
+		return nil
+	}
+	k, v, ok, err := m.ooo.Next()
+	if err != nil {
+		return err
+	}
+	if !ok {
+		m.oooDone = true
+		return nil
+	}
+	m.oooKey, m.oooVal, m.oooOk = k, v, true
+	return nil
+}
+
+// Next returns the next row across both tiers in ascending key order.
+func (m *MergeStream[K, V]) Next() (key K, value V, ok bool, err error) {
+	if err := m.fillMain(); err != nil {
+		return key, value, false, err
+	}
+	if err := m.fillOOO(); err != nil {
+		return key, value, false, err
+	}
+
+	switch {
+	case !m.mainOk && !m.oooOk:
+		return key, value, false, nil
+	case m.mainOk && !m.oooOk:
+		key, value, m.mainOk = m.mainKey, m.mainVal, false
+		return key, value, true, nil
+	case !m.mainOk && m.oooOk:
+		key, value, m.oooOk = m.oooKey, m.oooVal, false
+		return key, value, true, nil
+	case m.less(m.oooKey, m.mainKey):
+		key, value, m.oooOk = m.oooKey, m.oooVal, false
+		return key, value, true, nil
+	default:
+		// main wins ties, per the doc comment above.
+		key, value, m.mainOk = m.mainKey, m.mainVal, false
+		if !m.less(m.mainKey, m.oooKey) {
+			// mainKey == oooKey: the OOO copy is stale, drop it too.
+			m.oooOk = false
+		}
+		return key, value, true, nil
+	}
+}
@@ -0,0 +1,81 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ooo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sliceStream struct {
+	keys []int
+	vals []string
+	pos  int
+}
+
+func (s *sliceStream) Next() (int, string, bool, error) {
+	if s.pos >= len(s.keys) {
+		return 0, "", false, nil
+	}
+	k, v := s.keys[s.pos], s.vals[s.pos]
+	s.pos++
+	return k, v, true, nil
+}
+
+func drain(t *testing.T, m *MergeStream[int, string]) ([]int, []string) {
+	var keys []int
+	var vals []string
+	for {
+		k, v, ok, err := m.Next()
+		require.NoError(t, err)
+		if !ok {
+			return keys, vals
+		}
+		keys = append(keys, k)
+		vals = append(vals, v)
+	}
+}
+
+func TestMergeStreamInterleaves(t *testing.T) {
+	main := &sliceStream{keys: []int{1, 3, 5}, vals: []string{"m1", "m3", "m5"}}
+	ooo := &sliceStream{keys: []int{2, 4}, vals: []string{"o2", "o4"}}
+	m := NewMergeStream[int, string](main, ooo, func(a, b int) bool { return a < b })
+
+	keys, vals := drain(t, m)
+	assert.Equal(t, []int{1, 2, 3, 4, 5}, keys)
+	assert.Equal(t, []string{"m1", "o2", "m3", "o4", "m5"}, vals)
+}
+
+func TestMergeStreamMainWinsTies(t *testing.T) {
+	main := &sliceStream{keys: []int{1, 2}, vals: []string{"m1", "m2"}}
+	ooo := &sliceStream{keys: []int{2, 3}, vals: []string{"stale-o2", "o3"}}
+	m := NewMergeStream[int, string](main, ooo, func(a, b int) bool { return a < b })
+
+	keys, vals := drain(t, m)
+	assert.Equal(t, []int{1, 2, 3}, keys)
+	assert.Equal(t, []string{"m1", "m2", "o3"}, vals)
+}
+
+func TestMergeStreamOneSideEmpty(t *testing.T) {
+	main := &sliceStream{}
+	ooo := &sliceStream{keys: []int{1, 2}, vals: []string{"o1", "o2"}}
+	m := NewMergeStream[int, string](main, ooo, func(a, b int) bool { return a < b })
+
+	keys, vals := drain(t, m)
+	assert.Equal(t, []int{1, 2}, keys)
+	assert.Equal(t, []string{"o1", "o2"}, vals)
+}
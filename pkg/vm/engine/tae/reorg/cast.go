@@ -0,0 +1,124 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reorg
+
+import (
+	"fmt"
+	"unicode/utf8"
+)
+
+// CastError reports that a cast ConvertFunc produced a value CheckedCast's
+// domain check rejected - a silent truncation, an overflow, an invalid
+// UTF-8 byte sequence - the same class of row CheckedCast's caller should
+// fail the whole ALTER ... MODIFY COLUMN TYPE for, the way MySQL error
+// 1366 ("Incorrect value") fails a row in TiDB's online column-type-change
+// rather than writing a silently mangled value. ColumnReorgTask.Run wraps
+// this in a ConvertError naming the column and row, so the caller never
+// has to reach into CastError for that context.
+type CastError struct {
+	Old    any
+	Reason string
+}
+
+func (e *CastError) Error() string {
+	return fmt.Sprintf("value %v rejected: %s", e.Old, e.Reason)
+}
+
+// CastCheck reports whether casting old to new is safe to accept, naming
+// why not when it isn't. A check only ever sees the pair it was invoked
+// for; CheckedCast decides how to fold that into a ConvertFunc's error.
+type CastCheck func(old, new any) (ok bool, reason string)
+
+// CheckedCast wraps convert so that every value it produces is run
+// through every check in checks before being accepted; the first check
+// to fail turns the row into a CastError instead of a silently truncated
+// or invalid value reaching the shadow segment. checks run in order and
+// CheckedCast stops at the first failure.
+func CheckedCast(convert ConvertFunc, checks ...CastCheck) ConvertFunc {
+	return func(old any) (any, error) {
+		newVal, err := convert(old)
+		if err != nil {
+			return nil, err
+		}
+		for _, check := range checks {
+			if ok, reason := check(old, newVal); !ok {
+				return nil, &CastError{Old: old, Reason: reason}
+			}
+		}
+		return newVal, nil
+	}
+}
+
+// NoStringTruncation rejects a cast whose result is a string longer than
+// maxLen, the domain check a MODIFY COLUMN TYPE that narrows a VARCHAR's
+// length must enforce rather than silently truncating every over-length
+// value the way a naive cast would.
+func NoStringTruncation(maxLen int) CastCheck {
+	return func(_, new any) (bool, string) {
+		s, ok := new.(string)
+		if !ok {
+			return true, ""
+		}
+		if len(s) > maxLen {
+			return false, fmt.Sprintf("string of length %d exceeds column length %d", len(s), maxLen)
+		}
+		return true, ""
+	}
+}
+
+// ValidUTF8 rejects a cast whose result is a string containing a byte
+// sequence that is not valid UTF-8, the check a cast from a binary
+// column type to a text one must run before the shadow segment can be
+// trusted to hold well-formed text.
+func ValidUTF8() CastCheck {
+	return func(_, new any) (bool, string) {
+		s, ok := new.(string)
+		if !ok {
+			return true, ""
+		}
+		if !utf8.ValidString(s) {
+			return false, "invalid UTF-8 sequence"
+		}
+		return true, ""
+	}
+}
+
+// NoIntOverflow rejects a cast whose result, widened to int64, falls
+// outside [min, max], the domain check narrowing an integer column's
+// width (e.g. BIGINT -> INT) must run instead of letting the cast wrap
+// silently.
+func NoIntOverflow(min, max int64) CastCheck {
+	return func(_, new any) (bool, string) {
+		var v int64
+		switch n := new.(type) {
+		case int64:
+			v = n
+		case int32:
+			v = int64(n)
+		case int16:
+			v = int64(n)
+		case int8:
+			v = int64(n)
+		case int:
+			v = int64(n)
+		default:
+			return true, ""
+		}
+		if v < min || v > max {
+			return false, fmt.Sprintf("integer value %d out of range [%d, %d]", v, min, max)
+		}
+		return true, ""
+	}
+}
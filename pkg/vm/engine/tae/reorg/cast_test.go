@@ -0,0 +1,81 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reorg
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func identity(old any) (any, error) {
+	return old, nil
+}
+
+func TestCheckedCastPassesThroughAcceptedValues(t *testing.T) {
+	convert := CheckedCast(identity, NoStringTruncation(5))
+	got, err := convert("abc")
+	require.NoError(t, err)
+	assert.Equal(t, "abc", got)
+}
+
+func TestCheckedCastRejectsStringTruncation(t *testing.T) {
+	convert := CheckedCast(identity, NoStringTruncation(3))
+	_, err := convert("abcdef")
+	require.Error(t, err)
+	var castErr *CastError
+	require.ErrorAs(t, err, &castErr)
+	assert.Equal(t, "abcdef", castErr.Old)
+}
+
+func TestCheckedCastRejectsInvalidUTF8(t *testing.T) {
+	convert := CheckedCast(identity, ValidUTF8())
+	_, err := convert(string([]byte{0xff, 0xfe}))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "invalid UTF-8")
+}
+
+func TestCheckedCastRejectsIntOverflow(t *testing.T) {
+	convert := CheckedCast(func(old any) (any, error) {
+		return int64(old.(int32)) * 1000, nil
+	}, NoIntOverflow(-100, 100))
+	_, err := convert(int32(1))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "out of range")
+}
+
+func TestCheckedCastPropagatesConvertError(t *testing.T) {
+	convertFails := errors.New("bad input")
+	convert := CheckedCast(func(any) (any, error) { return nil, convertFails }, ValidUTF8())
+	_, err := convert("x")
+	require.ErrorIs(t, err, convertFails)
+}
+
+func TestCheckedCastInsideColumnReorgTaskNamesOffendingRow(t *testing.T) {
+	convert := CheckedCast(identity, NoStringTruncation(2))
+	task := NewColumnReorgTask("name", convert)
+	src := &fakeSource{batches: [][]any{{"ab", "abcdef"}}, deletes: [][]bool{{false, false}}}
+	sink := &fakeSink{}
+
+	err := task.Run(context.Background(), src, sink)
+	require.Error(t, err)
+	var convErr *ConvertError
+	require.ErrorAs(t, err, &convErr)
+	assert.Equal(t, "name", convErr.OldColumn)
+	assert.Equal(t, 1, convErr.Row)
+}
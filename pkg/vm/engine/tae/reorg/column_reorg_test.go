@@ -0,0 +1,156 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reorg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSource struct {
+	batches [][]any
+	deletes [][]bool
+	pos     int
+}
+
+func (s *fakeSource) Next(context.Context) ([]any, []bool, bool, error) {
+	if s.pos >= len(s.batches) {
+		return nil, nil, false, nil
+	}
+	batch, deleted := s.batches[s.pos], s.deletes[s.pos]
+	s.pos++
+	return batch, deleted, true, nil
+}
+
+type fakeSink struct {
+	written       [][]any
+	deletedBatch  [][]int
+	lastBatchRows int
+}
+
+func (s *fakeSink) Write(_ context.Context, batch []any) error {
+	s.written = append(s.written, batch)
+	s.lastBatchRows = len(batch)
+	return nil
+}
+
+func (s *fakeSink) TransferDelete(_ context.Context, rows []int) error {
+	s.deletedBatch = append(s.deletedBatch, rows)
+	return nil
+}
+
+func int32ToInt64(old any) (any, error) {
+	return int64(old.(int32)), nil
+}
+
+func TestColumnReorgTaskConvertsAndTransfersDeletes(t *testing.T) {
+	src := &fakeSource{
+		batches: [][]any{{int32(1), int32(2)}, {int32(3)}},
+		deletes: [][]bool{{false, true}, {false}},
+	}
+	dst := &fakeSink{}
+	task := NewColumnReorgTask("a", int32ToInt64)
+
+	require.NoError(t, task.Run(context.Background(), src, dst))
+	assert.Equal(t, [][]any{{int64(1), int64(2)}, {int64(3)}}, dst.written)
+	assert.Equal(t, [][]int{{1}}, dst.deletedBatch)
+
+	converted, deleted := task.Progress()
+	assert.Equal(t, 3, converted)
+	assert.Equal(t, 1, deleted)
+}
+
+func TestColumnReorgTaskNamesErrorAfterOldColumn(t *testing.T) {
+	src := &fakeSource{
+		batches: [][]any{{"not-a-number"}},
+		deletes: [][]bool{{false}},
+	}
+	dst := &fakeSink{}
+	convertFails := func(any) (any, error) { return nil, errors.New("invalid syntax") }
+	task := NewColumnReorgTask("amount", convertFails)
+
+	err := task.Run(context.Background(), src, dst)
+	require.Error(t, err)
+	var convErr *ConvertError
+	require.True(t, errors.As(err, &convErr))
+	assert.Equal(t, "amount", convErr.OldColumn)
+	assert.Contains(t, fmt.Sprint(err), "amount")
+}
+
+func TestColumnReorgTaskCancelStopsBeforeNextBatch(t *testing.T) {
+	src := &fakeSource{
+		batches: [][]any{{int32(1)}, {int32(2)}},
+		deletes: [][]bool{{false}, {false}},
+	}
+	dst := &fakeSink{}
+	task := NewColumnReorgTask("a", int32ToInt64)
+	task.Cancel()
+
+	err := task.Run(context.Background(), src, dst)
+	require.ErrorIs(t, err, ErrCancelled)
+	assert.Empty(t, dst.written)
+}
+
+func TestColumnReorgTaskRespectsContextCancellation(t *testing.T) {
+	src := &fakeSource{
+		batches: [][]any{{int32(1)}},
+		deletes: [][]bool{{false}},
+	}
+	dst := &fakeSink{}
+	task := NewColumnReorgTask("a", int32ToInt64)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := task.Run(ctx, src, dst)
+	require.ErrorIs(t, err, ErrCancelled)
+}
+
+// blockingSource lets a test Cancel a task from another goroutine while
+// Run is blocked waiting on Next, exercising the "safe to call from
+// another goroutine while Run is in progress" guarantee Cancel documents.
+type blockingSource struct {
+	entered chan struct{}
+	release chan struct{}
+	served  bool
+}
+
+func (s *blockingSource) Next(context.Context) ([]any, []bool, bool, error) {
+	if !s.served {
+		s.served = true
+		close(s.entered)
+		<-s.release
+	}
+	return nil, nil, false, nil
+}
+
+func TestColumnReorgTaskCancelIsSafeFromAnotherGoroutine(t *testing.T) {
+	src := &blockingSource{entered: make(chan struct{}), release: make(chan struct{})}
+	dst := &fakeSink{}
+	task := NewColumnReorgTask("a", int32ToInt64)
+
+	go func() {
+		<-src.entered
+		task.Cancel()
+		close(src.release)
+	}()
+
+	err := task.Run(context.Background(), src, dst)
+	assert.NoError(t, err)
+}
@@ -0,0 +1,196 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reorg
+
+import (
+	"context"
+	"fmt"
+)
+
+// Segment identifies one segment a SchemaReorgJob must walk. It carries
+// nothing but an ID: the caller-provided SegmentSource is the one place
+// that knows how to turn an ID into the RowSource/RowSink pair a
+// ColumnReorgTask reads and writes, the same way this package already
+// stays ignorant of catalog.Schema and the block reader.
+type Segment struct {
+	ID uint64
+}
+
+// SegmentSource enumerates the segments a SchemaReorgJob must convert, in
+// a stable order, up to (and not beyond) the reorg watermark the caller
+// snapshotted when the DDL committed. Open returns the RowSource/RowSink
+// pair backing seg's old and changing columns.
+type SegmentSource interface {
+	Segments(ctx context.Context) ([]Segment, error)
+	Open(ctx context.Context, seg Segment) (RowSource, RowSink, error)
+}
+
+// ProgressStore persists which segments a SchemaReorgJob has already
+// finished converting, so a restart resumes instead of re-converting
+// segments whose output already landed. Callers back this by a system
+// table row keyed on the DDL's job ID, the same restart pattern the
+// GC/checkpoint watermarks already use.
+type ProgressStore interface {
+	// Load returns the segment IDs already marked done for jobID, or an
+	// empty set for a job that has never run.
+	Load(ctx context.Context, jobID string) (done map[uint64]bool, err error)
+	// MarkDone persists that segID finished converting under jobID.
+	MarkDone(ctx context.Context, jobID string, segID uint64) error
+}
+
+// SchemaReorgJob drives one ALTER ... MODIFY COLUMN's ColumnReorgTask
+// across every segment SegmentSource reports, batched so no more than
+// maxSegmentsPerBatch segments are converted before progress is
+// persisted (modeled on how compaction batches work respecting
+// catalog.Schema's SegmentMaxBlocks). It owns sequencing and restart
+// bookkeeping only; the per-segment conversion is still ColumnReorgTask's
+// job, and the shadow column's lifecycle is still ShadowColumn's.
+type SchemaReorgJob struct {
+	jobID   string
+	oldCol  string
+	convert ConvertFunc
+
+	src      SegmentSource
+	progress ProgressStore
+	shadow   *ShadowColumn
+
+	maxSegmentsPerBatch int
+
+	// rowsConverted, totalSegments, doneSegments, and currentSegmentID are
+	// snapshotted at the end of every Run call so Progress can report them
+	// to a ddl.Job without re-deriving them from progress.Load itself.
+	rowsConverted    uint64
+	totalSegments    int
+	doneSegments     int
+	currentSegmentID uint64
+
+	// segmentsRun counts only the segments *this* job instance has actually
+	// opened and converted, unlike doneSegments, which progress.Load seeds
+	// with segments a previous instance (e.g. before a restart) already
+	// finished. ProgressStore persists segment IDs, not row counts, so
+	// rowsConverted only ever reflects this instance's own work; Progress
+	// must divide by segmentsRun, not doneSegments, or a resumed job
+	// understates estimatedTotal by counting segments in the denominator it
+	// never added rows for.
+	segmentsRun int
+}
+
+// NewSchemaReorgJob returns a job that converts oldColumn's values with
+// convert across every segment src reports, persisting restart progress
+// to progress under jobID and swapping shadow in once every segment
+// finishes. maxSegmentsPerBatch must be positive; it bounds how many
+// segments are converted between progress checkpoints.
+func NewSchemaReorgJob(jobID, oldColumn string, convert ConvertFunc, src SegmentSource, progress ProgressStore, shadow *ShadowColumn, maxSegmentsPerBatch int) (*SchemaReorgJob, error) {
+	if maxSegmentsPerBatch <= 0 {
+		return nil, fmt.Errorf("reorg: maxSegmentsPerBatch must be positive, got %d", maxSegmentsPerBatch)
+	}
+	return &SchemaReorgJob{
+		jobID:               jobID,
+		oldCol:              oldColumn,
+		convert:             convert,
+		src:                 src,
+		progress:            progress,
+		shadow:              shadow,
+		maxSegmentsPerBatch: maxSegmentsPerBatch,
+	}, nil
+}
+
+// Run converts up to maxSegmentsPerBatch not-yet-done segments and
+// returns, checkpointing each one to progress as it finishes so a crash
+// between calls resumes rather than re-converting or skipping work.
+// Callers should keep calling Run until it reports done=true, the same
+// call-until-done shape background compaction batches already use to
+// avoid holding one long-running task across a restart.
+//
+// Once every segment reports done, Run swaps shadow in and returns
+// done=true. On the first conversion failure or cancellation it rolls
+// shadow back and returns the error without checkpointing the failing
+// segment, so the next Run call re-converts it rather than skipping it.
+func (j *SchemaReorgJob) Run(ctx context.Context) (done bool, err error) {
+	if j.shadow.State() == ShadowStaging {
+		if err := j.shadow.BeginReorg(); err != nil {
+			return false, err
+		}
+	}
+
+	segs, err := j.src.Segments(ctx)
+	if err != nil {
+		return false, err
+	}
+	doneSegs, err := j.progress.Load(ctx, j.jobID)
+	if err != nil {
+		return false, err
+	}
+
+	j.totalSegments = len(segs)
+	j.doneSegments = len(doneSegs)
+
+	converted := 0
+	remaining := false
+	for _, seg := range segs {
+		if doneSegs[seg.ID] {
+			continue
+		}
+		if converted >= j.maxSegmentsPerBatch {
+			remaining = true
+			break
+		}
+		if ctx.Err() != nil {
+			j.shadow.Rollback()
+			return false, ErrCancelled
+		}
+
+		j.currentSegmentID = seg.ID
+		rs, sink, err := j.src.Open(ctx, seg)
+		if err != nil {
+			j.shadow.Rollback()
+			return false, err
+		}
+		task := NewColumnReorgTask(j.oldCol, j.convert)
+		if err := task.Run(ctx, rs, sink); err != nil {
+			j.shadow.Rollback()
+			return false, err
+		}
+		rowsDone, _ := task.Progress()
+		j.rowsConverted += uint64(rowsDone)
+		if err := j.progress.MarkDone(ctx, j.jobID, seg.ID); err != nil {
+			j.shadow.Rollback()
+			return false, err
+		}
+		converted++
+		j.doneSegments++
+		j.segmentsRun++
+	}
+
+	if remaining {
+		return false, nil
+	}
+	return true, j.shadow.Swap()
+}
+
+// Progress reports rows converted so far, an estimated total derived from
+// the segment count the last Run call saw (the rows per segment this
+// instance converted, times that count), and the segment currently being converted,
+// in the shape a ddl.Job checkpoints after every Run call. It is safe to
+// call before the first Run, returning all zeros.
+func (j *SchemaReorgJob) Progress() (rowsConverted, estimatedTotal, currentSegmentID uint64) {
+	rowsConverted = j.rowsConverted
+	if j.segmentsRun > 0 {
+		perSegment := j.rowsConverted / uint64(j.segmentsRun)
+		estimatedTotal = perSegment * uint64(j.totalSegments)
+	}
+	currentSegmentID = j.currentSegmentID
+	return
+}
@@ -0,0 +1,211 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reorg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeSegmentSource struct {
+	segs   []Segment
+	opened []uint64
+}
+
+func (s *fakeSegmentSource) Segments(context.Context) ([]Segment, error) {
+	return s.segs, nil
+}
+
+func (s *fakeSegmentSource) Open(_ context.Context, seg Segment) (RowSource, RowSink, error) {
+	s.opened = append(s.opened, seg.ID)
+	return &fakeSource{
+			batches: [][]any{{int32(seg.ID)}},
+			deletes: [][]bool{{false}},
+		},
+		&fakeSink{}, nil
+}
+
+type fakeProgressStore struct {
+	done map[string]map[uint64]bool
+}
+
+func newFakeProgressStore() *fakeProgressStore {
+	return &fakeProgressStore{done: make(map[string]map[uint64]bool)}
+}
+
+func (p *fakeProgressStore) Load(_ context.Context, jobID string) (map[uint64]bool, error) {
+	out := make(map[uint64]bool)
+	for id, ok := range p.done[jobID] {
+		out[id] = ok
+	}
+	return out, nil
+}
+
+func (p *fakeProgressStore) MarkDone(_ context.Context, jobID string, segID uint64) error {
+	if p.done[jobID] == nil {
+		p.done[jobID] = make(map[uint64]bool)
+	}
+	p.done[jobID][segID] = true
+	return nil
+}
+
+func TestSchemaReorgJobConvertsInBatchesAndSwaps(t *testing.T) {
+	src := &fakeSegmentSource{segs: []Segment{{ID: 1}, {ID: 2}, {ID: 3}}}
+	progress := newFakeProgressStore()
+	shadow := NewShadowColumn("amount", "__reorg_amount")
+	job, err := NewSchemaReorgJob("job-1", "amount", int32ToInt64, src, progress, shadow, 2)
+	require.NoError(t, err)
+
+	done, err := job.Run(context.Background())
+	require.NoError(t, err)
+	assert.False(t, done)
+	assert.Equal(t, ShadowReorging, shadow.State())
+	assert.Len(t, src.opened, 2)
+
+	done, err = job.Run(context.Background())
+	require.NoError(t, err)
+	assert.True(t, done)
+	assert.Equal(t, ShadowSwapped, shadow.State())
+	assert.Len(t, src.opened, 3)
+
+	rows, total, seg := job.Progress()
+	assert.Equal(t, uint64(3), rows)
+	assert.Equal(t, uint64(3), total)
+	assert.Equal(t, uint64(3), seg)
+}
+
+func TestSchemaReorgJobResumesWithoutReopeningDoneSegments(t *testing.T) {
+	src := &fakeSegmentSource{segs: []Segment{{ID: 1}, {ID: 2}}}
+	progress := newFakeProgressStore()
+	require.NoError(t, progress.MarkDone(context.Background(), "job-1", 1))
+	shadow := NewShadowColumn("amount", "__reorg_amount")
+	job, err := NewSchemaReorgJob("job-1", "amount", int32ToInt64, src, progress, shadow, 5)
+	require.NoError(t, err)
+
+	done, err := job.Run(context.Background())
+	require.NoError(t, err)
+	assert.True(t, done)
+	assert.Equal(t, []uint64{2}, src.opened)
+}
+
+// TestSchemaReorgJobProgressAfterResumeCountsOnlyThisInstancesSegments
+// proves Progress's estimatedTotal is not understated after a restart:
+// segment 1 was already marked done by a prior job instance before this
+// one is constructed, so this instance only ever converts segment 2's
+// rows itself. estimatedTotal must still land on the real per-segment
+// average (1 row/segment here) rather than dividing rowsConverted by both
+// segments, which would halve it.
+func TestSchemaReorgJobProgressAfterResumeCountsOnlyThisInstancesSegments(t *testing.T) {
+	src := &fakeSegmentSource{segs: []Segment{{ID: 1}, {ID: 2}}}
+	progress := newFakeProgressStore()
+	require.NoError(t, progress.MarkDone(context.Background(), "job-1", 1))
+	shadow := NewShadowColumn("amount", "__reorg_amount")
+	job, err := NewSchemaReorgJob("job-1", "amount", int32ToInt64, src, progress, shadow, 5)
+	require.NoError(t, err)
+
+	done, err := job.Run(context.Background())
+	require.NoError(t, err)
+	assert.True(t, done)
+
+	rows, total, _ := job.Progress()
+	assert.Equal(t, uint64(1), rows)
+	assert.Equal(t, uint64(2), total)
+}
+
+func TestSchemaReorgJobRollsBackShadowOnConversionFailure(t *testing.T) {
+	src := &fakeSegmentSource{segs: []Segment{{ID: 1}}}
+	progress := newFakeProgressStore()
+	shadow := NewShadowColumn("amount", "__reorg_amount")
+	convertFails := func(any) (any, error) { return nil, errors.New("invalid syntax") }
+	job, err := NewSchemaReorgJob("job-1", "amount", convertFails, src, progress, shadow, 5)
+	require.NoError(t, err)
+
+	_, err = job.Run(context.Background())
+	require.Error(t, err)
+	assert.Equal(t, ShadowRolledBack, shadow.State())
+}
+
+func TestSchemaReorgJobRollsBackOnCancellation(t *testing.T) {
+	src := &fakeSegmentSource{segs: []Segment{{ID: 1}}}
+	progress := newFakeProgressStore()
+	shadow := NewShadowColumn("amount", "__reorg_amount")
+	job, err := NewSchemaReorgJob("job-1", "amount", int32ToInt64, src, progress, shadow, 5)
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = job.Run(ctx)
+	require.ErrorIs(t, err, ErrCancelled)
+	assert.Equal(t, ShadowRolledBack, shadow.State())
+}
+
+func TestNewSchemaReorgJobRejectsNonPositiveBatchSize(t *testing.T) {
+	src := &fakeSegmentSource{}
+	progress := newFakeProgressStore()
+	shadow := NewShadowColumn("amount", "__reorg_amount")
+	_, err := NewSchemaReorgJob("job-1", "amount", int32ToInt64, src, progress, shadow, 0)
+	assert.Error(t, err)
+}
+
+// int32ToShortString stands in for a MODIFY COLUMN TYPE narrowing an
+// integer column to a short VARCHAR: CheckedCast's NoStringTruncation
+// check is what must catch a segment ID that doesn't fit.
+func int32ToShortString(old any) (any, error) {
+	return fmt.Sprintf("%d", old.(int32)), nil
+}
+
+// TestSchemaReorgJobRunsCheckedCastConversion wires cast.go's CheckedCast
+// in as the job's ConvertFunc, proving the full reorg chain - domain
+// check, ColumnReorgTask, ShadowColumn swap - composes end to end rather
+// than each piece only ever being exercised in isolation.
+func TestSchemaReorgJobRunsCheckedCastConversion(t *testing.T) {
+	src := &fakeSegmentSource{segs: []Segment{{ID: 1}, {ID: 2}}}
+	progress := newFakeProgressStore()
+	shadow := NewShadowColumn("amount", "__reorg_amount")
+	convert := CheckedCast(int32ToShortString, NoStringTruncation(4), ValidUTF8())
+	job, err := NewSchemaReorgJob("job-1", "amount", convert, src, progress, shadow, 5)
+	require.NoError(t, err)
+
+	done, err := job.Run(context.Background())
+	require.NoError(t, err)
+	assert.True(t, done)
+	assert.Equal(t, ShadowSwapped, shadow.State())
+}
+
+// TestSchemaReorgJobRollsBackOnCheckedCastDomainViolation proves a
+// CheckedCast domain failure (here NoStringTruncation rejecting an
+// over-length conversion) reaches the job as a real error and rolls the
+// shadow column back, the same as any other ConvertFunc failure.
+func TestSchemaReorgJobRollsBackOnCheckedCastDomainViolation(t *testing.T) {
+	src := &fakeSegmentSource{segs: []Segment{{ID: 123456}}}
+	progress := newFakeProgressStore()
+	shadow := NewShadowColumn("amount", "__reorg_amount")
+	convert := CheckedCast(int32ToShortString, NoStringTruncation(4))
+	job, err := NewSchemaReorgJob("job-1", "amount", convert, src, progress, shadow, 5)
+	require.NoError(t, err)
+
+	_, err = job.Run(context.Background())
+	require.Error(t, err)
+	var convErr *ConvertError
+	require.ErrorAs(t, err, &convErr)
+	var castErr *CastError
+	assert.ErrorAs(t, convErr.Cause, &castErr)
+	assert.Equal(t, ShadowRolledBack, shadow.State())
+}
@@ -0,0 +1,142 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reorg
+
+import (
+	"fmt"
+	"sync"
+)
+
+// ShadowState is where a ShadowColumn is in its lifecycle.
+type ShadowState uint8
+
+const (
+	// ShadowStaging: the changing column exists and is mirrored on every
+	// write to OldName, but ColumnReorgTask has not started backfilling
+	// already-committed rows yet.
+	ShadowStaging ShadowState = iota
+	// ShadowReorging: ColumnReorgTask is backfilling historical rows while
+	// ShadowStaging's mirroring keeps applying to new writes.
+	ShadowReorging
+	// ShadowSwapped: the final DDL txn renamed the changing column over
+	// OldName; the shadow is done.
+	ShadowSwapped
+	// ShadowRolledBack: the shadow was abandoned (reorg failed or was
+	// cancelled) without ever swapping in.
+	ShadowRolledBack
+)
+
+// ShadowColumn tracks one in-flight ALTER ... MODIFY COLUMN's staged
+// "changing" column: OldName is what error messages and readers outside
+// the reorg still see; ChangingName is the invisible column mirrored
+// writes land in until the swap. It does not itself touch
+// catalog.Schema — the DDL txn that adds/renames/drops columns owns
+// that — but it is the single place that tracks which state the shadow is
+// in, so mirrored writes, the reorg task, and the final swap all agree on
+// whether the shadow is still live.
+type ShadowColumn struct {
+	OldName      string
+	ChangingName string
+
+	mu    sync.Mutex
+	state ShadowState
+}
+
+// NewShadowColumn returns a ShadowColumn in ShadowStaging.
+func NewShadowColumn(oldName, changingName string) *ShadowColumn {
+	return &ShadowColumn{OldName: oldName, ChangingName: changingName, state: ShadowStaging}
+}
+
+// State returns the shadow's current lifecycle state.
+func (s *ShadowColumn) State() ShadowState {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.state
+}
+
+// BeginReorg transitions ShadowStaging -> ShadowReorging, returning an
+// error if the shadow is not in ShadowStaging (e.g. it was already swapped
+// or rolled back by a concurrent DDL).
+func (s *ShadowColumn) BeginReorg() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state != ShadowStaging {
+		return fmt.Errorf("reorg: shadow column %s->%s is not staging (state=%d)", s.OldName, s.ChangingName, s.state)
+	}
+	s.state = ShadowReorging
+	return nil
+}
+
+// Swap transitions to ShadowSwapped. Callers should only call this after
+// ColumnReorgTask.Run returned nil; the actual rename/drop is the DDL
+// txn's job.
+func (s *ShadowColumn) Swap() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state != ShadowReorging {
+		return fmt.Errorf("reorg: shadow column %s->%s cannot swap from state %d", s.OldName, s.ChangingName, s.state)
+	}
+	s.state = ShadowSwapped
+	return nil
+}
+
+// Rollback abandons the shadow from any non-terminal state, e.g. because
+// ColumnReorgTask.Run returned ErrCancelled or a conversion error.
+func (s *ShadowColumn) Rollback() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state == ShadowStaging || s.state == ShadowReorging {
+		s.state = ShadowRolledBack
+	}
+}
+
+// MirrorWriteError names which of the old or changing column a concurrent
+// write conflict should be raised against: while the shadow is live,
+// every write to OldName is mirrored onto ChangingName in the same txn, so
+// a W-W conflict can originate from either column depending on which one
+// the other txn touched.
+type MirrorWriteError struct {
+	Column string
+	Cause  error
+}
+
+func (e *MirrorWriteError) Error() string {
+	return fmt.Sprintf("reorg: write conflict on column %q: %v", e.Column, e.Cause)
+}
+
+func (e *MirrorWriteError) Unwrap() error {
+	return e.Cause
+}
+
+// MirrorWrite applies a write to both OldName and ChangingName while the
+// shadow is staging or reorging, surfacing whichever column's apply
+// function reports a conflict via MirrorWriteError. Once the shadow has
+// swapped or rolled back, it is the DDL txn's job to have already stopped
+// routing writes here, so MirrorWrite returns an error rather than writing
+// to a column that may no longer mean what the caller expects.
+func (s *ShadowColumn) MirrorWrite(applyOld, applyChanging func() error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.state != ShadowStaging && s.state != ShadowReorging {
+		return fmt.Errorf("reorg: shadow column %s->%s is no longer accepting mirrored writes (state=%d)", s.OldName, s.ChangingName, s.state)
+	}
+	if err := applyOld(); err != nil {
+		return &MirrorWriteError{Column: s.OldName, Cause: err}
+	}
+	if err := applyChanging(); err != nil {
+		return &MirrorWriteError{Column: s.ChangingName, Cause: err}
+	}
+	return nil
+}
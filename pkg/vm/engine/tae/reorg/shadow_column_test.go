@@ -0,0 +1,124 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package reorg
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestShadowColumnLifecycle(t *testing.T) {
+	s := NewShadowColumn("amount", "__reorg_amount")
+	assert.Equal(t, ShadowStaging, s.State())
+
+	require.NoError(t, s.BeginReorg())
+	assert.Equal(t, ShadowReorging, s.State())
+
+	require.NoError(t, s.Swap())
+	assert.Equal(t, ShadowSwapped, s.State())
+
+	require.Error(t, s.BeginReorg())
+}
+
+func TestShadowColumnRollbackFromEitherLiveState(t *testing.T) {
+	s := NewShadowColumn("amount", "__reorg_amount")
+	s.Rollback()
+	assert.Equal(t, ShadowRolledBack, s.State())
+
+	s2 := NewShadowColumn("amount", "__reorg_amount")
+	require.NoError(t, s2.BeginReorg())
+	s2.Rollback()
+	assert.Equal(t, ShadowRolledBack, s2.State())
+}
+
+func TestMirrorWriteAppliesToBothColumnsWhileLive(t *testing.T) {
+	s := NewShadowColumn("amount", "__reorg_amount")
+	var oldApplied, changingApplied bool
+
+	err := s.MirrorWrite(
+		func() error { oldApplied = true; return nil },
+		func() error { changingApplied = true; return nil },
+	)
+	require.NoError(t, err)
+	assert.True(t, oldApplied)
+	assert.True(t, changingApplied)
+}
+
+func TestMirrorWriteNamesConflictAfterWhicheverColumnFailed(t *testing.T) {
+	s := NewShadowColumn("amount", "__reorg_amount")
+	conflict := errors.New("write-write conflict")
+
+	err := s.MirrorWrite(
+		func() error { return nil },
+		func() error { return conflict },
+	)
+	var mwErr *MirrorWriteError
+	require.True(t, errors.As(err, &mwErr))
+	assert.Equal(t, "__reorg_amount", mwErr.Column)
+	require.ErrorIs(t, err, conflict)
+}
+
+func TestMirrorWriteRejectedAfterSwap(t *testing.T) {
+	s := NewShadowColumn("amount", "__reorg_amount")
+	require.NoError(t, s.BeginReorg())
+	require.NoError(t, s.Swap())
+
+	err := s.MirrorWrite(func() error { return nil }, func() error { return nil })
+	require.Error(t, err)
+}
+
+// TestMirrorWriteBlocksConcurrentSwap proves MirrorWrite holds the shadow
+// live for the whole mirrored write, not just the state check: a Swap
+// racing a slow applyOld must wait until MirrorWrite finishes, so a write
+// can never land on a column the shadow has already swapped or rolled
+// back out from under it.
+func TestMirrorWriteBlocksConcurrentSwap(t *testing.T) {
+	s := NewShadowColumn("amount", "__reorg_amount")
+	require.NoError(t, s.BeginReorg())
+
+	inMirrorWrite := make(chan struct{})
+	releaseMirrorWrite := make(chan struct{})
+	swapped := make(chan struct{})
+
+	go func() {
+		_ = s.MirrorWrite(
+			func() error {
+				close(inMirrorWrite)
+				<-releaseMirrorWrite
+				return nil
+			},
+			func() error { return nil },
+		)
+	}()
+
+	<-inMirrorWrite
+	go func() {
+		require.NoError(t, s.Swap())
+		close(swapped)
+	}()
+
+	select {
+	case <-swapped:
+		t.Fatal("Swap completed while a MirrorWrite was still in flight")
+	default:
+	}
+
+	close(releaseMirrorWrite)
+	<-swapped
+	assert.Equal(t, ShadowSwapped, s.State())
+}
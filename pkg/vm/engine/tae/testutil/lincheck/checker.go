@@ -0,0 +1,144 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lincheck replaces TestChaos1's aggregate invariant check
+// (appendCnt - deleteCnt == rows) with a real linearizability checker: it
+// records every txn op's start/commit timestamp and post-commit visible
+// state, then searches for a serial order consistent with those
+// timestamps that a correct model would also produce. It doesn't know
+// about catalog.BlockEntry, txnimpl.Txn, or handle.Relation.Append/
+// RangeDelete/Commit themselves; a caller wraps those calls to record an
+// Op (capturing StartTS/CommitTS and an Apply closure that replays the
+// call against a candidate state) and to call Scheduler.Yield at the same
+// points, so the interleaving a chaos test exercises is controlled by a
+// seed instead of the Go scheduler. Check's DFS is the Knossos/Wing-Gong
+// style: at each step it only considers ops no remaining op must precede
+// (by commit-before-start real-time order), applies the op's Apply
+// against the candidate state, and backtracks on failure. This is what
+// catches a write-write conflict TestSnapshotIsolation1/2 exercise by
+// hand: if a history contains two concurrent writes to the same key that
+// both appear to have committed, no serial order can apply both Apply
+// closures successfully, and Check reports Linearizable=false.
+package lincheck
+
+// Op is one recorded, already-committed operation in a chaos run. Apply
+// replays the op's real effect against a candidate state in some
+// hypothesized serial order; it must be a pure function of state (no
+// closures over mutable shared state besides what it captured at record
+// time) so Check can try it against many different candidate states
+// during its search.
+type Op[S any] struct {
+	// ID identifies the op for Result.Order; callers typically use a
+	// monotonic per-run counter.
+	ID int
+	// StartTS and CommitTS bound the op's real-time interval. Check only
+	// considers serial orders where every op commits no earlier than it
+	// would given these bounds: ops whose intervals don't overlap must
+	// appear in their real-time order; overlapping ops may appear in
+	// either order.
+	StartTS, CommitTS int64
+	// Apply returns the state after the op, and ok=false if the op
+	// couldn't legally have produced its recorded post-commit state from
+	// the given candidate state (e.g. a stale-snapshot write-write
+	// conflict).
+	Apply func(state S) (next S, ok bool)
+}
+
+// Result is the outcome of Check.
+type Result struct {
+	Linearizable bool
+	// Order holds the witnessing serial order's op IDs when Linearizable
+	// is true.
+	Order []int
+	// Explored is the number of DFS nodes visited.
+	Explored int
+	// BudgetExceeded is true when Check gave up because it hit Budget
+	// without finding a witness; a false Linearizable alongside
+	// BudgetExceeded is inconclusive, not a violation.
+	BudgetExceeded bool
+}
+
+// Checker searches for a linearization of a recorded history.
+type Checker[S any] struct {
+	// Budget caps the number of DFS nodes Check will explore before
+	// giving up. Zero means unbounded.
+	Budget int
+}
+
+// Check returns whether ops admits a serial order, starting from init,
+// consistent with each op's real-time interval.
+func (c Checker[S]) Check(ops []Op[S], init S) Result {
+	used := make([]bool, len(ops))
+	order := make([]int, 0, len(ops))
+	explored := 0
+	exceeded := false
+
+	var dfs func(state S) ([]int, bool)
+	dfs = func(state S) ([]int, bool) {
+		explored++
+		if c.Budget > 0 && explored > c.Budget {
+			exceeded = true
+			return nil, false
+		}
+
+		remaining := false
+		for i := range ops {
+			if used[i] {
+				continue
+			}
+			remaining = true
+			if !eligible(ops, used, i) {
+				continue
+			}
+			next, ok := ops[i].Apply(state)
+			if !ok {
+				continue
+			}
+			used[i] = true
+			order = append(order, ops[i].ID)
+			if witness, done := dfs(next); done {
+				return witness, true
+			}
+			order = order[:len(order)-1]
+			used[i] = false
+		}
+		if !remaining {
+			return append([]int(nil), order...), true
+		}
+		return nil, false
+	}
+
+	witness, ok := dfs(init)
+	return Result{
+		Linearizable:   ok,
+		Order:          witness,
+		Explored:       explored,
+		BudgetExceeded: exceeded && !ok,
+	}
+}
+
+// eligible reports whether ops[i] can be linearized next given which ops
+// are already used: no remaining op may have committed strictly before
+// ops[i] started, since that would force it to precede ops[i].
+func eligible[S any](ops []Op[S], used []bool, i int) bool {
+	for j := range ops {
+		if j == i || used[j] {
+			continue
+		}
+		if ops[j].CommitTS < ops[i].StartTS {
+			return false
+		}
+	}
+	return true
+}
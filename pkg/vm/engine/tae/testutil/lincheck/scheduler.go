@@ -0,0 +1,116 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lincheck
+
+import (
+	"math/rand"
+	"sync"
+)
+
+// Scheduler turns an otherwise Go-scheduler-dependent chaos test into a
+// reproducible one. It precomputes a seeded sequence of turns up front,
+// one actor ID per turn, and holds a single baton: only the actor named
+// by the current turn may run between two of its own Yield calls, so
+// rerunning with the same seed and actor count reproduces the exact same
+// interleaving every time, including a failing one. The intended call
+// sites are the same points TestChaos1 left to the Go scheduler's luck:
+// right before Append/RangeDelete/Commit touch shared state.
+type Scheduler struct {
+	mu   sync.Mutex
+	cond *sync.Cond
+
+	order []int // turn i is owned by actor order[i]
+	pos   int
+
+	current   int  // actor currently holding the baton, -1 if none
+	running   bool // current has returned from Yield holding the baton
+	exhausted bool
+	done      map[int]bool
+}
+
+// NewScheduler returns a Scheduler for n actors (IDs 0..n-1) with a
+// precomputed schedule of turns turns long, deterministically derived
+// from seed. turns should comfortably exceed the total number of Yield
+// calls the run is expected to make; once the schedule is exhausted,
+// Yield stops enforcing the baton so a run can't deadlock on an
+// under-sized budget.
+func NewScheduler(seed int64, n, turns int) *Scheduler {
+	rng := rand.New(rand.NewSource(seed))
+	order := make([]int, turns)
+	for i := range order {
+		order[i] = rng.Intn(n)
+	}
+	s := &Scheduler{order: order, current: -1, done: make(map[int]bool, n)}
+	s.cond = sync.NewCond(&s.mu)
+	return s
+}
+
+// Yield blocks the calling goroutine, identified by id, until the
+// schedule grants it the baton, releasing it first if id has been running
+// with it since its previous Yield. An actor granted the baton before it
+// reaches Yield keeps that turn. Only one actor at a time ever runs
+// between two Yield calls.
+func (s *Scheduler) Yield(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.current == id && s.running {
+		s.current = -1
+	}
+	s.advanceLocked()
+	for !s.exhausted && s.current != id {
+		s.cond.Wait()
+		s.advanceLocked()
+	}
+	s.running = s.current == id
+}
+
+// Done marks actor id as finished and releases the baton if id is
+// currently holding it, so the schedule can move on to another actor
+// instead of waiting on one that will never call Yield again.
+func (s *Scheduler) Done(id int) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.done[id] = true
+	if s.current == id {
+		s.current = -1
+	}
+	s.advanceLocked()
+	s.cond.Broadcast()
+}
+
+// advanceLocked assigns the next schedulable actor to current if no actor
+// currently holds the baton. Callers must hold s.mu.
+func (s *Scheduler) advanceLocked() {
+	if s.current != -1 || s.exhausted {
+		return
+	}
+	for s.pos < len(s.order) {
+		owner := s.order[s.pos]
+		s.pos++
+		if s.done[owner] {
+			continue
+		}
+		s.current = owner
+		s.running = false
+		s.cond.Broadcast()
+		return
+	}
+	// The schedule ran out of turns: stop enforcing the baton so any
+	// actor still waiting can proceed rather than deadlock.
+	s.exhausted = true
+	s.cond.Broadcast()
+}
@@ -0,0 +1,75 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lincheck
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// runInterleaving drives n actors, each recording its id every time it
+// gets a turn, and returns the resulting trace in the order turns were
+// granted.
+func runInterleaving(seed int64, n, yieldsPerActor int) []int {
+	sched := NewScheduler(seed, n, n*yieldsPerActor*8)
+	var mu sync.Mutex
+	var trace []int
+
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(id int) {
+			defer wg.Done()
+			defer sched.Done(id)
+			for j := 0; j < yieldsPerActor; j++ {
+				sched.Yield(id)
+				mu.Lock()
+				trace = append(trace, id)
+				mu.Unlock()
+			}
+		}(i)
+	}
+	wg.Wait()
+	return trace
+}
+
+func TestSchedulerSameSeedReproducesInterleaving(t *testing.T) {
+	const n, yields = 8, 20
+	first := runInterleaving(42, n, yields)
+	second := runInterleaving(42, n, yields)
+	assert.Equal(t, first, second)
+}
+
+func TestSchedulerDifferentSeedsCanDiffer(t *testing.T) {
+	const n, yields = 8, 20
+	a := runInterleaving(1, n, yields)
+	b := runInterleaving(2, n, yields)
+	assert.NotEqual(t, a, b)
+}
+
+func TestSchedulerEveryActorGetsAllTurns(t *testing.T) {
+	const n, yields = 5, 10
+	trace := runInterleaving(7, n, yields)
+	counts := make(map[int]int)
+	for _, id := range trace {
+		counts[id]++
+	}
+	assert.Len(t, trace, n*yields)
+	for id := 0; id < n; id++ {
+		assert.Equal(t, yields, counts[id], "actor %d", id)
+	}
+}
@@ -0,0 +1,69 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package vectorindex implements a block-level IVF-FLAT approximate
+// nearest-neighbor index over fixed-dimension float-vector columns: at
+// compaction time, sample a block's vectors, cluster them into nlist
+// centroids with k-means, then bucket every row under its nearest
+// centroid's posting list. A search picks the nprobe nearest centroids and
+// scans only their postings, so it touches a small fraction of the block
+// instead of every row. Merging top-k results across many blocks (and
+// respecting each block's delete mask) is the caller's job: see TopKHeap.
+package vectorindex
+
+import "math"
+
+// Metric selects how Distance scores two vectors of equal dimension.
+type Metric uint8
+
+const (
+	MetricL2 Metric = iota
+	MetricCosine
+)
+
+// Distance returns a and b's distance under m. For MetricCosine, lower is
+// still "closer": Distance returns 1-cosineSimilarity so MetricL2 and
+// MetricCosine results can both be treated as "smaller is better" by
+// TopKHeap.
+func Distance(m Metric, a, b []float32) float32 {
+	switch m {
+	case MetricCosine:
+		return cosineDistance(a, b)
+	default:
+		return l2Distance(a, b)
+	}
+}
+
+func l2Distance(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return float32(math.Sqrt(float64(sum)))
+}
+
+func cosineDistance(a, b []float32) float32 {
+	var dot, na, nb float32
+	for i := range a {
+		dot += a[i] * b[i]
+		na += a[i] * a[i]
+		nb += b[i] * b[i]
+	}
+	if na == 0 || nb == 0 {
+		return 1
+	}
+	sim := dot / (float32(math.Sqrt(float64(na))) * float32(math.Sqrt(float64(nb))))
+	return 1 - sim
+}
@@ -0,0 +1,168 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vectorindex
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+const kmeansMaxIters = 25
+
+// IVFFlatIndex is one block's IVF-FLAT index: nlist centroids, each with a
+// posting list of the row ids whose vector was assigned to it.
+type IVFFlatIndex struct {
+	dim      int
+	metric   Metric
+	centroids [][]float32
+	postings  [][]int32 // postings[c] = row ids assigned to centroids[c]
+}
+
+// Build clusters vectors (one per row, indexed by row id 0..len-1) into
+// nlist centroids via k-means and buckets each row under its nearest one.
+func Build(vectors [][]float32, nlist int, metric Metric) (*IVFFlatIndex, error) {
+	if len(vectors) == 0 {
+		return nil, fmt.Errorf("vectorindex: cannot build an index over zero vectors")
+	}
+	if nlist < 1 || nlist > len(vectors) {
+		return nil, fmt.Errorf("vectorindex: nlist %d out of range for %d vectors", nlist, len(vectors))
+	}
+	dim := len(vectors[0])
+
+	centroids, assign := kmeans(vectors, nlist, kmeansMaxIters, 1)
+	postings := make([][]int32, nlist)
+	for row, c := range assign {
+		postings[c] = append(postings[c], int32(row))
+	}
+	return &IVFFlatIndex{dim: dim, metric: metric, centroids: centroids, postings: postings}, nil
+}
+
+// SearchResult is one candidate returned by Search, before the caller
+// merges it against other blocks' results.
+type SearchResult struct {
+	Row      int32
+	Distance float32
+}
+
+// Search returns up to k rows nearest query, scanning only the nprobe
+// nearest centroids' posting lists. fetch supplies the stored vector for a
+// row id (e.g. reading the block's column data); isDeleted reports whether
+// a row should be excluded, mirroring view.DeleteMask so deleted rows
+// never surface.
+func (idx *IVFFlatIndex) Search(query []float32, k, nprobe int, fetch func(row int32) []float32, isDeleted func(row int32) bool) ([]SearchResult, error) {
+	if len(query) != idx.dim {
+		return nil, fmt.Errorf("vectorindex: query dimension %d does not match index dimension %d", len(query), idx.dim)
+	}
+	if nprobe > len(idx.centroids) {
+		nprobe = len(idx.centroids)
+	}
+
+	type centroidDist struct {
+		idx  int
+		dist float32
+	}
+	cds := make([]centroidDist, len(idx.centroids))
+	for i, c := range idx.centroids {
+		cds[i] = centroidDist{idx: i, dist: Distance(idx.metric, query, c)}
+	}
+	sort.Slice(cds, func(i, j int) bool { return cds[i].dist < cds[j].dist })
+
+	heap := NewTopKHeap(k)
+	for _, cd := range cds[:nprobe] {
+		for _, row := range idx.postings[cd.idx] {
+			if isDeleted != nil && isDeleted(row) {
+				continue
+			}
+			d := Distance(idx.metric, query, fetch(row))
+			heap.Offer(SearchResult{Row: row, Distance: d})
+		}
+	}
+	return heap.Sorted(), nil
+}
+
+// Encode serializes the index: dimension, metric, centroid count, the
+// centroid vectors, then each posting list length-prefixed.
+func (idx *IVFFlatIndex) Encode() []byte {
+	buf := make([]byte, 0, 16+len(idx.centroids)*idx.dim*4)
+	var hdr [12]byte
+	binary.LittleEndian.PutUint32(hdr[0:4], uint32(idx.dim))
+	hdr[4] = byte(idx.metric)
+	binary.LittleEndian.PutUint32(hdr[8:12], uint32(len(idx.centroids)))
+	buf = append(buf, hdr[:]...)
+
+	for _, c := range idx.centroids {
+		for _, v := range c {
+			var b [4]byte
+			binary.LittleEndian.PutUint32(b[:], math.Float32bits(v))
+			buf = append(buf, b[:]...)
+		}
+	}
+	for _, p := range idx.postings {
+		var lenBuf [4]byte
+		binary.LittleEndian.PutUint32(lenBuf[:], uint32(len(p)))
+		buf = append(buf, lenBuf[:]...)
+		for _, row := range p {
+			var b [4]byte
+			binary.LittleEndian.PutUint32(b[:], uint32(row))
+			buf = append(buf, b[:]...)
+		}
+	}
+	return buf
+}
+
+// Decode parses an index previously produced by Encode.
+func Decode(buf []byte) (*IVFFlatIndex, error) {
+	if len(buf) < 12 {
+		return nil, fmt.Errorf("vectorindex: buffer too short: %d bytes", len(buf))
+	}
+	dim := int(binary.LittleEndian.Uint32(buf[0:4]))
+	metric := Metric(buf[4])
+	nlist := int(binary.LittleEndian.Uint32(buf[8:12]))
+	off := 12
+
+	centroids := make([][]float32, nlist)
+	for i := range centroids {
+		c := make([]float32, dim)
+		for d := 0; d < dim; d++ {
+			if off+4 > len(buf) {
+				return nil, fmt.Errorf("vectorindex: buffer truncated reading centroid %d", i)
+			}
+			c[d] = math.Float32frombits(binary.LittleEndian.Uint32(buf[off : off+4]))
+			off += 4
+		}
+		centroids[i] = c
+	}
+
+	postings := make([][]int32, nlist)
+	for i := range postings {
+		if off+4 > len(buf) {
+			return nil, fmt.Errorf("vectorindex: buffer truncated reading posting list %d length", i)
+		}
+		n := int(binary.LittleEndian.Uint32(buf[off : off+4]))
+		off += 4
+		p := make([]int32, n)
+		for j := 0; j < n; j++ {
+			if off+4 > len(buf) {
+				return nil, fmt.Errorf("vectorindex: buffer truncated reading posting list %d entry %d", i, j)
+			}
+			p[j] = int32(binary.LittleEndian.Uint32(buf[off : off+4]))
+			off += 4
+		}
+		postings[i] = p
+	}
+	return &IVFFlatIndex{dim: dim, metric: metric, centroids: centroids, postings: postings}, nil
+}
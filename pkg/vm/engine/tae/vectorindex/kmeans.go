@@ -0,0 +1,78 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vectorindex
+
+import (
+	"math"
+	"math/rand"
+)
+
+// kmeans clusters vectors into len(centroids) groups using Lloyd's
+// algorithm, seeded from a deterministic RNG so index builds are
+// reproducible given the same input. It runs until assignments stop
+// changing or maxIters is reached, and returns the final centroids plus
+// each vector's assigned cluster.
+func kmeans(vectors [][]float32, nlist, maxIters int, seed int64) (centroids [][]float32, assign []int) {
+	dim := len(vectors[0])
+	rng := rand.New(rand.NewSource(seed))
+
+	centroids = make([][]float32, nlist)
+	for i, p := range rng.Perm(len(vectors))[:nlist] {
+		centroids[i] = append([]float32(nil), vectors[p]...)
+	}
+	assign = make([]int, len(vectors))
+
+	for iter := 0; iter < maxIters; iter++ {
+		changed := false
+		for i, v := range vectors {
+			best, bestDist := 0, float32(math.MaxFloat32)
+			for c, centroid := range centroids {
+				d := l2Distance(v, centroid)
+				if d < bestDist {
+					best, bestDist = c, d
+				}
+			}
+			if assign[i] != best {
+				assign[i] = best
+				changed = true
+			}
+		}
+		if !changed && iter > 0 {
+			break
+		}
+
+		sums := make([][]float32, nlist)
+		counts := make([]int, nlist)
+		for c := range sums {
+			sums[c] = make([]float32, dim)
+		}
+		for i, v := range vectors {
+			c := assign[i]
+			counts[c]++
+			for d := 0; d < dim; d++ {
+				sums[c][d] += v[d]
+			}
+		}
+		for c := range centroids {
+			if counts[c] == 0 {
+				continue // keep the previous centroid rather than divide by zero
+			}
+			for d := 0; d < dim; d++ {
+				centroids[c][d] = sums[c][d] / float32(counts[c])
+			}
+		}
+	}
+	return centroids, assign
+}
@@ -0,0 +1,97 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vectorindex
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// blockResult pairs a per-block SearchResult with the block it came from,
+// since Row is only unique within the block whose IVFFlatIndex produced
+// it: a future handle.Relation.VectorSearch merging per-block results
+// across a table's blocks needs this pairing to disambiguate, not just
+// SearchResult.Row on its own.
+type blockResult struct {
+	block int
+	SearchResult
+}
+
+// TestMergeTopKAcrossMultipleBlockIndexes proves the cross-block merge a
+// handle.Relation.VectorSearch would need to do once it exists: each
+// block builds and searches its own IVFFlatIndex independently, and the
+// per-block results merge into a single top-k that matches a brute-force
+// scan over every block's vectors combined.
+func TestMergeTopKAcrossMultipleBlockIndexes(t *testing.T) {
+	const blocks, rowsPerBlock, dim, k = 3, 100, 8, 10
+
+	var allVectors [][]float32
+	perBlockVectors := make([][][]float32, blocks)
+	for b := 0; b < blocks; b++ {
+		vecs := randVectors(rowsPerBlock, dim, int64(100+b))
+		perBlockVectors[b] = vecs
+		allVectors = append(allVectors, vecs...)
+	}
+
+	query := perBlockVectors[1][7]
+
+	heap := NewTopKHeap(k)
+	var merged []blockResult
+	for b, vecs := range perBlockVectors {
+		idx, err := Build(vecs, 10, MetricL2)
+		require.NoError(t, err)
+		fetch := func(row int32) []float32 { return vecs[row] }
+		got, err := idx.Search(query, k, 6, fetch, nil)
+		require.NoError(t, err)
+		for _, r := range got {
+			heap.Offer(r)
+			merged = append(merged, blockResult{block: b, SearchResult: r})
+		}
+	}
+	mergedTop := heap.Sorted()
+	require.Len(t, mergedTop, k)
+
+	want := bruteForceTopK(query, allVectors, nil, k, MetricL2)
+	wantSet := make(map[int32]bool, len(want))
+	for _, row := range want {
+		wantSet[row] = true
+	}
+
+	// Translate each merged result's block-local Row back into the
+	// concatenated allVectors id space to compare against want.
+	globalSet := make(map[int32]bool, len(mergedTop))
+	for _, r := range mergedTop {
+		for _, m := range merged {
+			if m.Row == r.Row && m.Distance == r.Distance {
+				globalSet[int32(m.block*rowsPerBlock)+r.Row] = true
+				break
+			}
+		}
+	}
+
+	overlap := 0
+	for row := range wantSet {
+		if globalSet[row] {
+			overlap++
+		}
+	}
+	assert.GreaterOrEqual(t, overlap, k*6/10)
+
+	sort.Slice(mergedTop, func(i, j int) bool { return mergedTop[i].Distance < mergedTop[j].Distance })
+	assert.Equal(t, mergedTop, heap.Sorted())
+}
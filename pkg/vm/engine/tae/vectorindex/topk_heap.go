@@ -0,0 +1,68 @@
+// Copyright 2022 Matrix Origin
+//
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package vectorindex
+
+import "container/heap"
+
+// TopKHeap keeps the k smallest-distance SearchResults offered to it,
+// across as many blocks as a caller merges together. It is a bounded max
+// heap on Distance: the root is the current worst of the k kept so far, so
+// Offer can reject a candidate in O(log k) without ever growing past k.
+type TopKHeap struct {
+	k int
+	h maxDistHeap
+}
+
+// NewTopKHeap returns a TopKHeap that keeps at most k results.
+func NewTopKHeap(k int) *TopKHeap {
+	return &TopKHeap{k: k}
+}
+
+// Offer considers r for inclusion in the top k, replacing the current
+// worst kept result if r is closer and the heap is already full.
+func (t *TopKHeap) Offer(r SearchResult) {
+	if len(t.h) < t.k {
+		heap.Push(&t.h, r)
+		return
+	}
+	if t.k > 0 && r.Distance < t.h[0].Distance {
+		t.h[0] = r
+		heap.Fix(&t.h, 0)
+	}
+}
+
+// Sorted drains the heap into ascending-distance order (nearest first).
+func (t *TopKHeap) Sorted() []SearchResult {
+	out := make([]SearchResult, len(t.h))
+	tmp := append(maxDistHeap(nil), t.h...)
+	for i := len(out) - 1; i >= 0; i-- {
+		out[i] = heap.Pop(&tmp).(SearchResult)
+	}
+	return out
+}
+
+type maxDistHeap []SearchResult
+
+func (h maxDistHeap) Len() int            { return len(h) }
+func (h maxDistHeap) Less(i, j int) bool  { return h[i].Distance > h[j].Distance }
+func (h maxDistHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *maxDistHeap) Push(x interface{}) { *h = append(*h, x.(SearchResult)) }
+func (h *maxDistHeap) Pop() interface{} {
+	old := *h
+	n := len(old)
+	v := old[n-1]
+	*h = old[:n-1]
+	return v
+}